@@ -0,0 +1,324 @@
+package benchmark
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricAliases are the short names of frequently asserted metrics.
+var metricAliases = map[string]string{
+	"p50": "msg_time_p50",
+	"p90": "msg_time_p90",
+	"p99": "msg_time_p99",
+}
+
+// latencySamples maps the prefixes of the latency metrics to the number of samples they
+// are calculated from. The latencies are zero without samples, so they are not available,
+// e.g. the percentiles of subscribers receiving messages without a payload header.
+var latencySamples = map[string]string{
+	"msg_time_":                 "msg_time_histogram.count",
+	"reconnect.reconnect_time_": "reconnect.reconnects",
+	"session.drain_time_":       "session.queued",
+	"session.redelivery_time_":  "session.queued",
+	"churn.sub_time_":           "churn.subscribes",
+	"churn.unsub_time_":         "churn.unsubscribes",
+	"connect.connect_time_":     "connect.accepted",
+	"retained.set_time_":        "retained.complete_subscribers",
+	"request.rtt_":              "request.responses",
+	"will.will_time_":           "will.received",
+}
+
+var assertionPattern = regexp.MustCompile(`^\s*([A-Za-z0-9_.]+)\s*(>=|<=|==|!=|>|<)\s*(\S+)\s*$`)
+
+// Assertion is a condition on a metric of the total results, e.g. ratio>=0.999 or p99<50ms.
+// Metrics are the JSON names of the TotalResults fields, the fields of the optional
+// results are addressed by path, e.g. request.rtt_p99.
+type Assertion struct {
+	Expr   string
+	Metric string
+	Op     string
+	Value  float64
+}
+
+// AssertionResult is the outcome of an assertion, Err is set if the metric is not available.
+type AssertionResult struct {
+	Assertion *Assertion
+	Actual    float64
+	Passed    bool
+	Err       error
+}
+
+// ParseAssertion parses an assertion expression: metric, comparison operator and value.
+// The value is a number, a percentage (99.9%) or a duration (50ms), which is converted
+// to the unit of the metric: seconds for run times, milliseconds for latencies.
+func ParseAssertion(expr string) (*Assertion, error) {
+	m := assertionPattern.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("invalid assertion %q, expected {metric}{op}{value}, e.g. ratio>=0.999", expr)
+	}
+	a := &Assertion{Expr: strings.TrimSpace(expr), Metric: strings.ToLower(m[1]), Op: m[2]}
+	if name, ok := metricAliases[a.Metric]; ok {
+		a.Metric = name
+	}
+	if _, ok := metricField(reflect.TypeOf(TotalResults{}), strings.Split(a.Metric, ".")); !ok {
+		return nil, fmt.Errorf("invalid assertion %q, unknown metric: %v", expr, m[1])
+	}
+
+	value := m[3]
+	if v, err := strconv.ParseFloat(value, 64); err == nil {
+		a.Value = v
+	} else if strings.HasSuffix(value, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid assertion %q, invalid percentage: %v", expr, value)
+		}
+		a.Value = v / 100
+	} else if d, err := time.ParseDuration(value); err == nil {
+		if isSecondsMetric(a.Metric) {
+			a.Value = d.Seconds()
+		} else {
+			a.Value = float64(d) / float64(time.Millisecond)
+		}
+	} else {
+		return nil, fmt.Errorf("invalid assertion %q, invalid value: %v", expr, value)
+	}
+	return a, nil
+}
+
+// isSecondsMetric reports whether the metric is in seconds, all other time metrics are in milliseconds.
+func isSecondsMetric(metric string) bool {
+	name := metric[strings.LastIndex(metric, ".")+1:]
+	switch name {
+	case "offline_time", "load_time", "reset_interval":
+		return true
+	}
+	return strings.Contains(name, "run_time")
+}
+
+// Check evaluates the assertion against the total results.
+func (a *Assertion) Check(totals *TotalResults) *AssertionResult {
+	res := &AssertionResult{Assertion: a}
+	v, ok := totalsMetric(totals, a.Metric)
+	if !ok {
+		res.Err = fmt.Errorf("metric %v is not available in %v results", a.Metric, totals.TestRunType)
+		return res
+	}
+	res.Actual = v
+	switch a.Op {
+	case ">=":
+		res.Passed = v >= a.Value
+	case "<=":
+		res.Passed = v <= a.Value
+	case ">":
+		res.Passed = v > a.Value
+	case "<":
+		res.Passed = v < a.Value
+	case "==":
+		res.Passed = v == a.Value
+	case "!=":
+		res.Passed = v != a.Value
+	}
+	return res
+}
+
+// CheckAssertions evaluates all assertions against the total results.
+func CheckAssertions(assertions []*Assertion, totals *TotalResults) []*AssertionResult {
+	results := make([]*AssertionResult, len(assertions))
+	for i, a := range assertions {
+		results[i] = a.Check(totals)
+	}
+	return results
+}
+
+// AssertionsFailed returns the number of failed assertions.
+func AssertionsFailed(results []*AssertionResult) int {
+	failed := 0
+	for _, r := range results {
+		if !r.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// totalsMetric returns the value of the metric, false if the optional results it belongs
+// to are not set or the latencies were not measured.
+func totalsMetric(totals *TotalResults, metric string) (float64, bool) {
+	for prefix, samples := range latencySamples {
+		if strings.HasPrefix(metric, prefix) {
+			if n, ok := metricValue(reflect.ValueOf(totals), strings.Split(samples, ".")); !ok || n == 0 {
+				return 0, false
+			}
+			break
+		}
+	}
+	return metricValue(reflect.ValueOf(totals), strings.Split(metric, "."))
+}
+
+// metricField finds the numeric field with the JSON name path in the type.
+func metricField(t reflect.Type, path []string) (reflect.StructField, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if jsonName(f) != path[0] {
+			continue
+		}
+		if len(path) > 1 {
+			return metricField(f.Type, path[1:])
+		}
+		switch f.Type.Kind() {
+		case reflect.Int, reflect.Int64, reflect.Float64, reflect.Bool:
+			return f, true
+		}
+		return reflect.StructField{}, false
+	}
+	return reflect.StructField{}, false
+}
+
+// metricValue returns the value of the field with the JSON name path, false if
+// the optional results it belongs to are not set.
+func metricValue(v reflect.Value, path []string) (float64, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, false
+		}
+		v = v.Elem()
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if jsonName(v.Type().Field(i)) != path[0] {
+			continue
+		}
+		f := v.Field(i)
+		if len(path) > 1 {
+			return metricValue(f, path[1:])
+		}
+		switch f.Kind() {
+		case reflect.Int, reflect.Int64:
+			return float64(f.Int()), true
+		case reflect.Float64:
+			return f.Float(), true
+		case reflect.Bool:
+			if f.Bool() {
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func jsonName(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("json"), ",")[0]
+}
+
+// PrintAssertions prints the outcome of the assertions.
+func PrintAssertions(results []*AssertionResult) {
+	fmt.Printf("========= ASSERTIONS =========\n")
+	for _, r := range results {
+		status := "PASS"
+		if !r.Passed {
+			status = "FAIL"
+		}
+		if r.Err != nil {
+			fmt.Printf("%-34s%v (%v)\n", r.Assertion.Expr+":", status, r.Err)
+			continue
+		}
+		fmt.Printf("%-34s%v (actual %.3f)\n", r.Assertion.Expr+":", status, r.Actual)
+	}
+	fmt.Printf("Failed Assertions:                %d/%d\n", AssertionsFailed(results), len(results))
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// AssertionReport is the outcome of the assertions checked against the results of a run.
+type AssertionReport struct {
+	Totals  *TotalResults
+	Results []*AssertionResult
+}
+
+// WriteJUnit writes the outcome of the assertions as a JUnit XML report, with a test
+// suite per run named after the test case id and a test case per assertion.
+func WriteJUnit(w io.Writer, reports ...AssertionReport) error {
+	var suites junitTestSuites
+	for i, report := range reports {
+		name := report.Totals.TestCaseID
+		if name == "" {
+			name = "mqtt-benchmark"
+		}
+		if len(reports) > 1 {
+			name = fmt.Sprintf("%v-run%d", name, i+1)
+		}
+		suites.Suites = append(suites.Suites, newJUnitTestSuite(name, report.Totals, report.Results))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func newJUnitTestSuite(name string, totals *TotalResults, results []*AssertionResult) junitTestSuite {
+	suite := junitTestSuite{
+		Name:      name,
+		Tests:     len(results),
+		Time:      totals.TotalRunTime,
+		Timestamp: totals.TestStart.Format("2006-01-02T15:04:05"),
+	}
+	for _, r := range results {
+		tc := junitTestCase{Name: r.Assertion.Expr, ClassName: name + "." + totals.TestRunType}
+		if r.Err != nil {
+			suite.Errors++
+			tc.Error = &junitFailure{Message: r.Err.Error(), Type: "unavailable"}
+		} else if !r.Passed {
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%v is %.3f, expected %v %v", r.Assertion.Metric, r.Actual, r.Assertion.Op, r.Assertion.Value),
+				Type:    "assertion",
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	return suite
+}
@@ -0,0 +1,106 @@
+package benchmark
+
+import (
+	"testing"
+)
+
+func TestParseAssertion(t *testing.T) {
+	tests := []struct {
+		expr   string
+		metric string
+		op     string
+		value  float64
+	}{
+		{"ratio>=0.999", "ratio", ">=", 0.999},
+		{" ratio >= 99.9% ", "ratio", ">=", 0.999},
+		{"p99<50ms", "msg_time_p99", "<", 50},
+		{"P90<=1s", "msg_time_p90", "<=", 1000},
+		{"msg_time_mean_mean<1500us", "msg_time_mean_mean", "<", 1.5},
+		{"total_run_time<2m", "total_run_time", "<", 120},
+		{"client_run_time_max<=500ms", "client_run_time_max", "<=", 0.5},
+		{"request.rtt_p99<20ms", "request.rtt_p99", "<", 20},
+		{"session.offline_time>=10s", "session.offline_time", ">=", 10},
+		{"retained.load_time<1m", "retained.load_time", "<", 60},
+		{"failures==0", "failures", "==", 0},
+		{"interrupted!=1", "interrupted", "!=", 1},
+		{"total_msgs_per_sec>1e4", "total_msgs_per_sec", ">", 10000},
+	}
+	for _, tt := range tests {
+		a, err := ParseAssertion(tt.expr)
+		if err != nil {
+			t.Errorf("ParseAssertion(%q) returned error: %v", tt.expr, err)
+			continue
+		}
+		if a.Metric != tt.metric || a.Op != tt.op || !almostEqual(a.Value, tt.value, 1e-9) {
+			t.Errorf("ParseAssertion(%q) = %v %v %v, want %v %v %v", tt.expr, a.Metric, a.Op, a.Value, tt.metric, tt.op, tt.value)
+		}
+	}
+}
+
+func TestParseAssertionErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"ratio",
+		"ratio=>1",
+		"ratio>=",
+		"unknown>=1",
+		"request>=1",
+		"test_run_id==1",
+		"ratio>=fast",
+		"ratio>=x%",
+	} {
+		if _, err := ParseAssertion(expr); err == nil {
+			t.Errorf("ParseAssertion(%q) returned no error", expr)
+		}
+	}
+}
+
+func TestAssertionCheck(t *testing.T) {
+	measured := &TotalResults{
+		TestRunType:      "pub",
+		Ratio:            0.99,
+		MsgTimeP99:       42,
+		MsgTimeHistogram: NewHistogram([]float64{1, 42}),
+		Request:          &RequestResults{Responses: 10, RTTP99: 5},
+	}
+	unmeasured := &TotalResults{
+		TestRunType: "sub",
+		Ratio:       1,
+		Request:     &RequestResults{},
+	}
+	tests := []struct {
+		expr      string
+		totals    *TotalResults
+		passed    bool
+		available bool
+	}{
+		{"ratio>=0.999", measured, false, true},
+		{"ratio>=0.99", measured, true, true},
+		{"p99<50ms", measured, true, true},
+		{"p99<40ms", measured, false, true},
+		{"request.rtt_p99<10ms", measured, true, true},
+		{"connect.max_concurrent>0", measured, false, false},
+		// latencies without samples must not pass vacuously
+		{"p99<50ms", unmeasured, false, false},
+		{"msg_time_max<1s", unmeasured, false, false},
+		{"request.rtt_p99<10ms", unmeasured, false, false},
+		{"ratio>=1", unmeasured, true, true},
+		{"client_id_takeovers==0", unmeasured, true, true},
+	}
+	for _, tt := range tests {
+		a, err := ParseAssertion(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseAssertion(%q) returned error: %v", tt.expr, err)
+		}
+		res := a.Check(tt.totals)
+		if res.Passed != tt.passed || (res.Err == nil) != tt.available {
+			t.Errorf("%q on %v results: passed %v, err %v, want passed %v, available %v",
+				tt.expr, tt.totals.TestRunType, res.Passed, res.Err, tt.passed, tt.available)
+		}
+	}
+}
+
+func almostEqual(a, b, tolerance float64) bool {
+	d := a - b
+	return d <= tolerance && d >= -tolerance
+}
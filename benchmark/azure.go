@@ -0,0 +1,111 @@
+package benchmark
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// ProfileAzure connects clients as Azure IoT Hub / IoT Edge hub devices.
+	ProfileAzure = "azure"
+
+	// AzureD2C sends device-to-cloud telemetry: publishers are devices and subscribers
+	// stand in for the hub backend reading the telemetry.
+	AzureD2C = "d2c"
+	// AzureC2D sends cloud-to-device messages: subscribers are devices and publishers
+	// stand in for the hub backend sending the messages.
+	AzureC2D = "c2d"
+
+	// azureAPIVersion is the IoT Hub API version sent in the username.
+	azureAPIVersion = "2021-04-12"
+)
+
+func isValidAzureDirection(direction string) bool {
+	return direction == AzureD2C || direction == AzureC2D
+}
+
+// AzureProfile maps the clients and topics of the benchmark to IoT Hub devices:
+// topic k belongs to device {DevicePrefix}{k} and clients authenticate with SAS tokens.
+// It works against the hub as well as any MQTT broker standing in for it.
+type AzureProfile struct {
+	// Hub is the hub host name, e.g. myhub.azure-devices.net.
+	Hub string
+
+	// Key is the base64 encoded device key. With GroupKey it is the key of a group
+	// enrollment, and the key of each device is derived from it.
+	Key      string
+	GroupKey bool
+
+	DevicePrefix string
+	Direction    string
+
+	// TokenTTL is the lifetime of the SAS tokens.
+	TokenTTL time.Duration
+}
+
+// DeviceID returns the id of the device owning the k-th topic.
+func (p *AzureProfile) DeviceID(k int) string {
+	return fmt.Sprintf("%s%d", p.DevicePrefix, k)
+}
+
+// BackendID returns the id of the i-th client standing in for the hub backend.
+func (p *AzureProfile) BackendID(i int) string {
+	return fmt.Sprintf("%sbackend-%d", p.DevicePrefix, i)
+}
+
+// IsDevice returns true if publishers (or subscribers) are devices in the configured direction.
+func (p *AzureProfile) IsDevice(publisher bool) bool {
+	return publisher == (p.Direction == AzureD2C)
+}
+
+// Topics maps the benchmark topics (/test{k}) of a client to the device topics:
+// devices/{id}/messages/events/ for telemetry, devices/{id}/messages/devicebound/ for C2D messages.
+// Subscribers get topic filters, as the hub appends properties to the topics.
+func (p *AzureProfile) Topics(topics []string, publisher bool) []string {
+	result := make([]string, len(topics))
+	for i, name := range topics {
+		var k int
+		fmt.Sscanf(name, "/test%d", &k)
+		kind := "events"
+		if p.Direction == AzureC2D {
+			kind = "devicebound"
+		}
+		result[i] = fmt.Sprintf("devices/%s/messages/%s/", p.DeviceID(k), kind)
+		if !publisher {
+			result[i] += "#"
+		}
+	}
+	return result
+}
+
+// Credentials returns the client id, username and SAS token of the given device.
+func (p *AzureProfile) Credentials(deviceID string) (*Credentials, error) {
+	key, err := base64.StdEncoding.DecodeString(p.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device key: %v", err)
+	}
+	if p.GroupKey {
+		key = azureSign(key, deviceID)
+	}
+
+	resource := url.QueryEscape(fmt.Sprintf("%s/devices/%s", p.Hub, deviceID))
+	expiry := strconv.FormatInt(time.Now().Add(p.TokenTTL).Unix(), 10)
+	sig := base64.StdEncoding.EncodeToString(azureSign(key, resource+"\n"+expiry))
+	return &Credentials{
+		ClientID: deviceID,
+		Username: fmt.Sprintf("%s/%s/?api-version=%s", p.Hub, deviceID, azureAPIVersion),
+		Password: fmt.Sprintf("SharedAccessSignature sr=%s&sig=%s&se=%s", resource, url.QueryEscape(sig), expiry),
+	}, nil
+}
+
+// azureSign returns the HMAC-SHA256 of the message with the key.
+func azureSign(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
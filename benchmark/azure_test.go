@@ -0,0 +1,100 @@
+package benchmark
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestAzureTopics(t *testing.T) {
+	tests := []struct {
+		direction string
+		publisher bool
+		device    bool
+		want      []string
+	}{
+		{AzureD2C, true, true, []string{"devices/dev7/messages/events/", "devices/dev12/messages/events/"}},
+		{AzureD2C, false, false, []string{"devices/dev7/messages/events/#", "devices/dev12/messages/events/#"}},
+		{AzureC2D, true, false, []string{"devices/dev7/messages/devicebound/", "devices/dev12/messages/devicebound/"}},
+		{AzureC2D, false, true, []string{"devices/dev7/messages/devicebound/#", "devices/dev12/messages/devicebound/#"}},
+	}
+	for _, tt := range tests {
+		p := &AzureProfile{DevicePrefix: "dev", Direction: tt.direction}
+		if got := p.Topics([]string{"/test7", "/test12"}, tt.publisher); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v topics of publisher %v = %q, want %q", tt.direction, tt.publisher, got, tt.want)
+		}
+		if got := p.IsDevice(tt.publisher); got != tt.device {
+			t.Errorf("%v publisher %v is device = %v, want %v", tt.direction, tt.publisher, got, tt.device)
+		}
+	}
+	for direction, want := range map[string]bool{AzureD2C: true, AzureC2D: true, "both": false} {
+		if got := isValidAzureDirection(direction); got != want {
+			t.Errorf("isValidAzureDirection(%v) = %v", direction, got)
+		}
+	}
+}
+
+func TestAzureCredentials(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	tests := []struct {
+		name     string
+		groupKey bool
+		signKey  []byte
+	}{
+		{"device key", false, key},
+		{"group key", true, azureSign(key, "dev3")},
+	}
+	for _, tt := range tests {
+		p := &AzureProfile{
+			Hub:          "hub.azure-devices.net",
+			Key:          base64.StdEncoding.EncodeToString(key),
+			GroupKey:     tt.groupKey,
+			DevicePrefix: "dev",
+			TokenTTL:     time.Hour,
+		}
+		cr, err := p.Credentials(p.DeviceID(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cr.ClientID != "dev3" || cr.Username != "hub.azure-devices.net/dev3/?api-version="+azureAPIVersion {
+			t.Errorf("%v: client id %v, username %v", tt.name, cr.ClientID, cr.Username)
+		}
+
+		// SharedAccessSignature sr={resource}&sig={signature}&se={expiry}
+		token, err := url.ParseQuery(strings.TrimPrefix(cr.Password, "SharedAccessSignature "))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resource := url.QueryEscape(token.Get("sr"))
+		if token.Get("sr") != "hub.azure-devices.net/devices/dev3" {
+			t.Errorf("%v: token resource %v", tt.name, token.Get("sr"))
+		}
+		expiry, _ := strconv.ParseInt(token.Get("se"), 10, 64)
+		if ttl := time.Until(time.Unix(expiry, 0)); ttl < 59*time.Minute || ttl > time.Hour {
+			t.Errorf("%v: token expires in %v, want 1h", tt.name, ttl)
+		}
+		sig, _ := base64.StdEncoding.DecodeString(token.Get("sig"))
+		if !hmac.Equal(sig, azureSign(tt.signKey, resource+"\n"+token.Get("se"))) {
+			t.Errorf("%v: invalid token signature", tt.name)
+		}
+	}
+
+	if _, err := (&AzureProfile{Key: "not base64!"}).Credentials("dev0"); err == nil {
+		t.Error("credentials created with an invalid key")
+	}
+}
+
+func TestCredentialsApply(t *testing.T) {
+	opts := mqtt.NewClientOptions().SetClientID("pub-0").SetUsername("user").SetPassword("pass")
+	(&Credentials{Password: "token"}).apply(opts)
+	if opts.ClientID != "pub-0" || opts.Username != "user" || opts.Password != "token" {
+		t.Errorf("client id %v, username %v, password %v, want only the password replaced", opts.ClientID, opts.Username, opts.Password)
+	}
+}
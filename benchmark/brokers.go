@@ -0,0 +1,49 @@
+package benchmark
+
+import (
+	"strings"
+)
+
+const (
+	// BrokerRoundRobin assigns clients to the broker nodes in turn.
+	BrokerRoundRobin = "roundrobin"
+	// BrokerSplit pins publishers to the first node and all other clients to the second one,
+	// to measure the latency of routing messages b/w cluster nodes.
+	BrokerSplit = "split"
+	// BrokerFailover connects all clients to the first available node in the given order.
+	BrokerFailover = "failover"
+)
+
+// getBrokerURLs splits the comma separated list of broker endpoints.
+func getBrokerURLs(brokers string) []string {
+	var result []string
+	for _, broker := range strings.Split(brokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			result = append(result, broker)
+		}
+	}
+	return result
+}
+
+func isValidBrokerStrategy(strategy string) bool {
+	switch strategy {
+	case BrokerRoundRobin, BrokerSplit, BrokerFailover:
+		return true
+	}
+	return false
+}
+
+// assignBroker returns the broker endpoint of the i-th client. With the failover strategy
+// it is the comma separated list of all nodes, which are tried in order when connecting.
+func assignBroker(strategy string, brokers []string, publisher bool, i int) string {
+	switch strategy {
+	case BrokerSplit:
+		if publisher {
+			return brokers[0]
+		}
+		return brokers[1]
+	case BrokerFailover:
+		return strings.Join(brokers, ",")
+	}
+	return brokers[i%len(brokers)]
+}
@@ -0,0 +1,80 @@
+package benchmark
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetBrokerURLs(t *testing.T) {
+	tests := []struct {
+		brokers string
+		want    []string
+	}{
+		{"tcp://localhost:1883", []string{"tcp://localhost:1883"}},
+		{"tcp://a:1883, tcp://b:1883,,tcp://c:1883 ", []string{"tcp://a:1883", "tcp://b:1883", "tcp://c:1883"}},
+		{" , ", nil},
+	}
+	for _, tt := range tests {
+		if got := getBrokerURLs(tt.brokers); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("getBrokerURLs(%q) = %q, want %q", tt.brokers, got, tt.want)
+		}
+	}
+}
+
+func TestAssignBroker(t *testing.T) {
+	brokers := []string{"tcp://a:1883", "tcp://b:1883", "tcp://c:1883"}
+	tests := []struct {
+		strategy  string
+		publisher bool
+		i         int
+		want      string
+	}{
+		{BrokerRoundRobin, true, 0, "tcp://a:1883"},
+		{BrokerRoundRobin, false, 1, "tcp://b:1883"},
+		{BrokerRoundRobin, true, 5, "tcp://c:1883"},
+		{BrokerRoundRobin, false, 6, "tcp://a:1883"},
+		{BrokerSplit, true, 4, "tcp://a:1883"},
+		{BrokerSplit, false, 0, "tcp://b:1883"},
+		{BrokerSplit, false, 5, "tcp://b:1883"},
+		{BrokerFailover, true, 2, "tcp://a:1883,tcp://b:1883,tcp://c:1883"},
+	}
+	for _, tt := range tests {
+		if got := assignBroker(tt.strategy, brokers, tt.publisher, tt.i); got != tt.want {
+			t.Errorf("assignBroker(%v, publisher %v, %d) = %v, want %v", tt.strategy, tt.publisher, tt.i, got, tt.want)
+		}
+	}
+	for strategy, want := range map[string]bool{BrokerRoundRobin: true, BrokerSplit: true, BrokerFailover: true, "random": false} {
+		if got := isValidBrokerStrategy(strategy); got != want {
+			t.Errorf("isValidBrokerStrategy(%v) = %v", strategy, got)
+		}
+	}
+}
+
+func TestCalculateNodeResults(t *testing.T) {
+	brokers := []string{"tcp://a:1883", "tcp://b:1883", "tcp://c:1883"}
+	results := []*RunResults{
+		{Broker: "tcp://a:1883", Successes: 90, Failures: 10, MsgTimeMin: 2, MsgTimeMax: 9, MsgTimeMean: 4},
+		{Broker: "tcp://a:1883", Successes: 100, MsgTimeMin: 1, MsgTimeMax: 5, MsgTimeMean: 2},
+		{Broker: "tcp://b:1883", Successes: 100, MsgTimeMin: 3, MsgTimeMax: 4, MsgTimeMean: 3},
+		// never connected
+		{Failures: 100},
+	}
+	nodes := calculateNodeResults(results, brokers, 10)
+	if len(nodes) != 3 {
+		t.Fatalf("%d nodes, want a, b and unconnected without the unused c", len(nodes))
+	}
+
+	a := nodes[0]
+	if a.Broker != "tcp://a:1883" || a.Clients != 2 || a.Successes != 190 || a.Failures != 10 || a.Ratio != 0.95 {
+		t.Errorf("node a: %+v", a)
+	}
+	if a.MsgTimeMin != 1 || a.MsgTimeMax != 9 || a.MsgTimeMean != 3 || a.MsgsPerSec != 19 {
+		t.Errorf("node a latency min %v, max %v, mean %v, rate %v, want 1, 9, 3, 19", a.MsgTimeMin, a.MsgTimeMax, a.MsgTimeMean, a.MsgsPerSec)
+	}
+	if b := nodes[1]; b.Broker != "tcp://b:1883" || b.Clients != 1 || b.Ratio != 1 {
+		t.Errorf("node b: %+v", b)
+	}
+	if u := nodes[2]; u.Broker != "unconnected" || u.Clients != 1 || u.Ratio != 0 || u.MsgTimeMean != 0 {
+		t.Errorf("unconnected clients: %+v", u)
+	}
+}
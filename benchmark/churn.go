@@ -0,0 +1,178 @@
+package benchmark
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// ModeChurn repeatedly subscribes to and unsubscribes from topic filters
+// while traffic flows and measures SUBSCRIBE->SUBACK and UNSUBSCRIBE->UNSUBACK latency.
+const ModeChurn = "churn"
+
+const (
+	filterSubscribed = iota
+	filterUnsubscribing
+	filterUnsubscribed
+)
+
+// ChurnSubscriber cycles its subscription through the topics at a configured rate:
+// on every cycle it unsubscribes from the current topic and subscribes to the next one.
+type ChurnSubscriber struct {
+	id          int
+	brokerURL   string
+	brokerUser  string
+	brokerPass  string
+	ids         *clientIDRegistry
+	TopicsCount int
+	MsgQoS      byte
+	Quiet       bool
+	Panic       bool
+
+	// ChurnRate is the number of unsubscribe/subscribe cycles per second.
+	ChurnRate float64
+
+	// CycleCount is the number of cycles to run, 0 to run for TestDuration instead.
+	CycleCount   int
+	TestDuration time.Duration
+
+	// Stop is closed to stop cycling before the test is over, nil if not used.
+	Stop chan bool
+
+	received int64
+	stale    int64
+	mu       sync.Mutex
+	filters  map[string]int
+}
+
+func (c *ChurnSubscriber) ClientId() string {
+	return fmt.Sprintf("churn-%d", c.id)
+}
+
+func (c *ChurnSubscriber) BrokerUrl() string {
+	return c.brokerURL
+}
+
+func (c *ChurnSubscriber) BrokerUser() string {
+	return c.brokerUser
+}
+
+func (c *ChurnSubscriber) BrokerPass() string {
+	return c.brokerPass
+}
+
+func (c *ChurnSubscriber) PanicMode() bool {
+	return c.Panic
+}
+
+func (c *ChurnSubscriber) clientIDs() *clientIDRegistry {
+	return c.ids
+}
+
+func (c *ChurnSubscriber) Run(res chan *RunResults) {
+	runResults := &RunResults{
+		ID: c.ClientId(),
+	}
+	c.filters = make(map[string]int)
+
+	// paho removes the route as soon as UNSUBSCRIBE is sent, so messages for
+	// filters we are no longer subscribed to end up in the default handler.
+	opts := newClientOptions(c, nil).
+		SetDefaultPublishHandler(func(client mqtt.Client, m mqtt.Message) {
+			c.mu.Lock()
+			state, ok := c.filters[m.Topic()]
+			c.mu.Unlock()
+			if ok && state == filterUnsubscribed {
+				atomic.AddInt64(&c.stale, 1)
+			}
+		})
+	client := mqtt.NewClient(opts)
+	start := time.Now()
+	token := client.Connect()
+	token.Wait()
+	if token.Error() != nil {
+		log.Printf("CLIENT %v had error connecting to the broker: %v\n", c.ClientId(), token.Error())
+		if c.Panic {
+			panic(token.Error())
+		}
+		runResults.Failures++
+		runResults.ClientRunTime = time.Since(start).Seconds()
+		res <- runResults
+		return
+	}
+	defer client.Disconnect(250)
+
+	onMessage := func(client mqtt.Client, m mqtt.Message) {
+		atomic.AddInt64(&c.received, 1)
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / c.ChurnRate))
+	defer ticker.Stop()
+	testTimer := time.NewTimer(c.TestDuration)
+
+	current := ""
+loop:
+	for cycle := 0; c.CycleCount == 0 || cycle < c.CycleCount; cycle++ {
+		select {
+		case <-ticker.C:
+		case <-testTimer.C:
+			break loop
+		case <-c.Stop:
+			break loop
+		}
+
+		if current != "" {
+			c.setFilter(current, filterUnsubscribing)
+			sent := time.Now()
+			token := client.Unsubscribe(current)
+			token.Wait()
+			if !c.checkToken(token, runResults) {
+				continue
+			}
+			runResults.UnsubscribeTimes = append(runResults.UnsubscribeTimes, float64(time.Since(sent).Microseconds())/1000)
+			c.setFilter(current, filterUnsubscribed)
+		}
+
+		// every client starts at its own topic, so subscriptions are spread among topics
+		current = fmt.Sprintf("/test%d", (c.id+cycle)%c.TopicsCount)
+		c.setFilter(current, filterSubscribed)
+		sent := time.Now()
+		token := client.Subscribe(current, c.MsgQoS, onMessage)
+		token.Wait()
+		if !c.checkToken(token, runResults) {
+			continue
+		}
+		runResults.SubscribeTimes = append(runResults.SubscribeTimes, float64(time.Since(sent).Microseconds())/1000)
+	}
+
+	if !c.Quiet {
+		log.Printf("CLIENT %v is done churning subscriptions\n", c.ClientId())
+	}
+	runResults.Successes = atomic.LoadInt64(&c.received)
+	runResults.Stale = atomic.LoadInt64(&c.stale)
+	runResults.ClientRunTime = time.Since(start).Seconds()
+	res <- runResults
+}
+
+// checkToken reports a failed subscription operation, returns false on error.
+func (c *ChurnSubscriber) checkToken(token mqtt.Token, runResults *RunResults) bool {
+	if token.Error() == nil {
+		return true
+	}
+	log.Printf("CLIENT %v Error changing subscription: %v\n", c.ClientId(), token.Error())
+	if c.Panic {
+		panic(token.Error())
+	}
+	runResults.Failures++
+	return false
+}
+
+func (c *ChurnSubscriber) setFilter(filter string, state int) {
+	c.mu.Lock()
+	c.filters[filter] = state
+	c.mu.Unlock()
+}
@@ -0,0 +1,32 @@
+package benchmark
+
+import (
+	"testing"
+)
+
+func TestCalculateChurnResults(t *testing.T) {
+	results := []*RunResults{
+		{SubscribeTimes: []float64{3, 1}, UnsubscribeTimes: []float64{2}, Failures: 1, Stale: 4},
+		{SubscribeTimes: []float64{2}, UnsubscribeTimes: []float64{6, 4}},
+		{},
+	}
+	res := calculateChurnResults(results, 2)
+	if res.Subscribes != 3 || res.Unsubscribes != 3 || res.OpFailures != 1 || res.Stale != 4 {
+		t.Errorf("%d subscribes, %d unsubscribes, %d failures, %d stale, want 3, 3, 1, 4",
+			res.Subscribes, res.Unsubscribes, res.OpFailures, res.Stale)
+	}
+	if res.OpsPerSec != 3 {
+		t.Errorf("%v operations per second, want 3", res.OpsPerSec)
+	}
+	if res.SubTimeMin != 1 || res.SubTimeMax != 3 || res.SubTimeMean != 2 || res.SubTimeP50 != 2 {
+		t.Errorf("subscribe time min %v, max %v, mean %v, p50 %v, want 1, 3, 2, 2", res.SubTimeMin, res.SubTimeMax, res.SubTimeMean, res.SubTimeP50)
+	}
+	if res.UnsubTimeMin != 2 || res.UnsubTimeMax != 6 || res.UnsubTimeMean != 4 || res.UnsubTimeP99 != 6 {
+		t.Errorf("unsubscribe time min %v, max %v, mean %v, p99 %v, want 2, 6, 4, 6", res.UnsubTimeMin, res.UnsubTimeMax, res.UnsubTimeMean, res.UnsubTimeP99)
+	}
+
+	empty := calculateChurnResults(nil, 0)
+	if empty.Subscribes != 0 || empty.OpsPerSec != 0 || empty.SubTimeP99 != 0 {
+		t.Errorf("results without operations: %+v", empty)
+	}
+}
@@ -0,0 +1,145 @@
+package benchmark
+
+import (
+	"crypto/tls"
+	"log"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Message describes a message
+type Message struct {
+	Topic     string
+	QoS       byte
+	Payload   interface{}
+	Seq       int
+	Publisher int
+	Sent      time.Time
+	Delivered time.Time
+	Error     bool
+
+	// Disconnected is true if the message failed because the client was disconnected.
+	Disconnected bool
+}
+
+// Client represents a connection information for a publisher or a subscriber
+type Client interface {
+	ClientId() string
+	BrokerUrl() string
+	BrokerUser() string
+	BrokerPass() string
+	Run(res chan *RunResults)
+	PanicMode() bool
+
+	// clientIDs returns the client ids of the run the client belongs to.
+	clientIDs() *clientIDRegistry
+}
+
+func connect(c Client, onConnect func(client mqtt.Client)) mqtt.Client {
+	opts := newClientOptions(c, onConnect)
+
+	var tracker *ConnectionTracker
+	if r, ok := c.(reconnectable); ok {
+		tracker = r.connectionTracker()
+	}
+
+	// clients with a reconnect policy recover from connection loss instead of panicking
+	reconnect := tracker != nil && tracker.Policy.Enabled()
+	if reconnect {
+		opts.SetConnectionLostHandler(func(client mqtt.Client, reason error) {
+			reason = c.clientIDs().lost(client, reason)
+			log.Printf("CLIENT %v lost connection to the broker: %v, reconnecting.\n", c.ClientId(), reason.Error())
+			tracker.reconnect(c, opts, reason)
+		})
+	}
+
+	var client mqtt.Client
+	var token mqtt.Token
+	if tracker != nil {
+		// the tracker records the broker node the client connected to
+		client, token = tracker.connectToAny(opts)
+	} else {
+		client = mqtt.NewClient(opts)
+		token = client.Connect()
+		token.Wait()
+	}
+
+	if token.Error() != nil {
+		log.Printf("CLIENT %v had error connecting to the broker: %v\n", c.ClientId(), token.Error())
+		if reconnect {
+			go tracker.reconnect(c, opts, token.Error())
+		} else if c.PanicMode() {
+			panic(token.Error())
+		}
+	}
+	return client
+}
+
+// newClientOptions creates MQTT client options for the given client.
+func newClientOptions(c Client, onConnect func(client mqtt.Client)) *mqtt.ClientOptions {
+	ids := c.clientIDs()
+	opts := mqtt.NewClientOptions()
+	// multiple brokers are tried in order (failover)
+	for _, broker := range strings.Split(c.BrokerUrl(), ",") {
+		opts.AddBroker(broker)
+	}
+	opts.SetClientID(ids.format(c.ClientId())).
+		SetCleanSession(true).
+		SetAutoReconnect(false).
+		SetPingTimeout(30 * time.Minute).
+		SetOnConnectHandler(func(client mqtt.Client) {
+			ids.connected(client)
+			if onConnect != nil {
+				onConnect(client)
+			}
+		}).
+		SetConnectionLostHandler(func(client mqtt.Client, reason error) {
+			reason = c.clientIDs().lost(client, reason)
+			log.Printf("CLIENT %v lost connection to the broker: %v.\n", c.ClientId(), reason.Error())
+			if c.PanicMode() {
+				panic(reason.Error())
+			}
+		})
+	if c.BrokerUser() != "" && c.BrokerPass() != "" {
+		opts.SetUsername(c.BrokerUser())
+		opts.SetPassword(c.BrokerPass())
+	}
+	if a, ok := c.(authenticated); ok && a.credentials() != nil {
+		a.credentials().apply(opts)
+	}
+	ids.add(opts.ClientID)
+	return opts
+}
+
+// Credentials describe the identity of a single client, overriding the defaults.
+type Credentials struct {
+	ClientID string
+	Username string
+	Password string
+
+	// TLSConfig holds the client certificate and the CA certificates, if any.
+	TLSConfig *tls.Config
+}
+
+// authenticated is implemented by clients that may have their own credentials.
+type authenticated interface {
+	credentials() *Credentials
+}
+
+// apply sets the non-empty credentials to the options.
+func (cr *Credentials) apply(opts *mqtt.ClientOptions) {
+	if cr.ClientID != "" {
+		opts.SetClientID(cr.ClientID)
+	}
+	if cr.Username != "" {
+		opts.SetUsername(cr.Username)
+	}
+	if cr.Password != "" {
+		opts.SetPassword(cr.Password)
+	}
+	if cr.TLSConfig != nil {
+		opts.SetTLSConfig(cr.TLSConfig)
+	}
+}
@@ -0,0 +1,143 @@
+package benchmark
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// DefaultClientIDTemplate creates new client ids for every run.
+	DefaultClientIDTemplate = "mqtt-benchmark-{time}-{role}-{index}"
+
+	// StableClientIDTemplate keeps client ids stable across runs on the same host,
+	// so persistent sessions and ACLs can be reused.
+	StableClientIDTemplate = "mqtt-benchmark-{host}-{role}-{index}"
+
+	// takeoverGracePeriod is how long a lost connection waits for the client that
+	// took over its id to report the connection, as the handlers race each other.
+	takeoverGracePeriod = 100 * time.Millisecond
+)
+
+// ClientIDTemplate formats MQTT client ids from a template with the placeholders:
+// {role} and {index} of the client (e.g. pub and 0), {id} for both (pub-0),
+// {runId}, {host} and {time} (the time the client was created).
+type ClientIDTemplate struct {
+	Template string
+	RunID    string
+	Host     string
+}
+
+// Stable returns true if the template creates the same ids across runs.
+func (t ClientIDTemplate) Stable() bool {
+	return !strings.Contains(t.Template, "{time}")
+}
+
+// format returns the MQTT client id for the client with the given id, e.g. pub-0.
+func (t ClientIDTemplate) format(clientID string) string {
+	role, index := clientID, ""
+	if i := strings.LastIndex(clientID, "-"); i >= 0 {
+		role, index = clientID[:i], clientID[i+1:]
+	}
+	return strings.NewReplacer(
+		"{role}", role,
+		"{index}", index,
+		"{id}", clientID,
+		"{runId}", t.RunID,
+		"{host}", t.Host,
+		"{time}", time.Now().Format(time.RFC3339Nano),
+	).Replace(t.Template)
+}
+
+// ClientIDTakeoverError is the reason of a lost connection when the broker disconnected
+// the client because another client connected with the same client id.
+type ClientIDTakeoverError struct {
+	ClientID string
+	Reason   error
+}
+
+func (e *ClientIDTakeoverError) Error() string {
+	return fmt.Sprintf("client id %v was taken over by another client: %v", e.ClientID, e.Reason)
+}
+
+// clientIDRegistry creates the client ids of a run from the template and tracks their
+// connections to recognize connections lost due to a client id takeover.
+type clientIDRegistry struct {
+	template ClientIDTemplate
+
+	mu sync.Mutex
+
+	// owners is the number of clients created with the id
+	owners map[string]int
+
+	// generations is the number of connections made with the id,
+	// connections is the generation of every connection
+	generations map[string]int
+	connections map[mqtt.Client]int
+
+	takeovers int64
+}
+
+func newClientIDRegistry(template ClientIDTemplate) *clientIDRegistry {
+	return &clientIDRegistry{
+		template:    template,
+		owners:      make(map[string]int),
+		generations: make(map[string]int),
+		connections: make(map[mqtt.Client]int),
+	}
+}
+
+// format returns the MQTT client id for the client with the given id, e.g. pub-0.
+func (r *clientIDRegistry) format(clientID string) string {
+	return r.template.format(clientID)
+}
+
+// add registers a new client with the id.
+func (r *clientIDRegistry) add(id string) {
+	r.mu.Lock()
+	r.owners[id]++
+	r.mu.Unlock()
+}
+
+// connected registers a new connection of the client.
+func (r *clientIDRegistry) connected(client mqtt.Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reader := client.OptionsReader()
+	id := reader.ClientID()
+	r.generations[id]++
+	r.connections[client] = r.generations[id]
+}
+
+// lost returns a ClientIDTakeoverError if the connection of the client was lost because
+// another client connected with the same id after it, otherwise the reason as is.
+func (r *clientIDRegistry) lost(client mqtt.Client, reason error) error {
+	reader := client.OptionsReader()
+	id := reader.ClientID()
+	for attempt := 0; attempt < 2; attempt++ {
+		r.mu.Lock()
+		gen, ok := r.connections[client]
+		takenOver := ok && r.generations[id] > gen
+		shared := r.owners[id] > 1
+		r.mu.Unlock()
+
+		if takenOver {
+			atomic.AddInt64(&r.takeovers, 1)
+			return &ClientIDTakeoverError{ClientID: id, Reason: reason}
+		}
+		if !shared {
+			break
+		}
+		time.Sleep(takeoverGracePeriod)
+	}
+	return reason
+}
+
+// Takeovers returns the number of connections lost due to client id takeovers.
+func (r *clientIDRegistry) Takeovers() int64 {
+	return atomic.LoadInt64(&r.takeovers)
+}
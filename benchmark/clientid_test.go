@@ -0,0 +1,81 @@
+package benchmark
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestClientIDTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		clientID string
+		want     string
+		stable   bool
+	}{
+		{StableClientIDTemplate, "pub-3", "mqtt-benchmark-host1-pub-3", true},
+		{"{runId}/{role}/{index}", "retained-loader-0", "run7/retained-loader/0", true},
+		{"{id}@{host}", "watcher", "watcher@host1", true},
+		{"bench-{role}", "sub-12", "bench-sub", true},
+	}
+	for _, tt := range tests {
+		tmpl := ClientIDTemplate{Template: tt.template, RunID: "run7", Host: "host1"}
+		if got := tmpl.format(tt.clientID); got != tt.want {
+			t.Errorf("%v of %v = %v, want %v", tt.template, tt.clientID, got, tt.want)
+		}
+		if got := tmpl.Stable(); got != tt.stable {
+			t.Errorf("%v stable = %v, want %v", tt.template, got, tt.stable)
+		}
+	}
+
+	tmpl := ClientIDTemplate{Template: DefaultClientIDTemplate}
+	if tmpl.Stable() {
+		t.Error("the default template is stable across runs")
+	}
+	if id := tmpl.format("pub-0"); !strings.HasPrefix(id, "mqtt-benchmark-") || !strings.HasSuffix(id, "-pub-0") || strings.Contains(id, "{time}") {
+		t.Errorf("default client id %v", id)
+	}
+}
+
+func TestClientIDTakeover(t *testing.T) {
+	r := newClientIDRegistry(ClientIDTemplate{Template: StableClientIDTemplate, Host: "host1"})
+	if id := r.format("pub-3"); id != "mqtt-benchmark-host1-pub-3" {
+		t.Errorf("client id %v of the registry template", id)
+	}
+	newClient := func(id string) mqtt.Client {
+		r.add(id)
+		return mqtt.NewClient(mqtt.NewClientOptions().SetClientID(id))
+	}
+	eof := errors.New("EOF")
+
+	// a connection of a unique id is never taken over
+	unique := newClient("unique")
+	r.connected(unique)
+	r.connected(unique)
+	if err := r.lost(unique, eof); err != eof {
+		t.Errorf("lost unique connection: %v, want EOF", err)
+	}
+
+	first, second := newClient("shared"), newClient("shared")
+	r.connected(first)
+	r.connected(second)
+	if err := r.lost(second, eof); err != eof {
+		t.Errorf("lost latest connection: %v, want EOF", err)
+	}
+	err := r.lost(first, eof)
+	if takeover, ok := err.(*ClientIDTakeoverError); !ok || takeover.ClientID != "shared" || takeover.Reason != eof {
+		t.Errorf("lost connection taken over: %v, want a takeover", err)
+	}
+	if r.Takeovers() != 1 {
+		t.Errorf("%d takeovers, want 1", r.Takeovers())
+	}
+
+	// the registry of another run does not share the connections
+	other := newClientIDRegistry(ClientIDTemplate{Template: StableClientIDTemplate})
+	other.add("shared")
+	if err := other.lost(first, eof); err != eof {
+		t.Errorf("lost connection of another run: %v, want EOF", err)
+	}
+}
@@ -0,0 +1,280 @@
+package benchmark
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Comparison statuses of a metric.
+const (
+	StatusUnchanged  = "ok"
+	StatusImproved   = "improved"
+	StatusRegression = "REGRESSION"
+	StatusChanged    = "changed"
+)
+
+// DefaultTolerance is the relative change of a metric tolerated by default.
+const DefaultTolerance = 0.05
+
+// DefaultRatioTolerance is the absolute change of a ratio tolerated by default.
+const DefaultRatioTolerance = 0.001
+
+// Tolerances are the relative changes of the metrics tolerated before a change is flagged.
+// Ratios and lost messages are compared by the absolute change instead, as a relative change
+// hides a significant loss: a ratio of 0.96 is only 4% lower than 1. Ratio is the tolerated
+// change of the ratios, any increase of lost messages is flagged. The tolerance of a single
+// metric is absolute for those metrics.
+type Tolerances struct {
+	Default float64
+	Ratio   float64
+	Metrics map[string]float64
+}
+
+// Set parses a tolerance as {metric}={value} or a default {value}, where value is a
+// fraction (0.05) or a percentage (5%).
+func (t *Tolerances) Set(s string) error {
+	metric, value := "", s
+	if i := strings.Index(s, "="); i >= 0 {
+		metric, value = strings.ToLower(strings.TrimSpace(s[:i])), s[i+1:]
+	}
+	value = strings.TrimSpace(value)
+	scale := 1.0
+	if strings.HasSuffix(value, "%") {
+		value, scale = strings.TrimSuffix(value, "%"), 0.01
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v < 0 {
+		return fmt.Errorf("invalid tolerance: %v", s)
+	}
+	v *= scale
+
+	if metric == "" {
+		t.Default = v
+		return nil
+	}
+	if name, ok := metricAliases[metric]; ok {
+		metric = name
+	}
+	if _, ok := metricField(reflect.TypeOf(TotalResults{}), strings.Split(metric, ".")); !ok {
+		return fmt.Errorf("invalid tolerance %v, unknown metric: %v", s, metric)
+	}
+	if t.Metrics == nil {
+		t.Metrics = make(map[string]float64)
+	}
+	t.Metrics[metric] = v
+	return nil
+}
+
+func (t *Tolerances) String() string {
+	return fmt.Sprintf("%v", t.Default)
+}
+
+func (t *Tolerances) of(metric string) float64 {
+	if v, ok := t.Metrics[metric]; ok {
+		return v
+	}
+	return t.Default
+}
+
+// absolute returns the tolerated absolute change of the ratio and loss metrics,
+// false for the metrics compared by the relative change.
+func (t *Tolerances) absolute(metric string) (float64, bool) {
+	name := metric[strings.LastIndex(metric, ".")+1:]
+	tolerance := 0.0
+	switch {
+	case name == "ratio", strings.HasSuffix(name, "_ratio"), name == "completeness":
+		tolerance = t.Ratio
+	case name == "lost", name == "missing":
+	default:
+		return 0, false
+	}
+	if v, ok := t.Metrics[metric]; ok {
+		tolerance = v
+	}
+	return tolerance, true
+}
+
+// MetricDelta is the change of a metric b/w the baseline and the candidate results.
+type MetricDelta struct {
+	Metric    string
+	Baseline  float64
+	Candidate float64
+	Delta     float64
+
+	// Change is the relative change, +/-Inf if the baseline is 0.
+	Change float64
+	Status string
+}
+
+// Compare computes the deltas of all metrics available in both results. Changes beyond
+// the tolerance are flagged as regressions or improvements, depending on whether the
+// metric is better higher or lower. Changes of test parameters are flagged as changed.
+// Metrics missing in loaded results, e.g. of an older version, are skipped.
+func Compare(baseline, candidate *TotalResults, tolerances Tolerances) []*MetricDelta {
+	var deltas []*MetricDelta
+	for _, metric := range metricNames(reflect.TypeOf(TotalResults{}), "") {
+		if !baseline.has(metric) || !candidate.has(metric) {
+			continue
+		}
+		path := strings.Split(metric, ".")
+		b, ok := metricValue(reflect.ValueOf(baseline), path)
+		if !ok {
+			continue
+		}
+		c, ok := metricValue(reflect.ValueOf(candidate), path)
+		if !ok {
+			continue
+		}
+		d := &MetricDelta{Metric: metric, Baseline: b, Candidate: c, Delta: c - b, Status: StatusUnchanged}
+		switch {
+		case b != 0:
+			d.Change = d.Delta / math.Abs(b)
+		case c > 0:
+			d.Change = math.Inf(1)
+		case c < 0:
+			d.Change = math.Inf(-1)
+		}
+
+		exceeded := math.Abs(d.Change) > tolerances.of(metric)
+		if tolerance, ok := tolerances.absolute(metric); ok {
+			exceeded = math.Abs(d.Delta) > tolerance
+		}
+		if exceeded {
+			switch metricDirection(metric) * math.Copysign(1, d.Delta) {
+			case 1:
+				d.Status = StatusImproved
+			case -1:
+				d.Status = StatusRegression
+			default:
+				d.Status = StatusChanged
+			}
+		}
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
+
+// Regressions returns the number of regressed metrics.
+func Regressions(deltas []*MetricDelta) int {
+	n := 0
+	for _, d := range deltas {
+		if d.Status == StatusRegression {
+			n++
+		}
+	}
+	return n
+}
+
+// metricNames returns the paths of all numeric metrics of the type.
+func metricNames(t reflect.Type, prefix string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonName(f)
+		if name == "" || name == "-" {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		switch ft.Kind() {
+		case reflect.Int, reflect.Int64, reflect.Float64:
+			names = append(names, prefix+name)
+		case reflect.Struct:
+			if ft.PkgPath() == t.PkgPath() && ft != reflect.TypeOf(Histogram{}) {
+				names = append(names, metricNames(ft, prefix+name+".")...)
+			}
+		}
+	}
+	return names
+}
+
+// metricDirection returns 1 if higher values of the metric are better, -1 if lower
+// values are better and 0 for the test parameters and metrics without a direction.
+func metricDirection(metric string) float64 {
+	name := metric[strings.LastIndex(metric, ".")+1:]
+	switch {
+	case name == "ratio", name == "successes", name == "completeness", name == "received",
+		name == "complete_subscribers", name == "responses", name == "echoed", name == "accepted",
+		name == "reconnects", name == "max_concurrent", strings.HasSuffix(name, "_per_sec"):
+		return 1
+	case strings.HasPrefix(name, "num_"), name == "message_size", name == "dop", name == "qos", name == "offline_time",
+		strings.Contains(name, "run_time"), strings.HasPrefix(name, "msg_per_"),
+		strings.HasPrefix(metric, "faults.") && !strings.Contains(metric, "drops"):
+		return 0
+	case strings.Contains(name, "time"), strings.HasPrefix(name, "rtt_"), name == "failures",
+		name == "timeouts", name == "unmatched", name == "unexpected", name == "lost", name == "duplicates", name == "missing",
+		name == "refused", name == "failed", name == "disconnects", name == "disconnected_failures",
+		name == "client_id_takeovers", name == "stale", name == "gini", name == "load_failures",
+		name == "unreported":
+		return -1
+	}
+	return 0
+}
+
+// WriteComparison writes the deltas as a text or markdown table.
+// Unchanged metrics are skipped unless all is set.
+func WriteComparison(w io.Writer, deltas []*MetricDelta, markdown bool, all bool) error {
+	header := []string{"Metric", "Baseline", "Candidate", "Delta", "Change", "Status"}
+	var rows [][]string
+	for _, d := range deltas {
+		if !all && d.Status == StatusUnchanged {
+			continue
+		}
+		change := "-"
+		if d.Delta != 0 {
+			change = fmt.Sprintf("%+.2f%%", d.Change*100)
+		}
+		rows = append(rows, []string{
+			d.Metric,
+			strconv.FormatFloat(d.Baseline, 'f', 3, 64),
+			strconv.FormatFloat(d.Candidate, 'f', 3, 64),
+			fmt.Sprintf("%+.3f", d.Delta),
+			change,
+			d.Status,
+		})
+	}
+
+	if markdown {
+		lines := []string{
+			"| " + strings.Join(header, " | ") + " |",
+			"|" + strings.Repeat(" --- |", len(header)),
+		}
+		for _, r := range rows {
+			lines = append(lines, "| "+strings.Join(r, " | ")+" |")
+		}
+		_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+		return err
+	}
+
+	widths := make([]int, len(header))
+	for _, r := range append([][]string{header}, rows...) {
+		for i, cell := range r {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	for _, r := range append([][]string{header}, rows...) {
+		cells := make([]string, len(r))
+		for i, cell := range r {
+			if i == 0 || i == len(r)-1 {
+				cells[i] = fmt.Sprintf("%-*s", widths[i], cell)
+			} else {
+				cells[i] = fmt.Sprintf("%*s", widths[i], cell)
+			}
+		}
+		if _, err := io.WriteString(w, strings.TrimRight(strings.Join(cells, "  "), " ")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package benchmark
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tolerances := Tolerances{Default: DefaultTolerance, Ratio: DefaultRatioTolerance}
+	tests := []struct {
+		metric    string
+		baseline  *TotalResults
+		candidate *TotalResults
+		status    string
+	}{
+		{"ratio", &TotalResults{Ratio: 1}, &TotalResults{Ratio: 0.96}, StatusRegression},
+		{"ratio", &TotalResults{Ratio: 0.999}, &TotalResults{Ratio: 0.9995}, StatusUnchanged},
+		{"total_msgs_per_sec", &TotalResults{TotalMsgsPerSec: 1000}, &TotalResults{TotalMsgsPerSec: 960}, StatusUnchanged},
+		{"total_msgs_per_sec", &TotalResults{TotalMsgsPerSec: 1000}, &TotalResults{TotalMsgsPerSec: 900}, StatusRegression},
+		{"msg_time_p99", &TotalResults{MsgTimeP99: 10}, &TotalResults{MsgTimeP99: 8}, StatusImproved},
+		{"session.lost", &TotalResults{Session: &SessionResults{Lost: 100}}, &TotalResults{Session: &SessionResults{Lost: 101}}, StatusRegression},
+		{"connect.max_concurrent", &TotalResults{Connect: &ConnectResults{MaxConcurrent: 1000}}, &TotalResults{Connect: &ConnectResults{MaxConcurrent: 800}}, StatusRegression},
+		{"num_clients", &TotalResults{Clients: 10}, &TotalResults{Clients: 20}, StatusChanged},
+	}
+	for _, tt := range tests {
+		var delta *MetricDelta
+		for _, d := range Compare(tt.baseline, tt.candidate, tolerances) {
+			if d.Metric == tt.metric {
+				delta = d
+			}
+		}
+		if delta == nil {
+			t.Errorf("%v: no delta", tt.metric)
+			continue
+		}
+		if delta.Status != tt.status {
+			t.Errorf("%v: %v -> %v is %v, want %v", tt.metric, delta.Baseline, delta.Candidate, delta.Status, tt.status)
+		}
+	}
+}
+
+func TestCompareSkipsMissingMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// results of an older version without the percentiles
+	path := filepath.Join(dir, "baseline.json")
+	data := `{"runs": [], "totals": {"run_type": "pub", "ratio": 1, "msg_time_max": 10}}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := LoadResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candidate := &TotalResults{TestRunType: "pub", Ratio: 1, MsgTimeMax: 10, MsgTimeP99: 9}
+	deltas := Compare(baseline.Totals, candidate, Tolerances{Default: DefaultTolerance, Ratio: DefaultRatioTolerance})
+	if n := Regressions(deltas); n > 0 {
+		t.Errorf("%d regressions, want none", n)
+	}
+	metrics := make(map[string]bool)
+	for _, d := range deltas {
+		metrics[d.Metric] = true
+	}
+	if !metrics["ratio"] || !metrics["msg_time_max"] || metrics["msg_time_p99"] {
+		t.Errorf("compared metrics %v, want ratio and msg_time_max only", metrics)
+	}
+}
@@ -0,0 +1,182 @@
+package benchmark
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// ModeConnect opens (and optionally closes) connections at a target rate
+// and measures CONNECT->CONNACK latency without publishing any messages.
+const ModeConnect = "connect"
+
+// ConnectionStorm coordinates the clients of the connection storm benchmark
+// and tracks the number of concurrent connections held by the broker.
+type ConnectionStorm struct {
+	// Hold is how long established connections are held open after
+	// all connections were attempted.
+	Hold time.Duration
+
+	// Close is true when each connection is closed right after CONNACK.
+	Close bool
+
+	current  int64
+	max      int64
+	attempts sync.WaitGroup
+	release  chan bool
+
+	// started and attempted are the start and the end of the connect phase
+	started   time.Time
+	attempted time.Time
+}
+
+// NewConnectionStorm creates a connection storm for the given number of connections.
+func NewConnectionStorm(connections int, hold time.Duration, closeEach bool) *ConnectionStorm {
+	s := &ConnectionStorm{
+		Hold:    hold,
+		Close:   closeEach,
+		release: make(chan bool),
+		started: time.Now(),
+	}
+	s.attempts.Add(connections)
+	go func() {
+		s.attempts.Wait()
+		s.attempted = time.Now()
+		time.Sleep(s.Hold)
+		close(s.release)
+	}()
+	return s
+}
+
+// Skip ends the given number of connections without attempting them,
+// so the connect phase is over once the started connections were attempted.
+func (s *ConnectionStorm) Skip(connections int) {
+	for i := 0; i < connections; i++ {
+		s.attempts.Done()
+	}
+}
+
+// MaxConcurrent returns the max number of connections held at the same time.
+func (s *ConnectionStorm) MaxConcurrent() int64 {
+	return atomic.LoadInt64(&s.max)
+}
+
+// ConnectPhase returns the time it took to attempt all connections.
+// It blocks until the connections are released.
+func (s *ConnectionStorm) ConnectPhase() time.Duration {
+	<-s.release
+	return s.attempted.Sub(s.started)
+}
+
+func (s *ConnectionStorm) connected() {
+	n := atomic.AddInt64(&s.current, 1)
+	for {
+		max := atomic.LoadInt64(&s.max)
+		if n <= max || atomic.CompareAndSwapInt64(&s.max, max, n) {
+			return
+		}
+	}
+}
+
+func (s *ConnectionStorm) disconnected() {
+	atomic.AddInt64(&s.current, -1)
+}
+
+// ConnectClient opens a single connection as part of a connection storm.
+type ConnectClient struct {
+	id         int
+	brokerURL  string
+	brokerUser string
+	brokerPass string
+	ids        *clientIDRegistry
+	Storm      *ConnectionStorm
+	Quiet      bool
+	Panic      bool
+}
+
+func (c ConnectClient) ClientId() string {
+	return fmt.Sprintf("conn-%d", c.id)
+}
+
+func (c ConnectClient) BrokerUrl() string {
+	return c.brokerURL
+}
+
+func (c ConnectClient) BrokerUser() string {
+	return c.brokerUser
+}
+
+func (c ConnectClient) BrokerPass() string {
+	return c.brokerPass
+}
+
+func (c ConnectClient) PanicMode() bool {
+	return c.Panic
+}
+
+func (c ConnectClient) clientIDs() *clientIDRegistry {
+	return c.ids
+}
+
+func (c ConnectClient) Run(res chan *RunResults) {
+	runResults := &RunResults{
+		ID: c.ClientId(),
+	}
+
+	// closed flags that the connection was either lost or closed, so it is not counted twice
+	var closed int32
+	// MQTT 3.1.1 is pinned, otherwise a refused connection is retried with MQTT 3.1,
+	// sending a second CONNECT and reporting the return code of the retry
+	opts := newClientOptions(c, nil).
+		SetProtocolVersion(4).
+		SetConnectionLostHandler(func(client mqtt.Client, reason error) {
+			if atomic.CompareAndSwapInt32(&closed, 0, 1) {
+				c.Storm.disconnected()
+			}
+			log.Printf("CLIENT %v lost connection to the broker: %v.\n", c.ClientId(), reason.Error())
+			if c.Panic {
+				panic(reason.Error())
+			}
+		})
+	client := mqtt.NewClient(opts)
+
+	start := time.Now()
+	token := client.Connect()
+	token.Wait()
+	connectTime := time.Since(start)
+	c.Storm.attempts.Done()
+
+	if token.Error() != nil {
+		rc := token.(*mqtt.ConnectToken).ReturnCode()
+		if rc != packets.ErrNetworkError {
+			runResults.Refused++
+		}
+		runResults.Failures++
+		log.Printf("CLIENT %v had error connecting to the broker: %v\n", c.ClientId(), token.Error())
+		if c.Panic {
+			panic(token.Error())
+		}
+		runResults.ClientRunTime = connectTime.Seconds()
+		res <- runResults
+		return
+	}
+
+	c.Storm.connected()
+	runResults.Successes++
+	runResults.ConnectTime = float64(connectTime.Microseconds()) / 1000 // in milliseconds
+
+	if !c.Storm.Close {
+		<-c.Storm.release
+	}
+	if atomic.CompareAndSwapInt32(&closed, 0, 1) {
+		client.Disconnect(250)
+		c.Storm.disconnected()
+	}
+	runResults.ClientRunTime = time.Since(start).Seconds()
+	res <- runResults
+}
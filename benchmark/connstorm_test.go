@@ -0,0 +1,96 @@
+package benchmark
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConnectionStormMaxConcurrent(t *testing.T) {
+	s := NewConnectionStorm(0, 0, false)
+	s.connected()
+	s.connected()
+	s.disconnected()
+	s.connected()
+	s.connected()
+	s.disconnected()
+	s.disconnected()
+	if got := s.MaxConcurrent(); got != 3 {
+		t.Errorf("max concurrent %d, want 3", got)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.connected()
+		}()
+	}
+	wg.Wait()
+	if got := s.MaxConcurrent(); got != 101 {
+		t.Errorf("max concurrent %d after 100 concurrent connections, want 101", got)
+	}
+}
+
+func TestConnectionStormRelease(t *testing.T) {
+	s := NewConnectionStorm(2, 10*time.Millisecond, false)
+	s.attempts.Done()
+	s.attempts.Done()
+
+	released := make(chan time.Duration)
+	go func() { released <- s.ConnectPhase() }()
+	select {
+	case phase := <-released:
+		if phase < 0 {
+			t.Errorf("connect phase %v", phase)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connections not released after the hold time")
+	}
+}
+
+func TestCalculateConnectResults(t *testing.T) {
+	results := []*RunResults{
+		{Successes: 1, ConnectTime: 4},
+		{Successes: 1, ConnectTime: 2},
+		{Successes: 1, ConnectTime: 9},
+		{Failures: 1, Refused: 1},
+		{Failures: 1},
+	}
+	res := calculateConnectResults(results, 3, 0.5)
+	if res.Attempted != 5 || res.Accepted != 3 || res.Refused != 1 || res.Failed != 1 || res.MaxConcurrent != 3 {
+		t.Errorf("attempted %d, accepted %d, refused %d, failed %d, max %d, want 5, 3, 1, 1, 3",
+			res.Attempted, res.Accepted, res.Refused, res.Failed, res.MaxConcurrent)
+	}
+	if res.ConnectsPerSec != 6 {
+		t.Errorf("%v connects per second, want 6", res.ConnectsPerSec)
+	}
+	if res.ConnectTimeMin != 2 || res.ConnectTimeMax != 9 || res.ConnectTimeMean != 5 || res.ConnectTimeP50 != 4 || res.ConnectTimeP99 != 9 {
+		t.Errorf("connect time min %v, max %v, mean %v, p50 %v, p99 %v, want 2, 9, 5, 4, 9",
+			res.ConnectTimeMin, res.ConnectTimeMax, res.ConnectTimeMean, res.ConnectTimeP50, res.ConnectTimeP99)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		p, want float64
+	}{
+		{0, 1},
+		{10, 1},
+		{11, 2},
+		{50, 5},
+		{90, 9},
+		{99, 10},
+		{100, 10},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile of an empty sample = %v, want 0", got)
+	}
+}
@@ -0,0 +1,158 @@
+package benchmark
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// CredentialsRoundRobin assigns the credential sets to clients in turn.
+	CredentialsRoundRobin = "roundrobin"
+	// CredentialsOneToOne assigns a dedicated credential set to every client.
+	CredentialsOneToOne = "onetoone"
+)
+
+func isValidCredentialsAssign(assign string) bool {
+	return assign == CredentialsRoundRobin || assign == CredentialsOneToOne
+}
+
+// credentialsEntry describes a credential set in the credentials file.
+// Empty fields keep the defaults of the client.
+type credentialsEntry struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	ClientID string `json:"client_id"`
+
+	// Cert and Key are the paths of the client certificate and its private key,
+	// CA is the path of the CA certificates to verify the broker with.
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+	CA   string `json:"ca"`
+}
+
+// loadCredentials loads the credential sets from a JSON (array of objects) or
+// CSV (with a header row) file. The sets are in the order of client indexes.
+// Relative certificate paths are relative to the file.
+func loadCredentials(path string) ([]*Credentials, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []credentialsEntry
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("invalid credentials file %v: %v", path, err)
+		}
+	} else {
+		entries, err = parseCredentialsCSV(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid credentials file %v: %v", path, err)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no credentials in %v", path)
+	}
+
+	dir := filepath.Dir(path)
+	result := make([]*Credentials, len(entries))
+	for i, e := range entries {
+		cred := &Credentials{
+			ClientID: e.ClientID,
+			Username: e.Username,
+			Password: e.Password,
+		}
+		if e.Cert != "" || e.CA != "" {
+			cred.TLSConfig, err = newTLSConfig(resolvePath(dir, e.Cert), resolvePath(dir, e.Key), resolvePath(dir, e.CA))
+			if err != nil {
+				return nil, fmt.Errorf("invalid credentials #%d: %v", i, err)
+			}
+		}
+		result[i] = cred
+	}
+	return result, nil
+}
+
+// parseCredentialsCSV parses the CSV file, the header row names the columns.
+func parseCredentialsCSV(data string) ([]credentialsEntry, error) {
+	rows, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	value := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	entries := make([]credentialsEntry, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		entries = append(entries, credentialsEntry{
+			Username: value(row, "username"),
+			Password: value(row, "password"),
+			ClientID: value(row, "client_id"),
+			Cert:     value(row, "cert"),
+			Key:      value(row, "key"),
+			CA:       value(row, "ca"),
+		})
+	}
+	return entries, nil
+}
+
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// newTLSConfig creates a TLS config with the client certificate and/or the CA certificates.
+func newTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates in %v", caFile)
+		}
+	}
+	return config, nil
+}
+
+// assignCredentials returns the credential set of the i-th client.
+func assignCredentials(credentials []*Credentials, assign string, i int) *Credentials {
+	if assign == CredentialsOneToOne {
+		return credentials[i]
+	}
+	return credentials[i%len(credentials)]
+}
+
+// checkCredentials verifies there are enough credential sets for the clients.
+func checkCredentials(credentials []*Credentials, assign string, clients int) error {
+	if assign == CredentialsOneToOne && len(credentials) < clients {
+		return fmt.Errorf("one to one credentials require a set per client, given %v sets for %v clients", len(credentials), clients)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package benchmark
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"creds.csv": "Username, password,client_id\ndevice-1,secret-1,dev-1\ndevice-2, secret-2,\n",
+		"creds.JSON": `[{"username": "device-1", "password": "secret-1", "client_id": "dev-1"},
+			{"username": "device-2", "password": "secret-2"}]`,
+		"empty.csv":   "username,password\n",
+		"broken.json": `{"username": "device-1"}`,
+		"ca.csv":      "username,ca\ndevice-1,ca.pem\n",
+		"ca.pem":      "not a certificate",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"creds.csv", "creds.JSON"} {
+		creds, err := loadCredentials(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if len(creds) != 2 {
+			t.Fatalf("%v: %d credential sets, want 2", name, len(creds))
+		}
+		if c := creds[0]; c.Username != "device-1" || c.Password != "secret-1" || c.ClientID != "dev-1" || c.TLSConfig != nil {
+			t.Errorf("%v: first set %+v", name, c)
+		}
+		if c := creds[1]; c.Username != "device-2" || c.Password != "secret-2" || c.ClientID != "" {
+			t.Errorf("%v: second set %+v, want the default client id", name, c)
+		}
+	}
+
+	// the CA path is relative to the file, but the file has no certificates
+	for _, name := range []string{"empty.csv", "broken.json", "ca.csv", "missing.csv"} {
+		if _, err := loadCredentials(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%v: loaded credentials", name)
+		}
+	}
+}
+
+func TestAssignCredentials(t *testing.T) {
+	creds := []*Credentials{{Username: "a"}, {Username: "b"}, {Username: "c"}}
+	tests := []struct {
+		assign string
+		i      int
+		want   string
+	}{
+		{CredentialsRoundRobin, 1, "b"},
+		{CredentialsRoundRobin, 4, "b"},
+		{CredentialsOneToOne, 2, "c"},
+	}
+	for _, tt := range tests {
+		if got := assignCredentials(creds, tt.assign, tt.i); got.Username != tt.want {
+			t.Errorf("%v credentials of client %d = %v, want %v", tt.assign, tt.i, got.Username, tt.want)
+		}
+	}
+
+	checks := []struct {
+		assign  string
+		clients int
+		valid   bool
+	}{
+		{CredentialsRoundRobin, 10, true},
+		{CredentialsOneToOne, 3, true},
+		{CredentialsOneToOne, 4, false},
+	}
+	for _, tt := range checks {
+		if err := checkCredentials(creds, tt.assign, tt.clients); (err == nil) != tt.valid {
+			t.Errorf("%v with %d clients: error %v", tt.assign, tt.clients, err)
+		}
+	}
+	for assign, want := range map[string]bool{CredentialsRoundRobin: true, CredentialsOneToOne: true, "random": false} {
+		if got := isValidCredentialsAssign(assign); got != want {
+			t.Errorf("isValidCredentialsAssign(%v) = %v", assign, got)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package benchmark
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Formats of the message export.
+const (
+	ExportJSONL = "jsonl"
+	ExportCSV   = "csv"
+)
+
+// DefaultExportBuffer is the number of message events buffered by default.
+const DefaultExportBuffer = 100000
+
+var exportHeader = []string{"run", "client", "topic", "seq", "sent", "acked", "received", "latency_ms", "error"}
+
+// exportRecord is a message event as written to the export.
+type exportRecord struct {
+	Run      int32   `json:"run"`
+	Client   string  `json:"client"`
+	Topic    string  `json:"topic"`
+	Seq      int     `json:"seq"`
+	Sent     string  `json:"sent,omitempty"`
+	Acked    string  `json:"acked,omitempty"`
+	Received string  `json:"received,omitempty"`
+	Latency  float64 `json:"latency_ms"`
+	Error    bool    `json:"error"`
+}
+
+// MessageExporter streams message events to a JSONL or CSV file, gzip compressed if the
+// file name ends with .gz. Events are buffered in a bounded queue and written in the
+// background. While the queue is full, the clients are blocked until the file catches up,
+// so no event is lost, unless Drop is set.
+type MessageExporter struct {
+	// Drop is true to drop the events arriving while the queue is full instead of blocking
+	// the clients, so the export does not slow down the test. Dropped events are counted.
+	Drop bool
+
+	path   string
+	format string
+
+	file *os.File
+	gz   *gzip.Writer
+	w    *bufio.Writer
+
+	run     int32
+	dropped int64
+	written int64
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan exportRecord
+	done   chan error
+}
+
+// NewMessageExporter creates the export file. The format is derived from the file name,
+// e.g. messages.jsonl.gz or messages.csv, buffer is the capacity of the queue.
+func NewMessageExporter(path string, buffer int) (*MessageExporter, error) {
+	format := exportFormat(path)
+	if format == "" {
+		return nil, fmt.Errorf("unsupported export file %v, expected .jsonl or .csv, optionally with .gz", path)
+	}
+	if buffer < 1 {
+		return nil, fmt.Errorf("export buffer should be >= 1, given: %v", buffer)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &MessageExporter{
+		path:   path,
+		format: format,
+		file:   f,
+		run:    1,
+		queue:  make(chan exportRecord, buffer),
+		done:   make(chan error, 1),
+	}
+	var w io.Writer = f
+	if strings.HasSuffix(path, ".gz") {
+		e.gz = gzip.NewWriter(f)
+		w = e.gz
+	}
+	e.w = bufio.NewWriterSize(w, 64*1024)
+	go e.write()
+	return e, nil
+}
+
+func exportFormat(path string) string {
+	switch ext := strings.TrimSuffix(path, ".gz"); {
+	case strings.HasSuffix(ext, ".jsonl"), strings.HasSuffix(ext, ".json"):
+		return ExportJSONL
+	case strings.HasSuffix(ext, ".csv"):
+		return ExportCSV
+	}
+	return ""
+}
+
+// SetRun sets the run number of the following events, for repeated runs.
+func (e *MessageExporter) SetRun(run int) {
+	atomic.StoreInt32(&e.run, int32(run))
+}
+
+// Add queues the event, it is the OnMessage hook of the runner.
+func (e *MessageExporter) Add(m MessageEvent) {
+	r := exportRecord{
+		Run:      atomic.LoadInt32(&e.run),
+		Client:   m.ClientID,
+		Topic:    m.Topic,
+		Seq:      m.Seq,
+		Sent:     exportTime(m.Sent),
+		Acked:    exportTime(m.Acked),
+		Received: exportTime(m.Received),
+		Latency:  float64(m.Latency.Microseconds()) / 1000,
+		Error:    m.Error,
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		atomic.AddInt64(&e.dropped, 1)
+		return
+	}
+	if !e.Drop {
+		e.queue <- r
+		return
+	}
+	select {
+	case e.queue <- r:
+	default:
+		if atomic.AddInt64(&e.dropped, 1) == 1 {
+			log.Printf("Export buffer of %d message events is full, dropping events", cap(e.queue))
+		}
+	}
+}
+
+func exportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func (e *MessageExporter) write() {
+	var err error
+	var enc *json.Encoder
+	var cw *csv.Writer
+	if e.format == ExportJSONL {
+		enc = json.NewEncoder(e.w)
+	} else {
+		cw = csv.NewWriter(e.w)
+		err = cw.Write(exportHeader)
+	}
+
+	for r := range e.queue {
+		if err != nil {
+			// keep draining the queue, so Add does not block on a failed export
+			continue
+		}
+		if enc != nil {
+			err = enc.Encode(r)
+		} else {
+			err = cw.Write([]string{
+				strconv.Itoa(int(r.Run)),
+				r.Client,
+				r.Topic,
+				strconv.Itoa(r.Seq),
+				r.Sent,
+				r.Acked,
+				r.Received,
+				strconv.FormatFloat(r.Latency, 'f', 3, 64),
+				strconv.FormatBool(r.Error),
+			})
+		}
+		if err == nil {
+			e.written++
+		}
+	}
+
+	if cw != nil && err == nil {
+		cw.Flush()
+		err = cw.Error()
+	}
+	e.done <- err
+}
+
+// Close writes the queued events and closes the file. Events added afterwards are dropped.
+func (e *MessageExporter) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	close(e.queue)
+	e.mu.Unlock()
+
+	err := <-e.done
+	if ferr := e.w.Flush(); err == nil {
+		err = ferr
+	}
+	if e.gz != nil {
+		if gerr := e.gz.Close(); err == nil {
+			err = gerr
+		}
+	}
+	if cerr := e.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Written returns the number of exported events, valid after Close.
+func (e *MessageExporter) Written() int64 {
+	return e.written
+}
+
+// Dropped returns the number of events dropped because the queue was full or the exporter was closed.
+func (e *MessageExporter) Dropped() int64 {
+	return atomic.LoadInt64(&e.dropped)
+}
@@ -0,0 +1,186 @@
+package benchmark
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportFormat(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"messages.jsonl", ExportJSONL},
+		{"messages.json.gz", ExportJSONL},
+		{"out/messages.csv", ExportCSV},
+		{"messages.csv.gz", ExportCSV},
+		{"messages.txt", ""},
+		{"messages.gz", ""},
+	}
+	for _, tt := range tests {
+		if got := exportFormat(tt.path); got != tt.want {
+			t.Errorf("exportFormat(%v) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMessageExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sent := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	events := []MessageEvent{
+		{ClientID: "pub-0", Topic: "/test0", Seq: 0, Sent: sent, Acked: sent.Add(2 * time.Millisecond), Latency: 2 * time.Millisecond},
+		{ClientID: "sub-0", Topic: "/test0", Seq: 0, Sent: sent, Received: sent.Add(5 * time.Millisecond), Latency: 5 * time.Millisecond},
+		{ClientID: "pub-1", Topic: "/test1", Seq: 1, Error: true},
+	}
+
+	for _, name := range []string{"messages.jsonl", "messages.csv.gz"} {
+		path := filepath.Join(dir, name)
+		e, err := NewMessageExporter(path, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Add(events[0])
+		e.SetRun(2)
+		e.Add(events[1])
+		e.Add(events[2])
+		if err := e.Close(); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		// events after close are dropped, closing again is a no-op
+		e.Add(events[0])
+		if err := e.Close(); err != nil {
+			t.Errorf("%v: closed again: %v", name, err)
+		}
+		if e.Written() != 3 || e.Dropped() != 1 {
+			t.Errorf("%v: %d written, %d dropped, want 3 and 1", name, e.Written(), e.Dropped())
+		}
+
+		records := readExport(t, path)
+		if len(records) != 3 {
+			t.Fatalf("%v: %d records, want 3", name, len(records))
+		}
+		want := []exportRecord{
+			{Run: 1, Client: "pub-0", Topic: "/test0", Sent: "2020-01-02T03:04:05Z", Acked: "2020-01-02T03:04:05.002Z", Latency: 2},
+			{Run: 2, Client: "sub-0", Topic: "/test0", Sent: "2020-01-02T03:04:05Z", Received: "2020-01-02T03:04:05.005Z", Latency: 5},
+			{Run: 2, Client: "pub-1", Topic: "/test1", Seq: 1, Error: true},
+		}
+		for i, r := range records {
+			if r != want[i] {
+				t.Errorf("%v: record %d %+v, want %+v", name, i, r, want[i])
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		path   string
+		buffer int
+	}{{filepath.Join(dir, "messages.txt"), 10}, {filepath.Join(dir, "messages.csv"), 0}} {
+		if _, err := NewMessageExporter(tt.path, tt.buffer); err == nil {
+			t.Errorf("created exporter of %v with buffer %d", tt.path, tt.buffer)
+		}
+	}
+}
+
+// readExport reads the records of a JSONL or CSV export.
+func readExport(t *testing.T, path string) []exportRecord {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		if r, err = gzip.NewReader(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var records []exportRecord
+	if exportFormat(path) == ExportJSONL {
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			var rec exportRecord
+			if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, rec)
+		}
+		return records
+	}
+
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rows[0], ",") != strings.Join(exportHeader, ",") {
+		t.Errorf("CSV header %v", rows[0])
+	}
+	for _, row := range rows[1:] {
+		run, _ := strconv.Atoi(row[0])
+		seq, _ := strconv.Atoi(row[3])
+		latency, _ := strconv.ParseFloat(row[7], 64)
+		failed, _ := strconv.ParseBool(row[8])
+		records = append(records, exportRecord{
+			Run:      int32(run),
+			Client:   row[1],
+			Topic:    row[2],
+			Seq:      seq,
+			Sent:     row[4],
+			Acked:    row[5],
+			Received: row[6],
+			Latency:  latency,
+			Error:    failed,
+		})
+	}
+	return records
+}
+
+func TestMessageExporterFullQueue(t *testing.T) {
+	// without the writer, the queue fills up after two events
+	dropping := &MessageExporter{Drop: true, queue: make(chan exportRecord, 2)}
+	for i := 0; i < 5; i++ {
+		dropping.Add(MessageEvent{ClientID: "pub-0", Seq: i})
+	}
+	if len(dropping.queue) != 2 || dropping.Dropped() != 3 {
+		t.Errorf("%d queued, %d dropped, want 2 and 3", len(dropping.queue), dropping.Dropped())
+	}
+
+	blocking := &MessageExporter{queue: make(chan exportRecord, 1)}
+	blocking.Add(MessageEvent{ClientID: "pub-0", Seq: 0})
+	added := make(chan bool)
+	go func() {
+		blocking.Add(MessageEvent{ClientID: "pub-0", Seq: 1})
+		close(added)
+	}()
+	select {
+	case <-added:
+		t.Fatal("event added to the full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if r := <-blocking.queue; r.Seq != 0 {
+		t.Errorf("first queued event %d, want 0", r.Seq)
+	}
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not added after the queue was drained")
+	}
+	if r := <-blocking.queue; r.Seq != 1 || blocking.Dropped() != 0 {
+		t.Errorf("queued event %d, %d dropped, want 1 and none dropped", r.Seq, blocking.Dropped())
+	}
+}
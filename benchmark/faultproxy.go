@@ -0,0 +1,418 @@
+package benchmark
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// faultChunkSize is the max number of bytes forwarded at once.
+	faultChunkSize = 4096
+
+	// faultRetransmitTimeout is the delay of a dropped chunk. The proxy runs on top of TCP,
+	// which retransmits lost segments, so a drop shows up as a delay rather than lost data.
+	faultRetransmitTimeout = 200 * time.Millisecond
+
+	// faultConnectSize is the max number of bytes read from a client to find the client id
+	// in its CONNECT packet.
+	faultConnectSize = 4096
+)
+
+// NetworkConditions describes the faults injected by the proxy b/w clients and the broker.
+// All conditions apply to each direction of each connection independently.
+type NetworkConditions struct {
+	// Latency is the delay added to every chunk of data, Jitter is the max random
+	// deviation (+/-) from it. Data is still delivered in order.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// Bandwidth is the max throughput in bytes/sec, 0 for unlimited.
+	Bandwidth int64
+
+	// DropRate is the probability of dropping a chunk of data, which is then
+	// delivered after faultRetransmitTimeout.
+	DropRate float64
+
+	// ResetInterval is the interval of resetting connections, every connection
+	// is reset with the ResetRatio probability. 0 to disable resets.
+	ResetInterval time.Duration
+	ResetRatio    float64
+
+	// Seed is the seed of the random generator, so faults are reproducible.
+	Seed int64
+}
+
+// Enabled returns true if any fault is injected.
+func (n NetworkConditions) Enabled() bool {
+	return n.Latency > 0 || n.Jitter > 0 || n.Bandwidth > 0 || n.DropRate > 0 || n.ResetInterval > 0
+}
+
+// FaultProxy forwards TCP connections from clients to a single broker and injects faults.
+type FaultProxy struct {
+	Upstream   string
+	Conditions NetworkConditions
+
+	listener  net.Listener
+	upstream  string // host:port
+	websocket bool
+
+	mu    sync.Mutex
+	rnd   *rand.Rand
+	conns map[*faultConn]bool
+
+	connections int64
+	resets      int64
+	drops       int64
+	bytesUp     int64
+	bytesDown   int64
+
+	stop chan bool
+	wg   sync.WaitGroup
+}
+
+// NewFaultProxy starts a proxy on a local port for the given broker endpoint.
+// Only plain TCP and websocket endpoints are supported, as TLS would not verify the proxy address.
+func NewFaultProxy(broker string, conditions NetworkConditions) (*FaultProxy, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt", "ws":
+	default:
+		return nil, fmt.Errorf("fault injection does not support %v brokers: %v", u.Scheme, broker)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &FaultProxy{
+		Upstream:   broker,
+		Conditions: conditions,
+		listener:   l,
+		upstream:   u.Host,
+		websocket:  u.Scheme == "ws",
+		rnd:        rand.New(rand.NewSource(conditions.Seed)),
+		conns:      make(map[*faultConn]bool),
+		stop:       make(chan bool),
+	}
+	go p.accept()
+	if conditions.ResetInterval > 0 {
+		go p.resetConnections()
+	}
+	return p, nil
+}
+
+// URL returns the broker endpoint that routes through the proxy.
+func (p *FaultProxy) URL() string {
+	u, _ := url.Parse(p.Upstream)
+	u.Host = p.listener.Addr().String()
+	return u.String()
+}
+
+// addStats adds the number of proxied connections, injected faults and forwarded bytes to the results.
+func (p *FaultProxy) addStats(r *FaultResults) {
+	r.Connections += atomic.LoadInt64(&p.connections)
+	r.Resets += atomic.LoadInt64(&p.resets)
+	r.Drops += atomic.LoadInt64(&p.drops)
+	r.BytesUp += atomic.LoadInt64(&p.bytesUp)
+	r.BytesDown += atomic.LoadInt64(&p.bytesDown)
+}
+
+// Close stops accepting connections and closes the open ones.
+func (p *FaultProxy) Close() {
+	close(p.stop)
+	p.listener.Close()
+	p.mu.Lock()
+	for c := range p.conns {
+		c.close(false)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *FaultProxy) accept() {
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			return // closed
+		}
+		server, err := net.Dial("tcp", p.upstream)
+		if err != nil {
+			log.Printf("PROXY error connecting to the broker %v: %v\n", p.upstream, err)
+			client.Close()
+			continue
+		}
+		seq := atomic.AddInt64(&p.connections, 1)
+
+		c := &faultConn{client: client.(*net.TCPConn), server: server.(*net.TCPConn), seq: seq}
+		p.mu.Lock()
+		p.conns[c] = true
+		p.mu.Unlock()
+
+		p.wg.Add(2)
+		go p.forward(c, c.server, c.client, &p.bytesUp)
+		go p.forward(c, c.client, c.server, &p.bytesDown)
+	}
+}
+
+// forward copies data from src to dst, delaying every chunk according to the conditions.
+// The connection is closed as soon as either side closes it.
+func (p *FaultProxy) forward(c *faultConn, dst, src *net.TCPConn, forwarded *int64) {
+	defer p.wg.Done()
+	defer func() {
+		c.close(false)
+		p.mu.Lock()
+		delete(p.conns, c)
+		p.mu.Unlock()
+	}()
+
+	type chunk struct {
+		data []byte
+		due  time.Time
+	}
+	chunks := make(chan chunk, 64)
+
+	// the reader schedules chunks, the writer delivers them in order when they are due
+	go func() {
+		defer close(chunks)
+		var last time.Time
+		// the client id is read from the CONNECT packet, which the client sends first
+		var connect []byte
+		sniff := src == c.client
+		for {
+			buf := make([]byte, faultChunkSize)
+			n, err := src.Read(buf)
+			if n > 0 && sniff {
+				connect = append(connect, buf[:n]...)
+				if id, ok := readConnectClientID(connect, p.websocket); ok {
+					p.mu.Lock()
+					c.clientID = id
+					p.mu.Unlock()
+					sniff, connect = false, nil
+				} else if len(connect) >= faultConnectSize {
+					sniff, connect = false, nil
+				}
+			}
+			if n > 0 {
+				due := time.Now().Add(p.delay())
+				if due.Before(last) {
+					due = last
+				}
+				// with a bandwidth cap chunks queue up behind each other
+				if p.Conditions.Bandwidth > 0 {
+					due = due.Add(time.Duration(int64(n) * int64(time.Second) / p.Conditions.Bandwidth))
+				}
+				last = due
+				chunks <- chunk{data: buf[:n], due: due}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for ch := range chunks {
+		time.Sleep(time.Until(ch.due))
+		if atomic.LoadInt32(&c.silenced) == 1 {
+			continue
+		}
+		if _, err := dst.Write(ch.data); err != nil {
+			// unblock and drain the reader
+			c.close(false)
+			for range chunks {
+			}
+			return
+		}
+		atomic.AddInt64(forwarded, int64(len(ch.data)))
+	}
+}
+
+// delay returns the latency of a chunk, including jitter and drops.
+func (p *FaultProxy) delay() time.Duration {
+	cond := p.Conditions
+	delay := cond.Latency
+
+	p.mu.Lock()
+	if cond.Jitter > 0 {
+		delay += time.Duration(p.rnd.Int63n(int64(2*cond.Jitter))) - cond.Jitter
+	}
+	if cond.DropRate > 0 && p.rnd.Float64() < cond.DropRate {
+		atomic.AddInt64(&p.drops, 1)
+		delay += faultRetransmitTimeout
+	}
+	p.mu.Unlock()
+
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+// resetConnections resets random connections on schedule.
+func (p *FaultProxy) resetConnections() {
+	ticker := time.NewTicker(p.Conditions.ResetInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+		p.mu.Lock()
+		for c := range p.conns {
+			if p.rnd.Float64() < p.Conditions.ResetRatio {
+				atomic.AddInt64(&p.resets, 1)
+				c.close(true)
+				delete(p.conns, c)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// kill abruptly kills the given ratio of open connections, chosen at random. The connections
+// are either reset with TCP RST or silenced: kept open, but all data is dropped, so that
+// only keepalive can detect them. Returns the client ids of the killed connections, empty
+// for the connections without a CONNECT packet.
+func (p *FaultProxy) kill(ratio float64, silent bool) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conns := make([]*faultConn, 0, len(p.conns))
+	for c := range p.conns {
+		conns = append(conns, c)
+	}
+	// map order is random, shuffle in accept order so the choice is reproducible
+	sort.Slice(conns, func(i, j int) bool { return conns[i].seq < conns[j].seq })
+	p.rnd.Shuffle(len(conns), func(i, j int) { conns[i], conns[j] = conns[j], conns[i] })
+	killed := make([]string, 0, int(math.Round(ratio*float64(len(conns)))))
+	for _, c := range conns[:cap(killed)] {
+		killed = append(killed, c.clientID)
+		if silent {
+			atomic.StoreInt32(&c.silenced, 1)
+			continue
+		}
+		atomic.AddInt64(&p.resets, 1)
+		c.close(true)
+		delete(p.conns, c)
+	}
+	return killed
+}
+
+// faultConn is a client connection forwarded to the broker.
+type faultConn struct {
+	client *net.TCPConn
+	server *net.TCPConn
+	once   sync.Once
+
+	// seq is the order in which the connection was accepted
+	seq int64
+
+	// silenced is set to 1 when all data of the connection is dropped
+	silenced int32
+
+	// clientID is the client id of the CONNECT packet sent through the connection,
+	// guarded by the mutex of the proxy
+	clientID string
+}
+
+// close closes both sides of the connection, with a TCP RST instead of FIN if reset is true.
+func (c *faultConn) close(reset bool) {
+	c.once.Do(func() {
+		if reset {
+			c.client.SetLinger(0)
+			c.server.SetLinger(0)
+		}
+		c.client.Close()
+		c.server.Close()
+	})
+}
+
+// readConnectClientID reads the client id of the MQTT CONNECT packet at the start of the data
+// sent by a client, in the first websocket frame after the upgrade request if websocket is set.
+// Returns false if the data ends before the client id, and an empty id if the data does not
+// start with a CONNECT packet.
+func readConnectClientID(data []byte, websocket bool) (string, bool) {
+	if websocket {
+		i := bytes.Index(data, []byte("\r\n\r\n"))
+		if i < 0 {
+			return "", false
+		}
+		var ok bool
+		if data, ok = websocketPayload(data[i+4:]); !ok {
+			return "", false
+		}
+	}
+
+	if len(data) == 0 {
+		return "", false
+	}
+	if data[0]>>4 != 1 {
+		return "", true
+	}
+	// the remaining length takes 1-4 bytes
+	i := 1
+	for ; i < len(data) && data[i]&0x80 != 0; i++ {
+		if i == 4 {
+			return "", true
+		}
+	}
+	i++
+	// the protocol name, level, flags and keep alive precede the client id
+	if len(data) < i+2 {
+		return "", false
+	}
+	i += 2 + int(binary.BigEndian.Uint16(data[i:])) + 4
+	if len(data) < i+2 {
+		return "", false
+	}
+	n := int(binary.BigEndian.Uint16(data[i:]))
+	i += 2
+	if len(data) < i+n {
+		return "", false
+	}
+	return string(data[i : i+n]), true
+}
+
+// websocketPayload returns the unmasked payload of the websocket frame at the start of the
+// data, as far as it was received. Returns false if the data ends before the payload.
+func websocketPayload(frame []byte) ([]byte, bool) {
+	if len(frame) < 2 {
+		return nil, false
+	}
+	i := 2
+	switch frame[1] & 0x7f {
+	case 126:
+		i += 2
+	case 127:
+		i += 8
+	}
+	masked := frame[1]&0x80 != 0
+	if masked {
+		i += 4
+	}
+	if len(frame) < i {
+		return nil, false
+	}
+	payload := append([]byte(nil), frame[i:]...)
+	if masked {
+		mask := frame[i-4 : i]
+		for j := range payload {
+			payload[j] ^= mask[j%4]
+		}
+	}
+	return payload, true
+}
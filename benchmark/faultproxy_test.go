@@ -0,0 +1,130 @@
+package benchmark
+
+import (
+	"io"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNetworkConditionsEnabled(t *testing.T) {
+	tests := []struct {
+		cond NetworkConditions
+		want bool
+	}{
+		{NetworkConditions{}, false},
+		// the defaults of the reset ratio and the seed alone inject nothing
+		{NetworkConditions{ResetRatio: 0.1, Seed: 1}, false},
+		{NetworkConditions{Latency: time.Millisecond}, true},
+		{NetworkConditions{Jitter: time.Millisecond}, true},
+		{NetworkConditions{Bandwidth: 1000}, true},
+		{NetworkConditions{DropRate: 0.01}, true},
+		{NetworkConditions{ResetInterval: time.Second}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.cond.Enabled(); got != tt.want {
+			t.Errorf("%+v enabled = %v, want %v", tt.cond, got, tt.want)
+		}
+	}
+}
+
+func TestNewFaultProxy(t *testing.T) {
+	for _, broker := range []string{"ssl://localhost:8883", "wss://localhost:443", "tls://localhost:8883"} {
+		if _, err := NewFaultProxy(broker, NetworkConditions{}); err == nil {
+			t.Errorf("created a fault proxy for %v", broker)
+		}
+	}
+
+	p, err := NewFaultProxy("ws://broker.local:8080/mqtt", NetworkConditions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	u, err := url.Parse(p.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "ws" || u.Path != "/mqtt" || u.Host != p.listener.Addr().String() {
+		t.Errorf("proxy URL %v, want the websocket path on the proxy address", p.URL())
+	}
+}
+
+func TestFaultProxyDelay(t *testing.T) {
+	cond := NetworkConditions{Latency: 50 * time.Millisecond, Jitter: 10 * time.Millisecond, DropRate: 0.2, Seed: 7}
+	newProxy := func() *FaultProxy {
+		p, err := NewFaultProxy("tcp://127.0.0.1:1", cond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	p, again := newProxy(), newProxy()
+	defer p.Close()
+	defer again.Close()
+
+	dropped := 0
+	for i := 0; i < 1000; i++ {
+		d := p.delay()
+		if d != again.delay() {
+			t.Fatalf("delay %d differs with the same seed", i)
+		}
+		if d >= 40*time.Millisecond+faultRetransmitTimeout {
+			dropped++
+			d -= faultRetransmitTimeout
+		}
+		if d < 40*time.Millisecond || d >= 60*time.Millisecond {
+			t.Fatalf("delay %v out of the jitter of 50ms +/- 10ms", d)
+		}
+	}
+	if dropped < 150 || dropped > 250 || p.drops != int64(dropped) {
+		t.Errorf("%d of 1000 chunks dropped, %d counted, want about 200", dropped, p.drops)
+	}
+}
+
+func TestFaultProxyForward(t *testing.T) {
+	// an echo server stands in for the broker
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(c, c)
+		}
+	}()
+
+	p, err := NewFaultProxy("tcp://"+l.Addr().String(), NetworkConditions{Latency: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(p.URL())
+	c, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	start := time.Now()
+	if _, err := c.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(c, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("read %q, %v, want the echo", buf, err)
+	}
+	if rtt := time.Since(start); rtt < 40*time.Millisecond {
+		t.Errorf("round trip of %v, want >= 40ms with 20ms in each direction", rtt)
+	}
+	p.Close()
+
+	res := calculateFaultResults(p.Conditions, []*FaultProxy{p})
+	if res.Latency != 20 || res.Connections != 1 || res.BytesUp != 4 || res.BytesDown != 4 {
+		t.Errorf("fault results %+v, want 1 connection with 4 bytes each way and 20ms latency", res)
+	}
+}
@@ -0,0 +1,135 @@
+package benchmark
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// histogramBase is the upper bound (ms) of the first bucket of latency histograms.
+	histogramBase = 0.001
+
+	// histogramGrowth is the ratio of the bounds of consecutive buckets, so the
+	// relative error of values read from the histogram is below 1%.
+	histogramGrowth = 1.02
+)
+
+// Histogram is a sparse histogram of latencies (ms) with logarithmic buckets. It is small
+// enough to keep with the results, and histograms of different runs can be merged.
+type Histogram struct {
+	Count   int64             `json:"count"`
+	Min     float64           `json:"min"`
+	Max     float64           `json:"max"`
+	Sum     float64           `json:"sum"`
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+// HistogramBucket counts the values in (Lower, Upper], bucket 0 counts the values up to histogramBase.
+type HistogramBucket struct {
+	Index int     `json:"index"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int64   `json:"count"`
+}
+
+// NewHistogram creates a histogram of the values.
+func NewHistogram(values []float64) *Histogram {
+	h := new(Histogram)
+	counts := make(map[int]int64)
+	for _, v := range values {
+		if h.Count == 0 || v < h.Min {
+			h.Min = v
+		}
+		if h.Count == 0 || v > h.Max {
+			h.Max = v
+		}
+		h.Count++
+		h.Sum += v
+		counts[histogramIndex(v)]++
+	}
+	h.setBuckets(counts)
+	return h
+}
+
+func histogramIndex(v float64) int {
+	if v <= histogramBase {
+		return 0
+	}
+	return int(math.Ceil(math.Log(v/histogramBase) / math.Log(histogramGrowth)))
+}
+
+func histogramUpper(index int) float64 {
+	return histogramBase * math.Pow(histogramGrowth, float64(index))
+}
+
+func (h *Histogram) setBuckets(counts map[int]int64) {
+	h.Buckets = make([]HistogramBucket, 0, len(counts))
+	for i, n := range counts {
+		b := HistogramBucket{Index: i, Upper: histogramUpper(i), Count: n}
+		if i > 0 {
+			b.Lower = histogramUpper(i - 1)
+		}
+		h.Buckets = append(h.Buckets, b)
+	}
+	sort.Slice(h.Buckets, func(i, j int) bool { return h.Buckets[i].Index < h.Buckets[j].Index })
+}
+
+// Merge adds the values of the other histogram.
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil || other.Count == 0 {
+		return
+	}
+	if h.Count == 0 || other.Min < h.Min {
+		h.Min = other.Min
+	}
+	if h.Count == 0 || other.Max > h.Max {
+		h.Max = other.Max
+	}
+	h.Count += other.Count
+	h.Sum += other.Sum
+
+	counts := make(map[int]int64)
+	for _, b := range h.Buckets {
+		counts[b.Index] += b.Count
+	}
+	for _, b := range other.Buckets {
+		counts[b.Index] += b.Count
+	}
+	h.setBuckets(counts)
+}
+
+// Mean returns the mean of the values.
+func (h *Histogram) Mean() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / float64(h.Count)
+}
+
+// Percentile returns the nearest-rank percentile p (0-100) of the values, with the
+// relative error of the bucket width. The value is clamped to the min and max.
+func (h *Histogram) Percentile(p float64) float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(p / 100 * float64(h.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var n int64
+	for _, b := range h.Buckets {
+		n += b.Count
+		if n >= rank {
+			return math.Max(h.Min, math.Min(h.Max, b.value()))
+		}
+	}
+	return h.Max
+}
+
+// value returns the representative value of the bucket: the geometric midpoint of the bounds.
+func (b HistogramBucket) value() float64 {
+	if b.Index == 0 {
+		return b.Upper
+	}
+	return math.Sqrt(b.Lower * b.Upper)
+}
@@ -0,0 +1,72 @@
+package benchmark
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestHistogramPercentile(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	values := make([]float64, 10000)
+	for i := range values {
+		// log-normal latencies around 5ms with a long tail
+		values[i] = math.Exp(rnd.NormFloat64() + math.Log(5))
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	h := NewHistogram(values)
+	if h.Count != int64(len(values)) || h.Min != sorted[0] || h.Max != sorted[len(sorted)-1] {
+		t.Fatalf("histogram count %v, min %v, max %v, want %v, %v, %v", h.Count, h.Min, h.Max, len(values), sorted[0], sorted[len(sorted)-1])
+	}
+	for _, p := range []float64{0, 1, 10, 50, 90, 99, 99.9, 100} {
+		want := percentile(sorted, p)
+		if got := h.Percentile(p); math.Abs(got-want)/want > 0.01 {
+			t.Errorf("p%v = %.4f, want %.4f within 1%%", p, got, want)
+		}
+	}
+}
+
+func TestHistogramSmallValues(t *testing.T) {
+	h := NewHistogram([]float64{0, 0.0005, 0.001, 2})
+	if len(h.Buckets) != 2 || h.Buckets[0].Index != 0 || h.Buckets[0].Count != 3 {
+		t.Fatalf("buckets %+v, want values up to the base in bucket 0", h.Buckets)
+	}
+	// bucket 0 reads as its upper bound, the others as their geometric midpoint
+	if got := h.Percentile(0); got != 0.001 {
+		t.Errorf("p0 = %v, want the upper bound of bucket 0", got)
+	}
+	if got := h.Percentile(100); got > 2 || got < 2/histogramGrowth {
+		t.Errorf("p100 = %v, want the value of the bucket of the max", got)
+	}
+	if got := new(Histogram).Percentile(50); got != 0 {
+		t.Errorf("p50 of an empty histogram = %v, want 0", got)
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a := []float64{0.5, 1, 1.2, 3, 7.5, 100}
+	b := []float64{0.0001, 1, 2, 250}
+
+	h := NewHistogram(a)
+	h.Merge(NewHistogram(b))
+	h.Merge(nil)
+	h.Merge(new(Histogram))
+	want := NewHistogram(append(append([]float64(nil), a...), b...))
+
+	if h.Count != want.Count || h.Min != want.Min || h.Max != want.Max || math.Abs(h.Sum-want.Sum) > 1e-9 {
+		t.Errorf("merged count %v, min %v, max %v, sum %v, want %v, %v, %v, %v", h.Count, h.Min, h.Max, h.Sum, want.Count, want.Min, want.Max, want.Sum)
+	}
+	if !reflect.DeepEqual(h.Buckets, want.Buckets) {
+		t.Errorf("merged buckets %+v, want %+v", h.Buckets, want.Buckets)
+	}
+
+	empty := new(Histogram)
+	empty.Merge(NewHistogram(b))
+	if empty.Min != 0.0001 || empty.Max != 250 || empty.Count != 4 {
+		t.Errorf("merged into empty: min %v, max %v, count %v", empty.Min, empty.Max, empty.Count)
+	}
+}
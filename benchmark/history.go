@@ -0,0 +1,256 @@
+package benchmark
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyLineLimit is the maximum size of a history entry, which includes the histogram and intervals.
+const historyLineLimit = 64 * 1024 * 1024
+
+// HistoryEntry is the total results of a run recorded in the history.
+// ID is the 1-based position of the entry in the history.
+type HistoryEntry struct {
+	ID       int           `json:"-"`
+	Recorded time.Time     `json:"recorded"`
+	Totals   *TotalResults `json:"totals"`
+}
+
+// HistoryFilter selects the entries of the history by key, empty fields match all entries.
+type HistoryFilter struct {
+	RunID    string
+	CaseID   string
+	Instance string
+}
+
+func (f HistoryFilter) match(t *TotalResults) bool {
+	return (f.RunID == "" || f.RunID == t.TestRunID) &&
+		(f.CaseID == "" || f.CaseID == t.TestCaseID) &&
+		(f.Instance == "" || f.Instance == t.TestInstance)
+}
+
+// History is a local store of the total results of past runs, kept as a JSON line per
+// run in an append-only file, so concurrent runs on the same machine do not conflict.
+type History struct {
+	Path string
+}
+
+// DefaultHistoryPath returns the path of the history in the home directory.
+func DefaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "mqtt-benchmark-history.jsonl"
+	}
+	return filepath.Join(home, ".mqtt-benchmark", "history.jsonl")
+}
+
+// Add records the total results in the history.
+func (h *History) Add(totals *TotalResults) error {
+	data, err := json.Marshal(&HistoryEntry{Recorded: time.Now(), Totals: totals})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.Path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries returns the entries of the history matching the filter, in the order they were recorded.
+// Malformed entries, e.g. a line cut short by a crashed run, are skipped with a warning.
+func (h *History) Entries(filter HistoryFilter) ([]*HistoryEntry, error) {
+	f, err := os.Open(h.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*HistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), historyLineLimit)
+	for id := 1; scanner.Scan(); id++ {
+		e := &HistoryEntry{ID: id}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil || e.Totals == nil {
+			if err == nil {
+				err = errors.New("no totals")
+			}
+			log.Printf("Skipping malformed entry %d of the history %v: %v", id, h.Path, err)
+			continue
+		}
+		if filter.match(e.Totals) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Entry returns the entry with the id.
+func (h *History) Entry(id int) (*HistoryEntry, error) {
+	entries, err := h.Entries(HistoryFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("no history entry %d", id)
+}
+
+// TrendPoint is the value of a metric in a history entry.
+type TrendPoint struct {
+	Entry *HistoryEntry
+	Value float64
+
+	// Change is the relative change from the previous point.
+	Change float64
+}
+
+// Trend returns the values of the metric in the entries. The metric is the JSON name of
+// a TotalResults field, as for assertions. Entries without the metric are skipped.
+func Trend(entries []*HistoryEntry, metric string) ([]*TrendPoint, error) {
+	metric = strings.ToLower(metric)
+	if name, ok := metricAliases[metric]; ok {
+		metric = name
+	}
+	path := strings.Split(metric, ".")
+	if _, ok := metricField(reflect.TypeOf(TotalResults{}), path); !ok {
+		return nil, fmt.Errorf("unknown metric: %v", metric)
+	}
+
+	var points []*TrendPoint
+	for _, e := range entries {
+		v, ok := totalsMetric(e.Totals, metric)
+		if !ok {
+			continue
+		}
+		p := &TrendPoint{Entry: e, Value: v}
+		if n := len(points); n > 0 && points[n-1].Value != 0 {
+			p.Change = (v - points[n-1].Value) / math.Abs(points[n-1].Value)
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// WriteHistory writes the entries as a table.
+func WriteHistory(w io.Writer, entries []*HistoryEntry) error {
+	header := []string{"#", "Recorded", "Run Id", "Case Id", "Instance", "Type", "Msgs/sec", "P99 (ms)", "Ratio"}
+	rows := make([][]string, len(entries))
+	for i, e := range entries {
+		t := e.Totals
+		rows[i] = []string{
+			strconv.Itoa(e.ID),
+			e.Recorded.Local().Format("2006-01-02 15:04:05"),
+			t.TestRunID,
+			t.TestCaseID,
+			t.TestInstance,
+			t.TestRunType,
+			strconv.FormatFloat(t.TotalMsgsPerSec, 'f', 3, 64),
+			strconv.FormatFloat(t.MsgTimeP99, 'f', 3, 64),
+			strconv.FormatFloat(t.Ratio, 'f', 3, 64),
+		}
+	}
+	return writeTable(w, header, rows)
+}
+
+// WriteTrend writes the values of the metric as a table, followed by their summary.
+func WriteTrend(w io.Writer, metric string, points []*TrendPoint) error {
+	header := []string{"#", "Recorded", "Run Id", "Instance", metric, "Change"}
+	rows := make([][]string, len(points))
+	values := make([]float64, len(points))
+	for i, p := range points {
+		change := "-"
+		if i > 0 && p.Change != 0 {
+			change = fmt.Sprintf("%+.2f%%", p.Change*100)
+		}
+		rows[i] = []string{
+			strconv.Itoa(p.Entry.ID),
+			p.Entry.Recorded.Local().Format("2006-01-02 15:04:05"),
+			p.Entry.Totals.TestRunID,
+			p.Entry.Totals.TestInstance,
+			strconv.FormatFloat(p.Value, 'f', 3, 64),
+			change,
+		}
+		values[i] = p.Value
+	}
+	if err := writeTable(w, header, rows); err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	min, max, sum := values[0], values[0], 0.0
+	for _, v := range values {
+		min, max, sum = math.Min(min, v), math.Max(max, v), sum+v
+	}
+	mean := sum / float64(len(values))
+	last := values[len(values)-1]
+	_, err := fmt.Fprintf(w, "\nRuns: %d, min %.3f, mean %.3f, max %.3f, latest %.3f", len(values), min, mean, max, last)
+	if err == nil && mean != 0 {
+		_, err = fmt.Fprintf(w, " (%+.2f%% from mean)", (last-mean)/math.Abs(mean)*100)
+	}
+	if err == nil {
+		_, err = io.WriteString(w, "\n")
+	}
+	return err
+}
+
+// writeTable writes the rows aligned in columns, numeric columns right-aligned.
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	widths := make([]int, len(header))
+	numeric := make([]bool, len(header))
+	for i := range header {
+		numeric[i] = len(rows) > 0
+	}
+	for j, r := range append([][]string{header}, rows...) {
+		for i, cell := range r {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+			if j > 0 && cell != "-" {
+				if _, err := strconv.ParseFloat(strings.TrimSuffix(cell, "%"), 64); err != nil {
+					numeric[i] = false
+				}
+			}
+		}
+	}
+	for _, r := range append([][]string{header}, rows...) {
+		cells := make([]string, len(r))
+		for i, cell := range r {
+			if numeric[i] {
+				cells[i] = fmt.Sprintf("%*s", widths[i], cell)
+			} else {
+				cells[i] = fmt.Sprintf("%-*s", widths[i], cell)
+			}
+		}
+		if _, err := io.WriteString(w, strings.TrimRight(strings.Join(cells, "  "), " ")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package benchmark
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistorySkipsMalformedEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := &History{Path: filepath.Join(dir, "history.jsonl")}
+	if err := h.Add(&TotalResults{TestRunID: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	// a line cut short and a line without totals
+	f, err := os.OpenFile(h.Path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{\"recorded\": \"2026-\n{}\n")
+	f.Close()
+	if err := h.Add(&TotalResults{TestRunID: "last"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := h.Entries(HistoryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != 1 || entries[1].ID != 4 {
+		t.Fatalf("entries %+v, want ids 1 and 4", entries)
+	}
+	e, err := h.Entry(4)
+	if err != nil || e.Totals.TestRunID != "last" {
+		t.Errorf("entry 4 = %+v, %v, want the last run", e, err)
+	}
+	if _, err := h.Entry(2); err == nil {
+		t.Errorf("malformed entry 2 returned no error")
+	}
+}
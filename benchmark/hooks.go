@@ -0,0 +1,180 @@
+package benchmark
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MessageEvent describes a single message published or received by a client.
+type MessageEvent struct {
+	ClientID string
+	Topic    string
+	Seq      int
+
+	// Sent is the publish time, read from the payload header by subscribers,
+	// zero if the message has no header.
+	Sent time.Time
+
+	// Acked is the time the publisher got the acknowledgement of the message,
+	// Received is the time the subscriber got the message.
+	Acked    time.Time
+	Received time.Time
+
+	// Latency is the publish latency for publishers and the end-to-end latency
+	// for subscribers, zero if unknown.
+	Latency time.Duration
+	Error   bool
+}
+
+// IntervalEvent describes the messages of all clients during a reporting interval.
+type IntervalEvent struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	Published int64 `json:"published"`
+	Received  int64 `json:"received"`
+	Failures  int64 `json:"failures"`
+
+	// MsgsPerSec is the rate of published and received messages during the interval.
+	MsgsPerSec float64 `json:"msgs_per_sec"`
+
+	// Latency stats (ms) of the messages of the interval.
+	LatencyMean float64 `json:"latency_mean"`
+	LatencyP50  float64 `json:"latency_p50"`
+	LatencyP99  float64 `json:"latency_p99"`
+	LatencyMax  float64 `json:"latency_max"`
+}
+
+// Hooks are callbacks of the runner, called while the benchmark is running.
+// They are called from the goroutines of the clients, so they should be safe
+// for concurrent use and return quickly not to slow the clients down.
+type Hooks struct {
+	// OnMessage is called for every message published or received.
+	OnMessage func(MessageEvent)
+
+	// OnInterval is called every Interval (1 sec if not set) and once more
+	// for the last partial interval when the clients are done.
+	OnInterval func(IntervalEvent)
+	Interval   time.Duration
+}
+
+// messageMeter passes message events to the hooks and aggregates them per interval.
+type messageMeter struct {
+	hooks Hooks
+
+	mu        sync.Mutex
+	start     time.Time
+	published int64
+	received  int64
+	failures  int64
+	latencies []float64
+
+	stop chan bool
+	done chan bool
+}
+
+func newMessageMeter(hooks Hooks) *messageMeter {
+	if hooks.Interval <= 0 {
+		hooks.Interval = time.Second
+	}
+	return &messageMeter{
+		hooks: hooks,
+		stop:  make(chan bool),
+		done:  make(chan bool),
+	}
+}
+
+// Start starts reporting intervals, if the interval hook is set.
+func (m *messageMeter) Start() {
+	m.mu.Lock()
+	m.start = time.Now()
+	m.mu.Unlock()
+
+	if m.hooks.OnInterval == nil {
+		close(m.done)
+		return
+	}
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.hooks.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.hooks.OnInterval(m.flush())
+			case <-m.stop:
+				m.hooks.OnInterval(m.flush())
+				return
+			}
+		}
+	}()
+}
+
+// Stop reports the last interval and waits for the interval hook to return.
+func (m *messageMeter) Stop() {
+	close(m.stop)
+	<-m.done
+}
+
+// onMessage returns the callback for the clients, nil if nobody listens to messages.
+func (m *messageMeter) onMessage() func(MessageEvent) {
+	if m.hooks.OnMessage == nil && m.hooks.OnInterval == nil {
+		return nil
+	}
+	return m.add
+}
+
+func (m *messageMeter) add(e MessageEvent) {
+	if m.hooks.OnInterval != nil {
+		m.mu.Lock()
+		switch {
+		case e.Error:
+			m.failures++
+		case e.Received.IsZero():
+			m.published++
+		default:
+			m.received++
+		}
+		if !e.Error && e.Latency > 0 {
+			m.latencies = append(m.latencies, float64(e.Latency.Microseconds())/1000)
+		}
+		m.mu.Unlock()
+	}
+	if m.hooks.OnMessage != nil {
+		m.hooks.OnMessage(e)
+	}
+}
+
+// flush returns the event of the current interval and starts the next one.
+func (m *messageMeter) flush() IntervalEvent {
+	m.mu.Lock()
+	e := IntervalEvent{
+		Start:     m.start,
+		End:       time.Now(),
+		Published: m.published,
+		Received:  m.received,
+		Failures:  m.failures,
+	}
+	latencies := m.latencies
+	m.start = e.End
+	m.published, m.received, m.failures = 0, 0, 0
+	m.latencies = nil
+	m.mu.Unlock()
+
+	if d := e.End.Sub(e.Start).Seconds(); d > 0 {
+		e.MsgsPerSec = float64(e.Published+e.Received) / d
+	}
+	if len(latencies) > 0 {
+		sort.Float64s(latencies)
+		var sum float64
+		for _, l := range latencies {
+			sum += l
+		}
+		e.LatencyMean = sum / float64(len(latencies))
+		e.LatencyP50 = percentile(latencies, 50)
+		e.LatencyP99 = percentile(latencies, 99)
+		e.LatencyMax = latencies[len(latencies)-1]
+	}
+	return e
+}
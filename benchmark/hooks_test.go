@@ -0,0 +1,50 @@
+package benchmark
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMessageMeter(t *testing.T) {
+	if newMessageMeter(Hooks{}).onMessage() != nil {
+		t.Error("message callback without hooks")
+	}
+
+	var messages int
+	var intervals []IntervalEvent
+	m := newMessageMeter(Hooks{
+		OnMessage:  func(MessageEvent) { messages++ },
+		OnInterval: func(e IntervalEvent) { intervals = append(intervals, e) },
+		// only the last partial interval is reported
+		Interval: time.Hour,
+	})
+	m.Start()
+	now := time.Now()
+	events := []MessageEvent{
+		{ClientID: "pub-0", Acked: now, Latency: 4 * time.Millisecond},
+		{ClientID: "pub-0", Acked: now, Latency: 2 * time.Millisecond},
+		{ClientID: "pub-0", Error: true, Latency: time.Second},
+		{ClientID: "sub-0", Received: now, Latency: 6 * time.Millisecond},
+		// without a payload header
+		{ClientID: "sub-0", Received: now},
+	}
+	add := m.onMessage()
+	for _, e := range events {
+		add(e)
+	}
+	m.Stop()
+
+	if messages != len(events) {
+		t.Errorf("%d message hooks called, want %d", messages, len(events))
+	}
+	if len(intervals) != 1 {
+		t.Fatalf("%d intervals, want 1", len(intervals))
+	}
+	e := intervals[0]
+	if e.Published != 2 || e.Received != 2 || e.Failures != 1 || e.MsgsPerSec <= 0 {
+		t.Errorf("%d published, %d received, %d failures, rate %v", e.Published, e.Received, e.Failures, e.MsgsPerSec)
+	}
+	if e.LatencyMean != 4 || e.LatencyP50 != 4 || e.LatencyMax != 6 {
+		t.Errorf("latency mean %v, p50 %v, max %v, want 4, 4, 6", e.LatencyMean, e.LatencyP50, e.LatencyMax)
+	}
+}
@@ -0,0 +1,180 @@
+package benchmark
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MergeResults combines the results of several instances running the same test into
+// aggregate results, as if all clients had run on a single instance. The totals are
+// recalculated from the results of all clients over the union of the test time ranges.
+// The latency histograms of the instances are merged and the percentiles are read from
+// the merged histogram, unless the raw samples of all clients were kept.
+// The topic distribution and the results per broker node are recalculated from the
+// results of the clients. The results of the other modes and options are calculated from
+// the state of the running test, so inputs with those are refused.
+func MergeResults(inputs []*JSONResults) (*JSONResults, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("no results to merge")
+	}
+	first := inputs[0].Totals
+	start, end := first.TestStart, first.TestEnd
+	var runs []*RunResults
+	var instances []string
+	seen := make(map[string]bool)
+	histogram := new(Histogram)
+	var brokers []string
+	seenBrokers := make(map[string]bool)
+	clients, topics, unreported, takeovers, interrupted := 0, 0, 0, int64(0), false
+
+	for i, in := range inputs {
+		t := in.Totals
+		if t.TestRunType != first.TestRunType {
+			return nil, fmt.Errorf("can not merge results of different test types: %v and %v", first.TestRunType, t.TestRunType)
+		}
+		if names := modeResults(t); len(names) > 0 {
+			return nil, fmt.Errorf("can not merge the %v results of %v", strings.Join(names, ", "), t.TestInstance)
+		}
+		if t.TestStart.Before(start) {
+			start = t.TestStart
+		}
+		if t.TestEnd.After(end) {
+			end = t.TestEnd
+		}
+
+		instance := t.TestInstance
+		if instance == "" || seen[instance] {
+			instance = fmt.Sprintf("%v#%d", t.TestInstance, i+1)
+		}
+		seen[instance] = true
+		instances = append(instances, instance)
+		for _, r := range in.Runs {
+			run := *r
+			run.ID = instance + "/" + r.ID
+			runs = append(runs, &run)
+		}
+
+		histogram.Merge(t.MsgTimeHistogram)
+		clients += t.Clients
+		if t.Topics > topics {
+			topics = t.Topics
+		}
+		unreported += t.Unreported
+		takeovers += t.ClientIDTakeovers
+		interrupted = interrupted || t.Interrupted
+		for _, n := range t.Nodes {
+			if !seenBrokers[n.Broker] {
+				seenBrokers[n.Broker] = true
+				brokers = append(brokers, n.Broker)
+			}
+		}
+	}
+	if len(runs) == 0 {
+		return nil, errors.New("no client results to merge")
+	}
+
+	totals := calculateTotalResults(first.TestRunID, first.TestCaseID, runs, start, end, first.TestRunType,
+		clients, topics, first.Messages, first.MessageSize, first.QoS, first.Dop)
+	totals.TestInstance = strings.Join(instances, ",")
+	totals.Unreported = unreported
+	totals.ClientIDTakeovers = takeovers
+	totals.Interrupted = interrupted
+
+	if histogram.Count > 0 && (totals.MsgTimeHistogram == nil || totals.MsgTimeHistogram.Count != histogram.Count) {
+		totals.MsgTimeHistogram = histogram
+		totals.MsgTimeP50 = histogram.Percentile(50)
+		totals.MsgTimeP90 = histogram.Percentile(90)
+		totals.MsgTimeP99 = histogram.Percentile(99)
+	}
+	totals.Intervals = mergeIntervals(inputs, start)
+	if first.TopicDistribution != nil {
+		// the counts per topic are kept with the results of each publisher
+		totals.TopicDistribution = calculateTopicResults(runs, first.TopicDistribution.Strategy)
+	}
+	if len(brokers) > 0 {
+		// each client result keeps the node it was connected to
+		totals.Strategy = first.Strategy
+		totals.Nodes = calculateNodeResults(runs, brokers, totals.TotalRunTime)
+	}
+	return &JSONResults{Runs: runs, Totals: totals}, nil
+}
+
+// modeResults returns the names of the optional results which are set and can not be
+// recalculated from the results of the clients.
+func modeResults(t *TotalResults) []string {
+	var names []string
+	v := reflect.ValueOf(t).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		name := jsonName(v.Type().Field(i))
+		switch name {
+		case "msg_time_histogram", "intervals", "topic_distribution", "nodes":
+			continue
+		}
+		switch f.Kind() {
+		case reflect.Ptr, reflect.Slice:
+			if !f.IsNil() {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// mergeIntervals combines the time series of the instances into intervals of the length
+// of the first one, aligned to the start. Counts and rates are added up and the latency
+// mean is weighted by the number of messages, while the percentiles can not be combined
+// without the samples: the merged p50, p99 and max are the highest of the instances.
+func mergeIntervals(inputs []*JSONResults, start time.Time) []IntervalEvent {
+	var length time.Duration
+	for _, in := range inputs {
+		if len(in.Totals.Intervals) > 0 {
+			e := in.Totals.Intervals[0]
+			length = e.End.Sub(e.Start)
+			break
+		}
+	}
+	if length <= 0 {
+		return nil
+	}
+
+	slots := make(map[int]*IntervalEvent)
+	latencySums := make(map[int]float64)
+	for _, in := range inputs {
+		for _, e := range in.Totals.Intervals {
+			// the slot of the midpoint, as the intervals of the instances drift apart
+			i := int((e.Start.Sub(start) + e.End.Sub(e.Start)/2) / length)
+			if i < 0 {
+				i = 0
+			}
+			s, ok := slots[i]
+			if !ok {
+				s = &IntervalEvent{Start: start.Add(time.Duration(i) * length), End: start.Add(time.Duration(i+1) * length)}
+				slots[i] = s
+			}
+			s.Published += e.Published
+			s.Received += e.Received
+			s.Failures += e.Failures
+			latencySums[i] += e.LatencyMean * float64(e.Published+e.Received)
+			s.LatencyP50 = math.Max(s.LatencyP50, e.LatencyP50)
+			s.LatencyP99 = math.Max(s.LatencyP99, e.LatencyP99)
+			s.LatencyMax = math.Max(s.LatencyMax, e.LatencyMax)
+		}
+	}
+
+	intervals := make([]IntervalEvent, 0, len(slots))
+	for i, s := range slots {
+		s.MsgsPerSec = float64(s.Published+s.Received) / length.Seconds()
+		if n := s.Published + s.Received; n > 0 {
+			s.LatencyMean = latencySums[i] / float64(n)
+		}
+		intervals = append(intervals, *s)
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start.Before(intervals[j].Start) })
+	return intervals
+}
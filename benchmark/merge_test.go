@@ -0,0 +1,65 @@
+package benchmark
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mergeInput(instance string, start time.Time, runs ...*RunResults) *JSONResults {
+	return &JSONResults{
+		Runs: runs,
+		Totals: &TotalResults{
+			TestInstance: instance,
+			TestRunType:  "pub",
+			TestStart:    start,
+			TestEnd:      start.Add(10 * time.Second),
+			Clients:      len(runs),
+			Nodes:        []*NodeResults{{Broker: "tcp://a:1883"}, {Broker: "tcp://b:1883"}},
+			Strategy:     "round-robin",
+		},
+	}
+}
+
+func TestMergeNodes(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	inputs := []*JSONResults{
+		mergeInput("i1", start,
+			&RunResults{ID: "0", Broker: "tcp://a:1883", Successes: 100, MsgTimeMin: 1, MsgTimeMax: 4, MsgTimeMean: 2, ClientRunTime: 10},
+			&RunResults{ID: "1", Broker: "tcp://b:1883", Successes: 100, MsgTimeMin: 2, MsgTimeMax: 5, MsgTimeMean: 3, ClientRunTime: 10}),
+		mergeInput("i2", start.Add(10*time.Second),
+			&RunResults{ID: "0", Broker: "tcp://a:1883", Successes: 50, Failures: 50, MsgTimeMin: 0.5, MsgTimeMax: 8, MsgTimeMean: 4, ClientRunTime: 10}),
+	}
+	merged, err := MergeResults(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := merged.Totals.Nodes
+	if len(nodes) != 2 || merged.Totals.Strategy != "round-robin" {
+		t.Fatalf("nodes %+v, strategy %v, want 2 nodes with round-robin", nodes, merged.Totals.Strategy)
+	}
+	a := nodes[0]
+	if a.Broker != "tcp://a:1883" || a.Clients != 2 || a.Successes != 150 || a.Failures != 50 {
+		t.Errorf("node %+v, want 2 clients with 150 successes and 50 failures", a)
+	}
+	if a.MsgTimeMin != 0.5 || a.MsgTimeMax != 8 || a.MsgTimeMean != 3 || a.Ratio != 0.75 {
+		t.Errorf("node %+v, want min 0.5, max 8, mean 3 and ratio 0.75", a)
+	}
+	// the runs of the instances cover 20s
+	if !almostEqual(a.MsgsPerSec, 7.5, 1e-9) {
+		t.Errorf("node throughput %v, want 7.5", a.MsgsPerSec)
+	}
+}
+
+func TestMergeRefusesModeResults(t *testing.T) {
+	start := time.Now()
+	inputs := []*JSONResults{
+		mergeInput("i1", start, &RunResults{ID: "0", Successes: 1}),
+		mergeInput("i2", start, &RunResults{ID: "0", Successes: 1}),
+	}
+	inputs[1].Totals.Session = &SessionResults{}
+	_, err := MergeResults(inputs)
+	if err == nil || !strings.Contains(err.Error(), "session") {
+		t.Errorf("merged results with session results, error %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package benchmark
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// payloadHeaderSize is the size of the header publishers write at the beginning
+// of the payload: sent time (unix nano), publisher id and per-topic sequence number.
+// Messages smaller than the header are sent without it.
+const payloadHeaderSize = 16
+
+// payloadHeader describes the header of a published message.
+type payloadHeader struct {
+	Sent      time.Time
+	Publisher int
+	Seq       int
+}
+
+// writePayloadHeader writes the header to the payload if it is large enough.
+func writePayloadHeader(payload []byte, h payloadHeader) {
+	if len(payload) < payloadHeaderSize {
+		return
+	}
+	binary.BigEndian.PutUint64(payload[0:8], uint64(h.Sent.UnixNano()))
+	binary.BigEndian.PutUint32(payload[8:12], uint32(h.Publisher))
+	binary.BigEndian.PutUint32(payload[12:16], uint32(h.Seq))
+}
+
+// readPayloadHeader reads the header from the payload, returns false if
+// the payload is too small to contain one.
+func readPayloadHeader(payload []byte) (payloadHeader, bool) {
+	if len(payload) < payloadHeaderSize {
+		return payloadHeader{}, false
+	}
+	sent := int64(binary.BigEndian.Uint64(payload[0:8]))
+	if sent == 0 {
+		return payloadHeader{}, false
+	}
+	return payloadHeader{
+		Sent:      time.Unix(0, sent),
+		Publisher: int(binary.BigEndian.Uint32(payload[8:12])),
+		Seq:       int(binary.BigEndian.Uint32(payload[12:16])),
+	}, true
+}
+
+// requestHeaderSize is the size of the fixed part of the request header, which follows
+// the payload header in request/response mode: correlation id and reply topic length.
+// MQTT 3.1.1 has no response topic / correlation data properties, so they are in the payload.
+const requestHeaderSize = payloadHeaderSize + 10
+
+// requestHeader describes the header of a request: the responder publishes the
+// payload as is to the reply topic, so the requester can match the response.
+type requestHeader struct {
+	payloadHeader
+	CorrelationID uint64
+	ReplyTopic    string
+}
+
+// newRequestPayload creates a request payload of the given size, grown to fit the header if needed.
+func newRequestPayload(size int, h requestHeader) []byte {
+	if min := requestHeaderSize + len(h.ReplyTopic); size < min {
+		size = min
+	}
+	payload := make([]byte, size)
+	writePayloadHeader(payload, h.payloadHeader)
+	binary.BigEndian.PutUint64(payload[16:24], h.CorrelationID)
+	binary.BigEndian.PutUint16(payload[24:26], uint16(len(h.ReplyTopic)))
+	copy(payload[requestHeaderSize:], h.ReplyTopic)
+	return payload
+}
+
+// readRequestHeader reads the request header from the payload, returns false
+// if the payload does not contain one.
+func readRequestHeader(payload []byte) (requestHeader, bool) {
+	ph, ok := readPayloadHeader(payload)
+	if !ok || len(payload) < requestHeaderSize {
+		return requestHeader{}, false
+	}
+	n := int(binary.BigEndian.Uint16(payload[24:26]))
+	if len(payload) < requestHeaderSize+n {
+		return requestHeader{}, false
+	}
+	return requestHeader{
+		payloadHeader: ph,
+		CorrelationID: binary.BigEndian.Uint64(payload[16:24]),
+		ReplyTopic:    string(payload[requestHeaderSize : requestHeaderSize+n]),
+	}, true
+}
@@ -0,0 +1,279 @@
+package benchmark
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/GaryBoone/GoStats/stats"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+type Publisher struct {
+	id           int
+	brokerURL    string
+	brokerUser   string
+	brokerPass   string
+	ids          *clientIDRegistry
+	MsgTopics    []string
+	TopicSelect  string
+	MsgSize      int
+	MsgCount     int
+	MsgQoS       byte
+	Quiet        bool
+	Panic        bool
+	TestDuration time.Duration
+	Reconnect    *ReconnectPolicy
+	Credentials  *Credentials
+
+	// Stop is closed to stop publishing before the test is over, nil if not used.
+	Stop chan bool
+
+	onMessage func(MessageEvent)
+	testTimer *time.Timer
+	connected time.Time
+	pickTopic topicPicker
+	tracker   *ConnectionTracker
+}
+
+func (c Publisher) ClientId() string {
+	return fmt.Sprintf("pub-%d", c.id)
+}
+
+func (c Publisher) BrokerUrl() string {
+	return c.brokerURL
+}
+
+func (c Publisher) BrokerUser() string {
+	return c.brokerUser
+}
+
+func (c Publisher) BrokerPass() string {
+	return c.brokerPass
+}
+
+func (c Publisher) PanicMode() bool {
+	return c.Panic
+}
+
+func (c Publisher) clientIDs() *clientIDRegistry {
+	return c.ids
+}
+
+func (c *Publisher) connectionTracker() *ConnectionTracker {
+	return c.tracker
+}
+
+func (c *Publisher) credentials() *Credentials {
+	return c.Credentials
+}
+
+func (c Publisher) Run(res chan *RunResults) {
+	newMsgs := make(chan *Message)
+	pubMsgs := make(chan *Message)
+	doneGen := make(chan bool)
+	donePub := make(chan bool)
+	stopped := make(chan bool)
+	runResults := &RunResults{
+		ID:          c.ClientId(),
+		MsgPerTopic: make(map[string]int64),
+	}
+
+	c.testTimer = time.NewTimer(c.TestDuration)
+	c.pickTopic = newTopicPicker(c.TopicSelect, c.ClientId(), c.MsgTopics)
+	c.tracker = newConnectionTracker(c.Reconnect)
+
+	// start generator
+	go c.genMessages(newMsgs, doneGen, stopped)
+	// start publisher
+	go c.pubMessages(newMsgs, pubMsgs, doneGen, donePub, stopped)
+
+	times := make([]float64, 0, c.MsgCount)
+	for {
+		select {
+		case m := <-pubMsgs:
+			if m.Error {
+				log.Printf("CLIENT %v ERROR publishing message: %v: at %v\n", c.ClientId(), m.Topic, m.Sent.Unix())
+				runResults.Failures++
+				if m.Disconnected {
+					runResults.DisconnectedFailures++
+				}
+			} else {
+				runResults.Successes++
+				runResults.MsgPerTopic[m.Topic]++
+				times = append(times, float64(m.Delivered.Sub(m.Sent).Microseconds())/1000) // in milliseconds
+			}
+			if c.onMessage != nil {
+				c.onMessage(c.messageEvent(m))
+			}
+		case <-donePub:
+			c.finish(stopped, res, c.prepareResult(runResults, times))
+			return
+		case <-c.testTimer.C:
+			c.finish(stopped, res, c.prepareResult(runResults, times))
+			return
+		case <-c.Stop:
+			if !c.Quiet {
+				log.Printf("CLIENT %v is stopped\n", c.ClientId())
+			}
+			c.finish(stopped, res, c.prepareResult(runResults, times))
+			return
+		}
+	}
+}
+
+// finish stops the generator, disconnects the client and sends the results.
+func (c *Publisher) finish(stopped chan bool, res chan *RunResults, runResults *RunResults) {
+	close(stopped)
+	c.tracker.disconnect(250)
+	res <- runResults
+}
+
+func (c Publisher) genMessages(ch chan *Message, done, stopped chan bool) {
+	// sequence numbers are per topic, so subscribers of a single topic can detect gaps
+	seqs := make(map[string]int)
+	for i := 0; i < c.MsgCount || c.MsgCount == 0; i++ {
+		topic := c.pickTopic(i)
+		select {
+		case ch <- &Message{
+			Topic:     topic,
+			QoS:       c.MsgQoS,
+			Payload:   make([]byte, c.MsgSize),
+			Seq:       seqs[topic],
+			Publisher: c.id,
+		}:
+		case <-stopped:
+			return
+		}
+		seqs[topic]++
+	}
+	select {
+	case done <- true:
+	case <-stopped:
+	}
+}
+
+func (c *Publisher) pubMessages(in, out chan *Message, doneGen, donePub, stopped chan bool) {
+	onConnected := func(client mqtt.Client) {
+		// keep the time of the first connection when reconnecting
+		if c.connected.IsZero() {
+			c.connected = time.Now()
+		}
+		if !c.Quiet {
+			log.Printf("CLIENT %v is connected to the broker %v and topic(s) %v\n", c.ClientId(), c.BrokerUrl(), c.MsgTopics)
+		}
+		for {
+			select {
+			case m := <-in:
+				m.Sent = time.Now()
+				writePayloadHeader(m.Payload.([]byte), payloadHeader{Sent: m.Sent, Publisher: m.Publisher, Seq: m.Seq})
+				token, ok := c.publish(client, m)
+				if !ok {
+					log.Printf("CLIENT %v Error sending message: lost connection to the broker\n", c.ClientId())
+					m.Error = true
+					m.Disconnected = true
+				} else if token.Error() != nil {
+					log.Printf("CLIENT %v Error sending message: %v\n", c.ClientId(), token.Error())
+					if c.Panic {
+						panic(token.Error())
+					}
+					m.Error = true
+					m.Disconnected = !client.IsConnected()
+				} else {
+					m.Delivered = time.Now()
+					m.Error = false
+				}
+				select {
+				case out <- m:
+				case <-stopped:
+					return
+				}
+				if m.Disconnected && c.tracker.Policy.Enabled() {
+					// publishing resumes when the reconnected client calls onConnected
+					return
+				}
+			case <-doneGen:
+				select {
+				case donePub <- true:
+				case <-stopped:
+				}
+				if !c.Quiet {
+					log.Printf("CLIENT %v is done publishing\n", c.ClientId())
+				}
+				return
+			case <-stopped:
+				return
+			}
+		}
+	}
+
+	connect(c, onConnected)
+}
+
+// publish publishes the message and waits for the token to complete. With a reconnect policy
+// it gives up and returns false if the client lost connection while publishing, as paho never
+// completes (and may even block) publishing of messages in flight when the connection is lost.
+func (c *Publisher) publish(client mqtt.Client, m *Message) (mqtt.Token, bool) {
+	if !c.tracker.Policy.Enabled() {
+		token := client.Publish(m.Topic, m.QoS, false, m.Payload)
+		token.Wait()
+		return token, true
+	}
+
+	published := make(chan mqtt.Token, 1)
+	go func() {
+		published <- client.Publish(m.Topic, m.QoS, false, m.Payload)
+	}()
+
+	var token mqtt.Token
+	for {
+		if token == nil {
+			select {
+			case token = <-published:
+				continue
+			case <-time.After(100 * time.Millisecond):
+			}
+		} else if token.WaitTimeout(100 * time.Millisecond) {
+			return token, true
+		}
+		if !client.IsConnected() {
+			return nil, false
+		}
+	}
+}
+
+func (c Publisher) messageEvent(m *Message) MessageEvent {
+	e := MessageEvent{
+		ClientID: c.ClientId(),
+		Topic:    m.Topic,
+		Seq:      m.Seq,
+		Sent:     m.Sent,
+		Error:    m.Error,
+	}
+	if !m.Error {
+		e.Acked = m.Delivered
+		e.Latency = m.Delivered.Sub(m.Sent)
+	}
+	return e
+}
+
+func (c Publisher) prepareResult(runResults *RunResults, times []float64) *RunResults {
+	duration := time.Since(c.connected)
+	if len(times) > 0 {
+		runResults.MsgTimeMin = stats.StatsMin(times)
+		runResults.MsgTimeMax = stats.StatsMax(times)
+		runResults.MsgTimeMean = stats.StatsMean(times)
+	}
+	runResults.ClientRunTime = duration.Seconds()
+	runResults.MsgTimes = times
+	runResults.Broker = c.tracker.Broker()
+	runResults.ConnectionEvents = c.tracker.Events()
+	runResults.Disconnects = int64(len(runResults.ConnectionEvents))
+
+	// calculate std if sample is > 1, otherwise leave as 0 (convention)
+	if len(times) > 1 {
+		runResults.MsgTimeStd = stats.StatsSampleStandardDeviation(times)
+	}
+
+	return runResults
+}
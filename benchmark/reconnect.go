@@ -0,0 +1,210 @@
+package benchmark
+
+import (
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// ReconnectOff stops the client on connection loss (default).
+	ReconnectOff = "off"
+	// ReconnectImmediate reconnects right away and retries at a short constant interval.
+	ReconnectImmediate = "immediate"
+	// ReconnectBackoff reconnects with exponential backoff up to the max interval.
+	ReconnectBackoff = "backoff"
+
+	// immediateRetryInterval is the interval b/w failed attempts of the immediate policy.
+	immediateRetryInterval = 100 * time.Millisecond
+
+	// backoffInitialInterval is the first interval of the backoff policy.
+	backoffInitialInterval = 500 * time.Millisecond
+)
+
+// ReconnectPolicy describes how clients recover from connection loss.
+// It is shared by all clients of a test.
+type ReconnectPolicy struct {
+	Mode        string
+	MaxInterval time.Duration
+
+	stop     chan bool
+	stopOnce sync.Once
+}
+
+// NewReconnectPolicy creates a reconnect policy for the given mode.
+func NewReconnectPolicy(mode string, maxInterval time.Duration) *ReconnectPolicy {
+	return &ReconnectPolicy{
+		Mode:        mode,
+		MaxInterval: maxInterval,
+		stop:        make(chan bool),
+	}
+}
+
+// Enabled returns true if the clients should reconnect.
+func (p *ReconnectPolicy) Enabled() bool {
+	return p != nil && p.Mode != ReconnectOff
+}
+
+// Stop aborts pending reconnect attempts once the test is over.
+func (p *ReconnectPolicy) Stop() {
+	if p != nil {
+		p.stopOnce.Do(func() { close(p.stop) })
+	}
+}
+
+// interval returns the delay before the given reconnect attempt (starting from 1).
+func (p *ReconnectPolicy) interval(attempt int) time.Duration {
+	if p.Mode == ReconnectImmediate {
+		if attempt == 1 {
+			return 0
+		}
+		return immediateRetryInterval
+	}
+
+	interval := backoffInitialInterval
+	for i := 1; i < attempt && interval < p.MaxInterval; i++ {
+		interval *= 2
+	}
+	if interval > p.MaxInterval {
+		interval = p.MaxInterval
+	}
+	return interval
+}
+
+func isValidReconnectMode(mode string) bool {
+	switch mode {
+	case ReconnectOff, ReconnectImmediate, ReconnectBackoff:
+		return true
+	}
+	return false
+}
+
+// ConnectionEvent describes a connection loss of a client and its recovery.
+type ConnectionEvent struct {
+	ClientID    string    `json:"client_id"`
+	Lost        time.Time `json:"lost"`
+	Reason      string    `json:"reason"`
+	Reconnected time.Time `json:"reconnected"`
+	Attempts    int       `json:"attempts"`
+
+	// ReconnectTime is the time (ms) it took to reconnect, 0 if the client did not recover.
+	ReconnectTime float64 `json:"reconnect_time"`
+}
+
+// ConnectionTracker reconnects a single client according to the policy
+// and records connection loss events and the broker node the client is connected to.
+type ConnectionTracker struct {
+	Policy *ReconnectPolicy
+
+	mu     sync.Mutex
+	events []ConnectionEvent
+	broker string
+	client mqtt.Client
+}
+
+// reconnectable is implemented by clients that support the reconnect policy.
+type reconnectable interface {
+	connectionTracker() *ConnectionTracker
+}
+
+func newConnectionTracker(policy *ReconnectPolicy) *ConnectionTracker {
+	return &ConnectionTracker{
+		Policy: policy,
+	}
+}
+
+// Events returns the recorded connection loss events.
+func (t *ConnectionTracker) Events() []ConnectionEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	events := make([]ConnectionEvent, len(t.events))
+	copy(events, t.events)
+	return events
+}
+
+// Broker returns the broker node the client first connected to, empty if it never connected.
+func (t *ConnectionTracker) Broker() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.broker
+}
+
+// connectToAny tries the brokers of the options in order until a client connects to one
+// of them (failover) and returns that client, or the last client and its token on error.
+// Every attempt uses a client with a single broker, so the connected node is known.
+func (t *ConnectionTracker) connectToAny(opts *mqtt.ClientOptions) (mqtt.Client, mqtt.Token) {
+	servers := opts.Servers
+	defer func() { opts.Servers = servers }()
+
+	var client mqtt.Client
+	var token mqtt.Token
+	for _, server := range servers {
+		opts.Servers = []*url.URL{server}
+		client = mqtt.NewClient(opts)
+		token = client.Connect()
+		token.Wait()
+		if token.Error() == nil {
+			t.mu.Lock()
+			if t.broker == "" {
+				t.broker = server.String()
+			}
+			t.client = client
+			t.mu.Unlock()
+			break
+		}
+	}
+	return client, token
+}
+
+// disconnect disconnects the latest connected client, if any. It is the one to disconnect
+// once the test is over, as every reconnect uses a new client.
+func (t *ConnectionTracker) disconnect(quiesce uint) {
+	t.mu.Lock()
+	client := t.client
+	t.mu.Unlock()
+	if client != nil && client.IsConnected() {
+		client.Disconnect(quiesce)
+	}
+}
+
+// reconnect creates new clients from the options until one of them connects or the policy is stopped.
+// A new client is used for every attempt, as paho can not reliably reconnect a disconnected client.
+func (t *ConnectionTracker) reconnect(c Client, opts *mqtt.ClientOptions, reason error) {
+	t.mu.Lock()
+	t.events = append(t.events, ConnectionEvent{
+		ClientID: c.ClientId(),
+		Lost:     time.Now(),
+		Reason:   reason.Error(),
+	})
+	idx := len(t.events) - 1
+	t.mu.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-time.After(t.Policy.interval(attempt)):
+		case <-t.Policy.stop:
+			return
+		}
+
+		t.mu.Lock()
+		t.events[idx].Attempts = attempt
+		t.mu.Unlock()
+
+		_, token := t.connectToAny(opts)
+		if token.Error() != nil {
+			log.Printf("CLIENT %v reconnect attempt %v failed: %v\n", c.ClientId(), attempt, token.Error())
+			continue
+		}
+
+		t.mu.Lock()
+		e := &t.events[idx]
+		e.Reconnected = time.Now()
+		e.ReconnectTime = float64(e.Reconnected.Sub(e.Lost).Milliseconds())
+		t.mu.Unlock()
+		log.Printf("CLIENT %v reconnected to the broker after %v attempt(s).\n", c.ClientId(), attempt)
+		return
+	}
+}
@@ -0,0 +1,102 @@
+package benchmark
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestReconnectInterval(t *testing.T) {
+	tests := []struct {
+		mode    string
+		attempt int
+		want    time.Duration
+	}{
+		{ReconnectImmediate, 1, 0},
+		{ReconnectImmediate, 2, immediateRetryInterval},
+		{ReconnectImmediate, 50, immediateRetryInterval},
+		{ReconnectBackoff, 1, 500 * time.Millisecond},
+		{ReconnectBackoff, 2, time.Second},
+		{ReconnectBackoff, 4, 4 * time.Second},
+		{ReconnectBackoff, 6, 10 * time.Second},
+		{ReconnectBackoff, 1000, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		p := NewReconnectPolicy(tt.mode, 10*time.Second)
+		if got := p.interval(tt.attempt); got != tt.want {
+			t.Errorf("%v interval of attempt %d = %v, want %v", tt.mode, tt.attempt, got, tt.want)
+		}
+	}
+	// the initial interval is capped as well
+	if got := NewReconnectPolicy(ReconnectBackoff, 100*time.Millisecond).interval(1); got != 100*time.Millisecond {
+		t.Errorf("backoff interval capped at 100ms = %v", got)
+	}
+}
+
+func TestReconnectPolicyEnabled(t *testing.T) {
+	var none *ReconnectPolicy
+	none.Stop()
+	if none.Enabled() || NewReconnectPolicy(ReconnectOff, 0).Enabled() || !NewReconnectPolicy(ReconnectBackoff, 0).Enabled() {
+		t.Error("only the immediate and backoff policies reconnect")
+	}
+	for mode, want := range map[string]bool{ReconnectOff: true, ReconnectImmediate: true, ReconnectBackoff: true, "linear": false} {
+		if got := isValidReconnectMode(mode); got != want {
+			t.Errorf("isValidReconnectMode(%v) = %v", mode, got)
+		}
+	}
+}
+
+func TestReconnectStopped(t *testing.T) {
+	p := NewReconnectPolicy(ReconnectBackoff, time.Minute)
+	p.Stop()
+	p.Stop()
+	tracker := newConnectionTracker(p)
+
+	done := make(chan bool)
+	go func() {
+		tracker.reconnect(Publisher{id: 3}, mqtt.NewClientOptions().AddBroker("tcp://127.0.0.1:1"), errors.New("EOF"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reconnect not aborted by the stopped policy")
+	}
+
+	events := tracker.Events()
+	if len(events) != 1 || events[0].ClientID != "pub-3" || events[0].Reason != "EOF" || events[0].Attempts != 0 || !events[0].Reconnected.IsZero() {
+		t.Errorf("events %+v, want a single loss without attempts", events)
+	}
+}
+
+func TestCalculateReconnectResults(t *testing.T) {
+	start := time.Now()
+	results := []*RunResults{
+		{DisconnectedFailures: 3, ConnectionEvents: []ConnectionEvent{
+			{ClientID: "pub-0", Lost: start.Add(2 * time.Second), Reconnected: start.Add(3 * time.Second), ReconnectTime: 1000},
+			{ClientID: "pub-0", Lost: start.Add(5 * time.Second)},
+		}},
+		{ConnectionEvents: []ConnectionEvent{
+			{ClientID: "pub-1", Lost: start, Reconnected: start.Add(200 * time.Millisecond), ReconnectTime: 200},
+		}},
+		{},
+	}
+	res := calculateReconnectResults(results, ReconnectImmediate)
+	if res.Policy != ReconnectImmediate || res.Disconnects != 3 || res.Reconnects != 2 || res.DisconnectedFailures != 3 {
+		t.Errorf("policy %v, %d disconnects, %d reconnects, %d failures, want immediate, 3, 2, 3",
+			res.Policy, res.Disconnects, res.Reconnects, res.DisconnectedFailures)
+	}
+	if res.ReconnectTimeMin != 200 || res.ReconnectTimeMax != 1000 || res.ReconnectTimeMean != 600 {
+		t.Errorf("reconnect time min %v, max %v, mean %v, want 200, 1000, 600", res.ReconnectTimeMin, res.ReconnectTimeMax, res.ReconnectTimeMean)
+	}
+	for i, want := range []string{"pub-1", "pub-0", "pub-0"} {
+		if res.Timeline[i].ClientID != want {
+			t.Errorf("timeline event %d of %v, want %v", i, res.Timeline[i].ClientID, want)
+		}
+	}
+	if !res.Timeline[2].Reconnected.IsZero() {
+		t.Error("timeline not ordered by the time of the loss")
+	}
+}
@@ -0,0 +1,186 @@
+package benchmark
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/GaryBoone/GoStats/stats"
+)
+
+// repeatMetrics are the key metrics summarized across repeated runs, if available.
+var repeatMetrics = []string{
+	"ratio",
+	"total_msgs_per_sec",
+	"avg_msgs_per_sec",
+	"msg_time_mean_mean",
+	"msg_time_p50",
+	"msg_time_p90",
+	"msg_time_p99",
+	"total_run_time",
+	"request.requests_per_sec",
+	"request.rtt_p50",
+	"request.rtt_p99",
+	"connect.connects_per_sec",
+	"connect.connect_time_p99",
+}
+
+// outlierThreshold is the modified z-score above which a run is an outlier (Iglewicz and Hoaglin).
+const outlierThreshold = 3.5
+
+// RepeatResults summarizes the key metrics of repeated runs of the same configuration.
+type RepeatResults struct {
+	Runs    int              `json:"runs"`
+	Metrics []*MetricSummary `json:"metrics"`
+
+	// Outliers are the runs (1-based) which are outliers in any of the metrics.
+	Outliers []int `json:"outliers,omitempty"`
+}
+
+// MetricSummary describes the distribution of a metric across repeated runs.
+type MetricSummary struct {
+	Metric string    `json:"metric"`
+	Values []float64 `json:"values"`
+	Mean   float64   `json:"mean"`
+	Std    float64   `json:"std"`
+
+	// CILow and CIHigh are the bounds of the 95% confidence interval of the mean.
+	CILow  float64 `json:"ci95_low"`
+	CIHigh float64 `json:"ci95_high"`
+
+	// Outliers are the runs (1-based) with a modified z-score above 3.5.
+	Outliers []int `json:"outliers,omitempty"`
+}
+
+// RepeatedJSONResults are used to export the results of repeated runs as a JSON document.
+type RepeatedJSONResults struct {
+	Results []*JSONResults `json:"results"`
+	Repeat  *RepeatResults `json:"repeat"`
+}
+
+// CalculateRepeatResults summarizes the total results of repeated runs.
+func CalculateRepeatResults(totals []*TotalResults) *RepeatResults {
+	res := &RepeatResults{Runs: len(totals)}
+	outliers := make(map[int]bool)
+metrics:
+	for _, metric := range repeatMetrics {
+		path := strings.Split(metric, ".")
+		values := make([]float64, len(totals))
+		for i, t := range totals {
+			v, ok := metricValue(reflect.ValueOf(t), path)
+			if !ok {
+				continue metrics
+			}
+			values[i] = v
+		}
+
+		s := &MetricSummary{Metric: metric, Values: values, Mean: stats.StatsMean(values)}
+		s.CILow, s.CIHigh = s.Mean, s.Mean
+		// calculate std if # of runs is > 1, otherwise leave as 0 (convention)
+		if len(values) > 1 {
+			s.Std = stats.StatsSampleStandardDeviation(values)
+			margin := tCritical95(len(values)-1) * s.Std / math.Sqrt(float64(len(values)))
+			s.CILow, s.CIHigh = s.Mean-margin, s.Mean+margin
+		}
+		s.Outliers = findOutliers(values)
+		for _, run := range s.Outliers {
+			outliers[run] = true
+		}
+		res.Metrics = append(res.Metrics, s)
+	}
+
+	for run := range outliers {
+		res.Outliers = append(res.Outliers, run)
+	}
+	sort.Ints(res.Outliers)
+	return res
+}
+
+// findOutliers returns the runs (1-based) with a modified z-score of the value above the threshold.
+// The score is based on the median absolute deviation, so it is robust to the outliers themselves.
+func findOutliers(values []float64) []int {
+	if len(values) < 3 {
+		return nil
+	}
+	m := median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - m)
+	}
+	mad := median(deviations)
+	if mad == 0 {
+		return nil
+	}
+
+	var outliers []int
+	for i, v := range values {
+		if math.Abs(0.6745*(v-m)/mad) > outlierThreshold {
+			outliers = append(outliers, i+1)
+		}
+	}
+	return outliers
+}
+
+// median returns the median of the values without modifying them.
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// tCritical95 returns the two-sided 95% critical value of Student's t-distribution.
+func tCritical95(df int) float64 {
+	table := []float64{
+		12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+	}
+	if df < 1 {
+		return math.NaN()
+	}
+	if df <= len(table) {
+		return table[df-1]
+	}
+
+	// beyond the table the values are interpolated in 1/df, which is close to linear,
+	// b/w the known points up to the normal distribution (df = infinity)
+	points := []struct {
+		df int
+		t  float64
+	}{{30, 2.042}, {40, 2.021}, {60, 2.000}, {120, 1.980}, {0, 1.960}}
+	for i := 1; i < len(points); i++ {
+		lo, hi := points[i-1], points[i]
+		if df <= hi.df || hi.df == 0 {
+			x, x0, x1 := 1/float64(df), 1/float64(lo.df), 0.0
+			if hi.df > 0 {
+				x1 = 1 / float64(hi.df)
+			}
+			return lo.t + (hi.t-lo.t)*(x0-x)/(x0-x1)
+		}
+	}
+	return 1.960
+}
+
+// PrintRepeatResults prints the summary of repeated runs.
+func PrintRepeatResults(r *RepeatResults) {
+	fmt.Printf("========= REPEAT =========\n")
+	fmt.Printf("Number of Runs:                   %v\n", r.Runs)
+	for _, m := range r.Metrics {
+		fmt.Printf("%-34s%.3f ± %.3f (95%% CI %.3f..%.3f)\n", m.Metric+":", m.Mean, m.Std, m.CILow, m.CIHigh)
+	}
+	if len(r.Outliers) > 0 {
+		fmt.Printf("Outlier Runs:                     %v\n", strings.Trim(fmt.Sprint(r.Outliers), "[]"))
+		for _, m := range r.Metrics {
+			if len(m.Outliers) > 0 {
+				fmt.Printf("%-34s%v\n", "  "+m.Metric+":", strings.Trim(fmt.Sprint(m.Outliers), "[]"))
+			}
+		}
+	}
+	fmt.Printf("==============================\n")
+}
@@ -0,0 +1,64 @@
+package benchmark
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTCritical95(t *testing.T) {
+	// two-sided 95% critical values of Student's t-distribution
+	tests := []struct {
+		df   int
+		want float64
+	}{
+		{1, 12.706},
+		{2, 4.303},
+		{10, 2.228},
+		{30, 2.042},
+		{35, 2.030},
+		{40, 2.021},
+		{45, 2.014},
+		{50, 2.009},
+		{60, 2.000},
+		{80, 1.990},
+		{100, 1.984},
+		{120, 1.980},
+		{1000, 1.962},
+	}
+	for _, tt := range tests {
+		if got := tCritical95(tt.df); !almostEqual(got, tt.want, 0.001) {
+			t.Errorf("tCritical95(%d) = %.4f, want %.3f", tt.df, got, tt.want)
+		}
+	}
+	if got := tCritical95(0); !math.IsNaN(got) {
+		t.Errorf("tCritical95(0) = %v, want NaN", got)
+	}
+	for df := 2; df < 2000; df++ {
+		if tCritical95(df) > tCritical95(df-1) {
+			t.Fatalf("tCritical95(%d) = %.4f > tCritical95(%d) = %.4f", df, tCritical95(df), df-1, tCritical95(df-1))
+		}
+	}
+}
+
+func TestFindOutliers(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   []int
+	}{
+		// too few runs to tell
+		{[]float64{1, 100}, nil},
+		// median 11, MAD 1: the modified z-score of 30 is 0.6745*19 = 12.8
+		{[]float64{10, 11, 12, 11, 10, 30}, []int{6}},
+		// the z-score of 14 is 0.6745*3 = 2.0
+		{[]float64{10, 11, 12, 11, 10, 14}, nil},
+		{[]float64{100, 5, 101, 99, 100, 180}, []int{2, 6}},
+		// MAD 0, the scores are not defined
+		{[]float64{5, 5, 5, 9}, nil},
+	}
+	for _, tt := range tests {
+		if got := findOutliers(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findOutliers(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package benchmark
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chart dimensions and margins of the plot area (px).
+const (
+	chartWidth   = 900
+	chartHeight  = 300
+	chartLeft    = 70
+	chartRight   = 20
+	chartTop     = 20
+	chartBottom  = 40
+	chartXTicks  = 8
+	chartYTicks  = 5
+	chartMaxBars = 2000
+)
+
+var chartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd"}
+
+// series is a line of a chart.
+type series struct {
+	Name   string
+	Points [][2]float64
+}
+
+// chart renders line and bar charts as inline SVG, so the report needs no scripts or assets.
+type chart struct {
+	xMin, xMax, yMin, yMax float64
+	xLog                   bool
+	xLabel, yLabel         string
+	b                      strings.Builder
+}
+
+func newChart(xMin, xMax, yMax float64, xLabel, yLabel string) *chart {
+	if xMax <= xMin {
+		xMax = xMin + 1
+	}
+	if yMax <= 0 {
+		yMax = 1
+	}
+	return &chart{xMin: xMin, xMax: xMax, yMax: yMax * 1.05, xLabel: xLabel, yLabel: yLabel}
+}
+
+func (c *chart) x(v float64) float64 {
+	w := float64(chartWidth - chartLeft - chartRight)
+	if c.xLog {
+		return chartLeft + w*(math.Log10(v)-math.Log10(c.xMin))/(math.Log10(c.xMax)-math.Log10(c.xMin))
+	}
+	return chartLeft + w*(v-c.xMin)/(c.xMax-c.xMin)
+}
+
+func (c *chart) y(v float64) float64 {
+	h := float64(chartHeight - chartTop - chartBottom)
+	return chartTop + h - h*(v-c.yMin)/(c.yMax-c.yMin)
+}
+
+func (c *chart) axes() {
+	fmt.Fprintf(&c.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	bottom, right := float64(chartHeight-chartBottom), float64(chartWidth-chartRight)
+	for i := 0; i <= chartYTicks; i++ {
+		v := c.yMin + (c.yMax-c.yMin)*float64(i)/chartYTicks
+		y := c.y(v)
+		fmt.Fprintf(&c.b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#eee"/>`, chartLeft, y, right, y)
+		fmt.Fprintf(&c.b, `<text x="%d" y="%.1f" text-anchor="end" font-size="11">%s</text>`, chartLeft-5, y+4, formatTick(v))
+	}
+	for i := 0; i <= chartXTicks; i++ {
+		var v float64
+		if c.xLog {
+			v = math.Pow(10, math.Log10(c.xMin)+(math.Log10(c.xMax)-math.Log10(c.xMin))*float64(i)/chartXTicks)
+		} else {
+			v = c.xMin + (c.xMax-c.xMin)*float64(i)/chartXTicks
+		}
+		fmt.Fprintf(&c.b, `<text x="%.1f" y="%.1f" text-anchor="middle" font-size="11">%s</text>`, c.x(v), bottom+15, formatTick(v))
+	}
+	fmt.Fprintf(&c.b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#333"/>`, chartLeft, bottom, right, bottom)
+	fmt.Fprintf(&c.b, `<line x1="%d" y1="%d" x2="%d" y2="%.1f" stroke="#333"/>`, chartLeft, chartTop, chartLeft, bottom)
+	fmt.Fprintf(&c.b, `<text x="%.1f" y="%d" text-anchor="middle" font-size="12">%s</text>`, (chartLeft+right)/2, chartHeight-5, template.HTMLEscapeString(c.xLabel))
+	fmt.Fprintf(&c.b, `<text x="15" y="%.1f" text-anchor="middle" font-size="12" transform="rotate(-90 15 %.1f)">%s</text>`, (chartTop+bottom)/2, (chartTop+bottom)/2, template.HTMLEscapeString(c.yLabel))
+}
+
+func (c *chart) lines(lines []series) {
+	for i, s := range lines {
+		color := chartColors[i%len(chartColors)]
+		points := make([]string, len(s.Points))
+		for j, p := range s.Points {
+			points[j] = fmt.Sprintf("%.1f,%.1f", c.x(p[0]), c.y(p[1]))
+		}
+		fmt.Fprintf(&c.b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`, color, strings.Join(points, " "))
+		fmt.Fprintf(&c.b, `<text x="%d" y="%d" font-size="12" fill="%s">%s</text>`, chartLeft+10+i*110, chartTop+12, color, template.HTMLEscapeString(s.Name))
+	}
+}
+
+func (c *chart) svg() template.HTML {
+	return template.HTML(c.b.String() + "</svg>")
+}
+
+func formatTick(v float64) string {
+	switch a := math.Abs(v); {
+	case a >= 1e6:
+		return strconv.FormatFloat(v/1e6, 'f', 1, 64) + "M"
+	case a >= 1e4:
+		return strconv.FormatFloat(v/1e3, 'f', 1, 64) + "k"
+	case a >= 100 || a == 0:
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	case a >= 1:
+		return strconv.FormatFloat(v, 'f', 1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', 2, 64)
+}
+
+// throughputChart plots the message rate of every interval.
+func throughputChart(intervals []IntervalEvent, start time.Time) template.HTML {
+	s := series{Name: "msgs/sec"}
+	var yMax float64
+	for _, e := range intervals {
+		s.Points = append(s.Points, [2]float64{e.End.Sub(start).Seconds(), e.MsgsPerSec})
+		yMax = math.Max(yMax, e.MsgsPerSec)
+	}
+	c := newChart(0, intervals[len(intervals)-1].End.Sub(start).Seconds(), yMax, "time (s)", "msgs/sec")
+	c.axes()
+	c.lines([]series{s})
+	return c.svg()
+}
+
+// latencyChart plots the latency percentiles of every interval.
+func latencyChart(intervals []IntervalEvent, start time.Time) template.HTML {
+	lines := []series{{Name: "p50"}, {Name: "p99"}, {Name: "max"}, {Name: "mean"}}
+	var yMax float64
+	for _, e := range intervals {
+		if e.LatencyMax == 0 {
+			continue
+		}
+		t := e.End.Sub(start).Seconds()
+		for i, v := range []float64{e.LatencyP50, e.LatencyP99, e.LatencyMax, e.LatencyMean} {
+			lines[i].Points = append(lines[i].Points, [2]float64{t, v})
+		}
+		yMax = math.Max(yMax, e.LatencyMax)
+	}
+	c := newChart(0, intervals[len(intervals)-1].End.Sub(start).Seconds(), yMax, "time (s)", "latency (ms)")
+	c.axes()
+	c.lines(lines)
+	return c.svg()
+}
+
+// histogramChart plots the buckets of the latency histogram on a logarithmic latency axis.
+func histogramChart(h *Histogram) template.HTML {
+	var yMax float64
+	for _, b := range h.Buckets {
+		yMax = math.Max(yMax, float64(b.Count))
+	}
+	first, last := h.Buckets[0], h.Buckets[len(h.Buckets)-1]
+	xMin := first.Lower
+	if xMin <= 0 {
+		xMin = first.Upper / histogramGrowth
+	}
+	c := newChart(xMin, last.Upper, yMax, "latency (ms)", "messages")
+	c.xLog = true
+	c.axes()
+	for _, b := range h.Buckets {
+		lower := b.Lower
+		if lower <= 0 {
+			lower = xMin
+		}
+		x, w := c.x(lower), math.Max(c.x(b.Upper)-c.x(lower), 1)
+		y := c.y(float64(b.Count))
+		fmt.Fprintf(&c.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%.3f-%.3f ms: %d</title></rect>`,
+			x, y, w, float64(chartHeight-chartBottom)-y, chartColors[0], lower, b.Upper, b.Count)
+	}
+	return c.svg()
+}
+
+// clientsChart plots the min, mean and max latency of every client.
+func clientsChart(runs []*RunResults) template.HTML {
+	sorted := append([]*RunResults(nil), runs...)
+	sort.Slice(sorted, func(i, j int) bool { return clientLess(sorted[i].ID, sorted[j].ID) })
+	if len(sorted) > chartMaxBars {
+		sorted = sorted[:chartMaxBars]
+	}
+	var yMax float64
+	for _, r := range sorted {
+		yMax = math.Max(yMax, r.MsgTimeMax)
+	}
+	c := newChart(0, float64(len(sorted)), yMax, "client", "latency (ms)")
+	c.axes()
+	for i, r := range sorted {
+		x := c.x(float64(i) + 0.5)
+		fmt.Fprintf(&c.b, `<g><title>%s: min %.3f, mean %.3f, max %.3f ms, %d msgs</title>`,
+			template.HTMLEscapeString(r.ID), r.MsgTimeMin, r.MsgTimeMean, r.MsgTimeMax, r.Successes)
+		fmt.Fprintf(&c.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`, x, c.y(r.MsgTimeMin), x, c.y(r.MsgTimeMax), chartColors[0])
+		fmt.Fprintf(&c.b, `<circle cx="%.1f" cy="%.1f" r="2.5" fill="%s"/></g>`, x, c.y(r.MsgTimeMean), chartColors[1])
+	}
+	return c.svg()
+}
+
+// clientLess orders the client ids by the prefix and then by the index at the end,
+// so pub-2 comes before pub-10.
+func clientLess(a, b string) bool {
+	prefixA, indexA := splitClientID(a)
+	prefixB, indexB := splitClientID(b)
+	if prefixA != prefixB {
+		return prefixA < prefixB
+	}
+	return indexA < indexB
+}
+
+// splitClientID returns the prefix and the index of the client id, -1 without an index.
+func splitClientID(id string) (string, int) {
+	i := len(id)
+	for i > 0 && id[i-1] >= '0' && id[i-1] <= '9' {
+		i--
+	}
+	index, err := strconv.Atoi(id[i:])
+	if err != nil {
+		return id, -1
+	}
+	return id[:i], index
+}
+
+type reportRow struct {
+	Name  string
+	Value string
+}
+
+type reportChart struct {
+	Title string
+	SVG   template.HTML
+}
+
+type reportData struct {
+	Title  string
+	Totals []reportRow
+	Charts []reportChart
+	Notes  []string
+}
+
+// reportRows returns the test parameters and all metrics of the total results.
+func reportRows(t *TotalResults) []reportRow {
+	rows := []reportRow{
+		{"run_id", t.TestRunID},
+		{"test_case_id", t.TestCaseID},
+		{"run_instance", t.TestInstance},
+		{"run_type", t.TestRunType},
+		{"test_start_time", t.TestStart.Format(time.RFC3339)},
+		{"test_end_time", t.TestEnd.Format(time.RFC3339)},
+	}
+	for _, metric := range metricNames(reflect.TypeOf(TotalResults{}), "") {
+		if v, ok := metricValue(reflect.ValueOf(t), strings.Split(metric, ".")); ok {
+			rows = append(rows, reportRow{metric, strconv.FormatFloat(v, 'f', -1, 64)})
+		}
+	}
+	return rows
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; font-size: 13px; }
+td { border-bottom: 1px solid #eee; padding: 3px 12px 3px 0; }
+td.value { text-align: right; font-family: monospace; }
+.note { color: #888; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Charts}}<h2>{{.Title}}</h2>
+{{.SVG}}
+{{end}}{{range .Notes}}<p class="note">{{.}}</p>
+{{end}}<h2>Total Results</h2>
+<table>
+{{range .Totals}}<tr><td>{{.Name}}</td><td class="value">{{.Value}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteReport writes the results as a self-contained HTML page with charts of the
+// throughput and latencies over time, the latency histogram, the latencies per client
+// and a table of the total results.
+func WriteReport(w io.Writer, res *JSONResults) error {
+	t := res.Totals
+	data := reportData{Title: "mqtt-benchmark " + t.TestRunType, Totals: reportRows(t)}
+	if t.TestCaseID != "" {
+		data.Title += " - " + t.TestCaseID
+	}
+
+	if len(t.Intervals) > 0 {
+		start := t.Intervals[0].Start
+		data.Charts = append(data.Charts,
+			reportChart{"Throughput", throughputChart(t.Intervals, start)},
+			reportChart{"Latency Percentiles", latencyChart(t.Intervals, start)})
+	} else {
+		data.Notes = append(data.Notes, "No interval time series, run the benchmark with -interval for throughput and latency over time.")
+	}
+	if h := t.MsgTimeHistogram; h != nil && len(h.Buckets) > 0 {
+		data.Charts = append(data.Charts, reportChart{"Latency Histogram", histogramChart(h)})
+	} else {
+		data.Notes = append(data.Notes, "No latency histogram in the results.")
+	}
+	if len(res.Runs) > 0 {
+		title := "Latency per Client (min, mean, max)"
+		if len(res.Runs) > chartMaxBars {
+			title += fmt.Sprintf(", first %d of %d clients", chartMaxBars, len(res.Runs))
+		}
+		data.Charts = append(data.Charts, reportChart{title, clientsChart(res.Runs)})
+	}
+	return reportTemplate.Execute(w, data)
+}
@@ -0,0 +1,73 @@
+package benchmark
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteReport(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	res := &JSONResults{
+		Runs: []*RunResults{
+			{ID: `pub-0<script>alert("x")</script>`, Successes: 10, MsgTimeMin: 1, MsgTimeMean: 2, MsgTimeMax: 4},
+			{ID: "pub-1", Successes: 10, MsgTimeMin: 1, MsgTimeMean: 3, MsgTimeMax: 5},
+		},
+		Totals: &TotalResults{
+			TestRunType:  "pub",
+			TestInstance: "host<b>1</b>",
+			TestStart:    start,
+			TestEnd:      start.Add(2 * time.Second),
+			Intervals: []IntervalEvent{
+				{Start: start, End: start.Add(time.Second), Published: 10, MsgsPerSec: 10, LatencyMean: 2, LatencyP50: 2, LatencyP99: 4, LatencyMax: 4},
+				{Start: start.Add(time.Second), End: start.Add(2 * time.Second), Published: 10, MsgsPerSec: 10, LatencyMean: 3, LatencyP50: 3, LatencyP99: 5, LatencyMax: 5},
+			},
+			MsgTimeHistogram: &Histogram{Count: 20, Min: 1, Max: 5, Buckets: []HistogramBucket{
+				{Index: 0, Lower: 0, Upper: 1, Count: 2},
+				{Index: 5, Lower: 1, Upper: 2, Count: 12},
+				{Index: 9, Lower: 4, Upper: 5, Count: 6},
+			}},
+		},
+	}
+
+	var b bytes.Buffer
+	if err := WriteReport(&b, res); err != nil {
+		t.Fatal(err)
+	}
+	html := b.String()
+	for _, title := range []string{"<h2>Throughput</h2>", "<h2>Latency Percentiles</h2>", "<h2>Latency Histogram</h2>", "<h2>Latency per Client (min, mean, max)</h2>"} {
+		i := strings.Index(html, title)
+		if i < 0 || !strings.HasPrefix(html[i+len(title):], "\n<svg ") {
+			t.Errorf("report without the chart %v", title)
+		}
+	}
+	if n := strings.Count(html, "<rect "); n != 3 {
+		t.Errorf("%d histogram bars, want 3", n)
+	}
+	if strings.Contains(html, "No interval time series") || strings.Contains(html, "No latency histogram") {
+		t.Error("report notes missing charts")
+	}
+
+	for _, raw := range []string{"<script>", "<b>1</b>"} {
+		if strings.Contains(html, raw) {
+			t.Errorf("report contains unescaped %v", raw)
+		}
+	}
+	for _, escaped := range []string{"pub-0&lt;script&gt;", "host&lt;b&gt;1&lt;/b&gt;"} {
+		if !strings.Contains(html, escaped) {
+			t.Errorf("report without the escaped name %v", escaped)
+		}
+	}
+}
+
+func TestClientLess(t *testing.T) {
+	ids := []string{"pub-10", "sub-1", "pub-2", "host/pub-1", "pub-1", "pub", "pub-11"}
+	sort.Slice(ids, func(i, j int) bool { return clientLess(ids[i], ids[j]) })
+	want := []string{"host/pub-1", "pub", "pub-1", "pub-2", "pub-10", "pub-11", "sub-1"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("sorted %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,287 @@
+package benchmark
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// ModeRequest runs requester clients that send requests to responder clients
+// and wait for the responses, to measure request/response round-trip time.
+const ModeRequest = "request"
+
+// getRequestTopic returns the request topic with the given index.
+func getRequestTopic(i int) string {
+	return fmt.Sprintf("/rpc/req%d", i)
+}
+
+// Responder subscribes to a part of the request topics and publishes every request
+// back to its reply topic. Request topic i is served by responder i % ResponderCount,
+// so every request gets exactly one response.
+type Responder struct {
+	id             int
+	brokerURL      string
+	brokerUser     string
+	brokerPass     string
+	ids            *clientIDRegistry
+	ResponderCount int
+	TopicsCount    int
+	MsgQoS         byte
+	Quiet          bool
+	Panic          bool
+
+	// Ready receives a value once the responder is subscribed (or failed to connect),
+	// Stop stops the responder once the requesters are done.
+	Ready chan bool
+	Stop  chan bool
+}
+
+func (c Responder) ClientId() string {
+	return fmt.Sprintf("resp-%d", c.id)
+}
+
+func (c Responder) BrokerUrl() string {
+	return c.brokerURL
+}
+
+func (c Responder) BrokerUser() string {
+	return c.brokerUser
+}
+
+func (c Responder) BrokerPass() string {
+	return c.brokerPass
+}
+
+func (c Responder) PanicMode() bool {
+	return c.Panic
+}
+
+func (c Responder) clientIDs() *clientIDRegistry {
+	return c.ids
+}
+
+// Run echoes requests until stopped.
+func (c Responder) Run(res chan *RunResults) {
+	runResults := &RunResults{
+		ID: c.ClientId(),
+	}
+	echoed := make(chan bool)
+
+	onRequest := func(client mqtt.Client, m mqtt.Message) {
+		h, ok := readRequestHeader(m.Payload())
+		if !ok {
+			return // not a request
+		}
+		// publish asynchronously, paho does not complete tokens from within handlers
+		client.Publish(h.ReplyTopic, c.MsgQoS, false, m.Payload())
+		select {
+		case echoed <- true:
+		case <-c.Stop:
+		}
+	}
+
+	onConnected := func(client mqtt.Client) {
+		topics := make(map[string]byte)
+		for i := c.id; i < c.TopicsCount; i += c.ResponderCount {
+			topics[getRequestTopic(i)] = c.MsgQoS
+		}
+		token := client.SubscribeMultiple(topics, onRequest)
+		token.Wait()
+		if token.Error() != nil {
+			log.Printf("CLIENT %v Error subscribing to the topic %v: %v\n", c.ClientId(), topics, token.Error())
+			if c.Panic {
+				panic(token.Error())
+			}
+		}
+		if !c.Quiet {
+			log.Printf("CLIENT %v is connected to the broker %v and serving topic(s) %v\n", c.ClientId(), c.BrokerUrl(), topics)
+		}
+		c.Ready <- true
+	}
+
+	start := time.Now()
+	client := connect(c, onConnected)
+	if !client.IsConnected() {
+		c.Ready <- false
+		runResults.Failures++
+		<-c.Stop
+		res <- runResults
+		return
+	}
+
+loop:
+	for {
+		select {
+		case <-echoed:
+			runResults.Successes++
+		case <-c.Stop:
+			break loop
+		}
+	}
+	client.Disconnect(250)
+	runResults.ClientRunTime = time.Since(start).Seconds()
+	res <- runResults
+}
+
+// Requester sends requests one at a time and waits for the response to each of them
+// until RequestTimeout. Responses are matched by the correlation id in the payload.
+type Requester struct {
+	id          int
+	brokerURL   string
+	brokerUser  string
+	brokerPass  string
+	ids         *clientIDRegistry
+	TopicsCount int
+	MsgSize     int
+	MsgQoS      byte
+	Quiet       bool
+	Panic       bool
+
+	// MsgCount is the number of requests to send, 0 to send them for TestDuration instead.
+	MsgCount     int
+	TestDuration time.Duration
+
+	// RequestTimeout is the max time to wait for a response.
+	RequestTimeout time.Duration
+
+	// Stop is closed to stop sending requests before the test is over, nil if not used.
+	Stop chan bool
+}
+
+func (c Requester) ClientId() string {
+	return fmt.Sprintf("req-%d", c.id)
+}
+
+func (c Requester) BrokerUrl() string {
+	return c.brokerURL
+}
+
+func (c Requester) BrokerUser() string {
+	return c.brokerUser
+}
+
+func (c Requester) BrokerPass() string {
+	return c.brokerPass
+}
+
+func (c Requester) PanicMode() bool {
+	return c.Panic
+}
+
+func (c Requester) clientIDs() *clientIDRegistry {
+	return c.ids
+}
+
+func (c Requester) Run(res chan *RunResults) {
+	runResults := &RunResults{
+		ID: c.ClientId(),
+	}
+	responses := make(chan requestHeader, 100)
+	stopped := make(chan bool)
+
+	client := mqtt.NewClient(newClientOptions(c, nil))
+	start := time.Now()
+	token := client.Connect()
+	token.Wait()
+	if token.Error() != nil {
+		log.Printf("CLIENT %v had error connecting to the broker: %v\n", c.ClientId(), token.Error())
+		if c.Panic {
+			panic(token.Error())
+		}
+		runResults.Failures++
+		runResults.ClientRunTime = time.Since(start).Seconds()
+		res <- runResults
+		return
+	}
+
+	replyTopic := fmt.Sprintf("/rpc/reply/%s", c.ClientId())
+	onResponse := func(client mqtt.Client, m mqtt.Message) {
+		h, ok := readRequestHeader(m.Payload())
+		if !ok {
+			return
+		}
+		select {
+		case responses <- h:
+		case <-stopped:
+		}
+	}
+	token = client.Subscribe(replyTopic, c.MsgQoS, onResponse)
+	token.Wait()
+	if token.Error() != nil {
+		log.Printf("CLIENT %v Error subscribing to the topic %v: %v\n", c.ClientId(), replyTopic, token.Error())
+		if c.Panic {
+			panic(token.Error())
+		}
+	}
+	if !c.Quiet {
+		log.Printf("CLIENT %v is connected to the broker %v and waiting for responses on %v\n", c.ClientId(), c.BrokerUrl(), replyTopic)
+	}
+
+	testTimer := time.NewTimer(c.TestDuration)
+	topic := getRequestTopic(c.id % c.TopicsCount)
+loop:
+	for seq := 0; c.MsgCount == 0 || seq < c.MsgCount; seq++ {
+		select {
+		case <-testTimer.C:
+			break loop
+		case <-c.Stop:
+			break loop
+		default:
+		}
+
+		h := requestHeader{
+			payloadHeader: payloadHeader{Sent: time.Now(), Publisher: c.id, Seq: seq},
+			CorrelationID: uint64(c.id)<<32 | uint64(seq),
+			ReplyTopic:    replyTopic,
+		}
+		token := client.Publish(topic, c.MsgQoS, false, newRequestPayload(c.MsgSize, h))
+		token.Wait()
+		if token.Error() != nil {
+			log.Printf("CLIENT %v Error sending request: %v\n", c.ClientId(), token.Error())
+			if c.Panic {
+				panic(token.Error())
+			}
+			runResults.Failures++
+			continue
+		}
+
+		timeout := time.NewTimer(c.RequestTimeout)
+	wait:
+		for {
+			select {
+			case r := <-responses:
+				if r.CorrelationID != h.CorrelationID {
+					// late response to a timed out request, or not ours
+					runResults.Unmatched++
+					continue
+				}
+				runResults.Successes++
+				runResults.RequestTimes = append(runResults.RequestTimes, float64(time.Since(h.Sent).Microseconds())/1000)
+				timeout.Stop()
+				break wait
+			case <-timeout.C:
+				runResults.Failures++
+				runResults.Timeouts++
+				break wait
+			case <-c.Stop:
+				timeout.Stop()
+				break loop
+			}
+		}
+	}
+
+	if !c.Quiet {
+		log.Printf("CLIENT %v is done sending requests\n", c.ClientId())
+	}
+	// count late responses to the last requests
+	for len(responses) > 0 {
+		<-responses
+		runResults.Unmatched++
+	}
+	close(stopped)
+	client.Disconnect(250)
+	runResults.ClientRunTime = time.Since(start).Seconds()
+	res <- runResults
+}
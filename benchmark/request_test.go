@@ -0,0 +1,69 @@
+package benchmark
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRequestHeader(t *testing.T) {
+	h := requestHeader{
+		payloadHeader: payloadHeader{Sent: time.Unix(0, 1600000000123456789), Publisher: 2, Seq: 9},
+		CorrelationID: 1<<40 + 5,
+		ReplyTopic:    "/rpc/reply/req-2",
+	}
+	tests := []struct {
+		size int
+		want int
+	}{
+		{100, 100},
+		// grown to fit the header and the reply topic
+		{10, requestHeaderSize + len(h.ReplyTopic)},
+	}
+	for _, tt := range tests {
+		payload := newRequestPayload(tt.size, h)
+		if len(payload) != tt.want {
+			t.Errorf("payload of size %d has %d bytes, want %d", tt.size, len(payload), tt.want)
+		}
+		got, ok := readRequestHeader(payload)
+		if !ok || !got.Sent.Equal(h.Sent) || got.Publisher != h.Publisher || got.Seq != h.Seq ||
+			got.CorrelationID != h.CorrelationID || got.ReplyTopic != h.ReplyTopic {
+			t.Errorf("read header %+v, %v, want %+v", got, ok, h)
+		}
+	}
+
+	truncated := newRequestPayload(0, h)
+	for name, payload := range map[string][]byte{
+		"without header": make([]byte, 100),
+		"short":          truncated[:requestHeaderSize-1],
+		"truncated":      truncated[:len(truncated)-1],
+	} {
+		if _, ok := readRequestHeader(payload); ok {
+			t.Errorf("%v: read a request header", name)
+		}
+	}
+}
+
+func TestCalculateRequestResults(t *testing.T) {
+	results := []*RunResults{
+		{Successes: 3, Failures: 1, Timeouts: 1, Unmatched: 2, RequestTimes: []float64{2, 4, 6}},
+		{Successes: 1, RequestTimes: []float64{8}},
+	}
+	responders := []*RunResults{{Successes: 3}, {Successes: 2}}
+
+	res := calculateRequestResults(results, responders, 2)
+	if res.Responders != 2 || res.Requests != 5 || res.Responses != 4 || res.Echoed != 5 || res.Timeouts != 1 || res.Unmatched != 2 {
+		t.Errorf("request results %+v", res)
+	}
+	if res.RequestsPerSec != 2 {
+		t.Errorf("%v requests per second, want 2", res.RequestsPerSec)
+	}
+	if res.RTTMin != 2 || res.RTTMax != 8 || res.RTTMean != 5 || math.Abs(res.RTTStd-2.581988897) > 1e-9 {
+		t.Errorf("rtt min %v, max %v, mean %v, std %v, want 2, 8, 5, 2.582", res.RTTMin, res.RTTMax, res.RTTMean, res.RTTStd)
+	}
+
+	empty := calculateRequestResults(nil, nil, 0)
+	if empty.RequestsPerSec != 0 || empty.RTTStd != 0 {
+		t.Errorf("results without requests: %+v", empty)
+	}
+}
@@ -0,0 +1,1341 @@
+package benchmark
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/GaryBoone/GoStats/stats"
+)
+
+// RunResults describes results of a single client / run
+type RunResults struct {
+	ID            string  `json:"id"`
+	Successes     int64   `json:"successes"`
+	Failures      int64   `json:"failures"`
+	ClientRunTime float64 `json:"run_time"`
+	MsgTimeMin    float64 `json:"msg_time_min"`
+	MsgTimeMax    float64 `json:"msg_time_max"`
+	MsgTimeMean   float64 `json:"msg_time_mean"`
+	MsgTimeStd    float64 `json:"msg_time_std"`
+	Group         string  `json:"group,omitempty"`
+	Broker        string  `json:"broker,omitempty"`
+
+	// MsgTimes are the latencies (ms) of all messages of a publisher or subscriber,
+	// used to calculate percentiles across clients. They are only exported if the
+	// raw samples are kept.
+	MsgTimes []float64 `json:"msg_times,omitempty"`
+
+	// Idle is true if the subscriber stopped after no messages arrived for the idle timeout,
+	// which is not part of its run time.
+	Idle bool `json:"-"`
+
+	// RetainedSetTime is the time (ms) from subscribing until the full retained set
+	// was received, 0 if the set is incomplete.
+	RetainedSetTime float64 `json:"retained_set_time,omitempty"`
+
+	// ConnectTime is the CONNECT->CONNACK latency (ms) of a connection storm client.
+	ConnectTime float64 `json:"connect_time,omitempty"`
+
+	// Refused is the number of connections refused by the broker with a CONNACK return code.
+	Refused int64 `json:"refused,omitempty"`
+
+	// Stale is the number of messages delivered to filters the churn client
+	// was no longer subscribed to.
+	Stale int64 `json:"stale,omitempty"`
+
+	// SubscribeTimes and UnsubscribeTimes are SUBSCRIBE->SUBACK and UNSUBSCRIBE->UNSUBACK
+	// latencies (ms) of a churn client, used to calculate percentiles across clients.
+	SubscribeTimes   []float64 `json:"-"`
+	UnsubscribeTimes []float64 `json:"-"`
+
+	// Queued is the number of messages queued by the broker while the session
+	// subscriber was offline, Lost and Duplicates are detected by sequence numbers.
+	Queued     int64 `json:"queued,omitempty"`
+	Lost       int64 `json:"lost,omitempty"`
+	Duplicates int64 `json:"duplicates,omitempty"`
+
+	// QueueDrainTime is the time (ms) from reconnecting until the last queued message arrived.
+	QueueDrainTime float64 `json:"queue_drain_time,omitempty"`
+
+	// RedeliveryTimes are the times (ms) from reconnecting until each queued message arrived.
+	RedeliveryTimes []float64 `json:"-"`
+
+	// Disconnects is the number of times the client lost connection to the broker,
+	// DisconnectedFailures is the number of messages failed while disconnected.
+	Disconnects          int64 `json:"disconnects,omitempty"`
+	DisconnectedFailures int64 `json:"disconnected_failures,omitempty"`
+
+	// ConnectionEvents is the timeline of connection loss and recovery of the client.
+	ConnectionEvents []ConnectionEvent `json:"connection_events,omitempty"`
+
+	// RequestTimes are the round-trip times (ms) of a requester, Timeouts is the number of
+	// requests without a response in time, Unmatched is the number of late or unknown responses.
+	RequestTimes []float64 `json:"-"`
+	Timeouts     int64     `json:"timeouts,omitempty"`
+	Unmatched    int64     `json:"unmatched,omitempty"`
+
+	// MsgPerTopic is the number of messages successfully published per topic.
+	MsgPerTopic map[string]int64 `json:"msg_per_topic,omitempty"`
+}
+
+// TotalResults describes results of all clients / runs
+type TotalResults struct {
+	TestRunID    string    `json:"run_id"`
+	TestInstance string    `json:"run_instance"`
+	TestRunType  string    `json:"run_type"` //pub or sub
+	TestCaseID   string    `json:"test_case_id"`
+	TestStart    time.Time `json:"test_start_time"`
+	TestEnd      time.Time `json:"test_end_time"`
+	Clients      int       `json:"num_clients"`
+	Topics       int       `json:"num_topics"`
+	Messages     int       `json:"num_messages"`
+	MessageSize  int       `json:"message_size"`
+	Dop          int       `json:"dop"`
+	QoS          int       `json:"qos"`
+	Ratio        float64   `json:"ratio"`
+	Successes    int64     `json:"successes"`
+	Failures     int64     `json:"failures"`
+	TotalRunTime float64   `json:"total_run_time"`
+
+	ClientRunTimeMin  float64 `json:"client_run_time_min"`
+	ClientRunTimeMax  float64 `json:"client_run_time_max"`
+	ClientRunTimeMean float64 `json:"client_run_time_mean"`
+	ClientRunTimeStd  float64 `json:"client_run_time_std"`
+
+	MsgPerClientMin  float64 `json:"msg_per_client_min"`
+	MsgPerClientMax  float64 `json:"msg_per_client_max"`
+	MsgPerClientMean float64 `json:"msg_per_client_mean"`
+	MsgPerClientStd  float64 `json:"msg_per_client_std"`
+
+	MsgTimeMin  float64 `json:"msg_time_min"`
+	MsgTimeMax  float64 `json:"msg_time_max"`
+	MsgTimeMean float64 `json:"msg_time_mean_mean"`
+	MsgTimeStd  float64 `json:"msg_time_mean_std"`
+
+	// MsgTimeP* are the percentiles of the latencies of all messages.
+	MsgTimeP50 float64 `json:"msg_time_p50,omitempty"`
+	MsgTimeP90 float64 `json:"msg_time_p90,omitempty"`
+	MsgTimeP99 float64 `json:"msg_time_p99,omitempty"`
+
+	// MsgTimeHistogram is the histogram of the latencies of all messages.
+	MsgTimeHistogram *Histogram `json:"msg_time_histogram,omitempty"`
+
+	// Intervals is the time series of the messages of all clients, if recorded.
+	Intervals []IntervalEvent `json:"intervals,omitempty"`
+
+	// TotalMsgsPerSec is a total average throughput, calculated as sum of all messages
+	// from all clients divided by total execution time
+	TotalMsgsPerSec float64 `json:"total_msgs_per_sec"`
+
+	// AvgMsgsPerSec is an average throughput per client, calculated as sum
+	// of individual client throughputs divided by the number of clients.
+	AvgMsgsPerSec float64 `json:"avg_msgs_per_sec"`
+
+	// ClientIDTakeovers is the number of connections lost because another client
+	// connected with the same client id.
+	ClientIDTakeovers int64 `json:"client_id_takeovers,omitempty"`
+
+	// Interrupted is true if the test was stopped before it was over, Unreported is the
+	// number of clients that did not report their results before the drain timeout.
+	Interrupted bool `json:"interrupted,omitempty"`
+	Unreported  int  `json:"unreported,omitempty"`
+
+	// Groups describes how messages were spread among members of shared subscription groups.
+	Groups []*GroupResults `json:"groups,omitempty"`
+
+	// TopicDistribution describes how published messages were spread among topics.
+	TopicDistribution *TopicResults `json:"topic_distribution,omitempty"`
+
+	// Topology describes the topology preset and the delivery ratio validated against it.
+	Topology *TopologyResults `json:"topology,omitempty"`
+
+	// Retained describes results of the retained messages benchmark.
+	Retained *RetainedResults `json:"retained,omitempty"`
+
+	// Connect describes results of the connection storm benchmark.
+	Connect *ConnectResults `json:"connect,omitempty"`
+
+	// Churn describes results of the subscribe/unsubscribe churn benchmark.
+	Churn *ChurnResults `json:"churn,omitempty"`
+
+	// Session describes results of the persistent session benchmark.
+	Session *SessionResults `json:"session,omitempty"`
+
+	// Reconnect describes connection loss and recovery of all clients.
+	Reconnect *ReconnectResults `json:"reconnect,omitempty"`
+
+	// Request describes results of the request/response benchmark.
+	Request *RequestResults `json:"request,omitempty"`
+
+	// Will describes results of the Last Will and Testament benchmark.
+	Will *WillResults `json:"will,omitempty"`
+
+	// Faults describes the network conditions injected by the fault proxy.
+	Faults *FaultResults `json:"faults,omitempty"`
+
+	// Strategy is the strategy used to assign clients to broker nodes,
+	// Nodes breaks the results down per node when multiple brokers are used.
+	Strategy string         `json:"broker_strategy,omitempty"`
+	Nodes    []*NodeResults `json:"nodes,omitempty"`
+
+	// fields are the paths of the metrics present in loaded results, nil if not loaded.
+	fields map[string]bool
+}
+
+// has returns true if the metric is present in the results, which is always
+// the case unless the results were loaded from the JSON of an older version.
+func (t *TotalResults) has(metric string) bool {
+	return t.fields == nil || t.fields[metric]
+}
+
+// ReconnectResults describes connection loss and recovery of all clients
+type ReconnectResults struct {
+	Policy               string `json:"policy"`
+	Disconnects          int    `json:"disconnects"`
+	Reconnects           int    `json:"reconnects"`
+	DisconnectedFailures int64  `json:"disconnected_failures"`
+
+	ReconnectTimeMin  float64 `json:"reconnect_time_min"`
+	ReconnectTimeMax  float64 `json:"reconnect_time_max"`
+	ReconnectTimeMean float64 `json:"reconnect_time_mean"`
+	ReconnectTimeP50  float64 `json:"reconnect_time_p50"`
+	ReconnectTimeP99  float64 `json:"reconnect_time_p99"`
+
+	// Timeline is the list of connection loss events of all clients ordered by time.
+	Timeline []ConnectionEvent `json:"timeline"`
+}
+
+// SessionResults describes results of the persistent session benchmark
+type SessionResults struct {
+	OfflineTime float64 `json:"offline_time"`
+	Queued      int64   `json:"queued"`
+	Lost        int64   `json:"lost"`
+	Duplicates  int64   `json:"duplicates"`
+
+	DrainTimeMin  float64 `json:"drain_time_min"`
+	DrainTimeMax  float64 `json:"drain_time_max"`
+	DrainTimeMean float64 `json:"drain_time_mean"`
+
+	RedeliveryTimeMean float64 `json:"redelivery_time_mean"`
+	RedeliveryTimeP50  float64 `json:"redelivery_time_p50"`
+	RedeliveryTimeP90  float64 `json:"redelivery_time_p90"`
+	RedeliveryTimeP99  float64 `json:"redelivery_time_p99"`
+	RedeliveryTimeMax  float64 `json:"redelivery_time_max"`
+}
+
+// ChurnResults describes results of the subscribe/unsubscribe churn benchmark
+type ChurnResults struct {
+	Subscribes   int     `json:"subscribes"`
+	Unsubscribes int     `json:"unsubscribes"`
+	OpFailures   int64   `json:"op_failures"`
+	OpsPerSec    float64 `json:"ops_per_sec"`
+	Stale        int64   `json:"stale_messages"`
+
+	SubTimeMin  float64 `json:"sub_time_min"`
+	SubTimeMax  float64 `json:"sub_time_max"`
+	SubTimeMean float64 `json:"sub_time_mean"`
+	SubTimeP50  float64 `json:"sub_time_p50"`
+	SubTimeP90  float64 `json:"sub_time_p90"`
+	SubTimeP99  float64 `json:"sub_time_p99"`
+
+	UnsubTimeMin  float64 `json:"unsub_time_min"`
+	UnsubTimeMax  float64 `json:"unsub_time_max"`
+	UnsubTimeMean float64 `json:"unsub_time_mean"`
+	UnsubTimeP50  float64 `json:"unsub_time_p50"`
+	UnsubTimeP90  float64 `json:"unsub_time_p90"`
+	UnsubTimeP99  float64 `json:"unsub_time_p99"`
+}
+
+// ConnectResults describes results of the connection storm benchmark
+type ConnectResults struct {
+	Attempted      int     `json:"attempted"`
+	Accepted       int64   `json:"accepted"`
+	Refused        int64   `json:"refused"`
+	Failed         int64   `json:"failed"`
+	MaxConcurrent  int64   `json:"max_concurrent"`
+	ConnectsPerSec float64 `json:"connects_per_sec"`
+
+	ConnectTimeMin  float64 `json:"connect_time_min"`
+	ConnectTimeMax  float64 `json:"connect_time_max"`
+	ConnectTimeMean float64 `json:"connect_time_mean"`
+	ConnectTimeStd  float64 `json:"connect_time_std"`
+	ConnectTimeP50  float64 `json:"connect_time_p50"`
+	ConnectTimeP90  float64 `json:"connect_time_p90"`
+	ConnectTimeP99  float64 `json:"connect_time_p99"`
+}
+
+// RetainedResults describes results of the retained messages benchmark
+type RetainedResults struct {
+	Messages       int     `json:"num_retained"`
+	LoadFailures   int64   `json:"load_failures"`
+	LoadTime       float64 `json:"load_time"`
+	LoadMsgsPerSec float64 `json:"load_msgs_per_sec"`
+
+	// Completeness is the share of the retained set received by all subscribers.
+	Completeness        float64 `json:"completeness"`
+	CompleteSubscribers int     `json:"complete_subscribers"`
+
+	// SetTime* describe the time (ms) complete subscribers took to receive the full retained set.
+	SetTimeMin  float64 `json:"set_time_min"`
+	SetTimeMax  float64 `json:"set_time_max"`
+	SetTimeMean float64 `json:"set_time_mean"`
+	SetTimeStd  float64 `json:"set_time_std"`
+}
+
+// TopologyResults describes results of a topology preset run
+type TopologyResults struct {
+	Name        string `json:"name"`
+	Publishers  int    `json:"num_publishers"`
+	Subscribers int    `json:"num_subscribers"`
+	Topics      int    `json:"num_topics"`
+
+	// delivery is only validated for subscribers
+	ExpectedPerSubscriber int     `json:"expected_per_subscriber,omitempty"`
+	Expected              int64   `json:"expected,omitempty"`
+	Received              int64   `json:"received,omitempty"`
+	DeliveryRatio         float64 `json:"delivery_ratio,omitempty"`
+	CompleteSubscribers   int     `json:"complete_subscribers,omitempty"`
+}
+
+// TopicResults describes the distribution of published messages among topics
+type TopicResults struct {
+	Strategy string `json:"strategy"`
+	Topics   int    `json:"num_topics_used"`
+
+	MsgPerTopicMin  float64 `json:"msg_per_topic_min"`
+	MsgPerTopicMax  float64 `json:"msg_per_topic_max"`
+	MsgPerTopicMean float64 `json:"msg_per_topic_mean"`
+	MsgPerTopicStd  float64 `json:"msg_per_topic_std"`
+
+	MsgPerTopic map[string]int64 `json:"msg_per_topic"`
+}
+
+// GroupResults describes results of a shared subscription group
+type GroupResults struct {
+	Name     string  `json:"name"`
+	Members  int     `json:"members"`
+	Received int64   `json:"received"`
+	Expected int     `json:"expected"`
+	Ratio    float64 `json:"ratio"`
+
+	MsgPerMemberMin  float64 `json:"msg_per_member_min"`
+	MsgPerMemberMax  float64 `json:"msg_per_member_max"`
+	MsgPerMemberMean float64 `json:"msg_per_member_mean"`
+	MsgPerMemberStd  float64 `json:"msg_per_member_std"`
+
+	// Gini is the Gini coefficient of messages received per member:
+	// 0 means perfectly even load-balancing, values close to 1 mean
+	// a single member received almost all messages.
+	Gini float64 `json:"gini"`
+}
+
+// RequestResults describes the round-trip times of the request/response benchmark.
+type RequestResults struct {
+	Responders     int     `json:"num_responders"`
+	Requests       int64   `json:"requests"`
+	Responses      int64   `json:"responses"`
+	Echoed         int64   `json:"echoed"`
+	Timeouts       int64   `json:"timeouts"`
+	Unmatched      int64   `json:"unmatched"`
+	RequestsPerSec float64 `json:"requests_per_sec"`
+
+	RTTMin  float64 `json:"rtt_min"`
+	RTTMax  float64 `json:"rtt_max"`
+	RTTMean float64 `json:"rtt_mean"`
+	RTTStd  float64 `json:"rtt_std"`
+	RTTP50  float64 `json:"rtt_p50"`
+	RTTP90  float64 `json:"rtt_p90"`
+	RTTP99  float64 `json:"rtt_p99"`
+}
+
+// WillResults describes how the broker delivered the wills of killed connections.
+type WillResults struct {
+	Kill       string  `json:"kill"`
+	Connected  int64   `json:"connected"`
+	Killed     int     `json:"killed"`
+	Received   int     `json:"received"`
+	Missing    int     `json:"missing"`
+	Duplicates int     `json:"duplicates"`
+	Ratio      float64 `json:"ratio"`
+
+	// Unexpected is the number of wills of clients which were not killed, e.g. of clients
+	// that disconnected on their own or of another test publishing to the will topics.
+	Unexpected int `json:"unexpected"`
+
+	// WillTime is the time (ms) from killing the connections until each will was delivered.
+	WillTimeMin  float64 `json:"will_time_min"`
+	WillTimeMax  float64 `json:"will_time_max"`
+	WillTimeMean float64 `json:"will_time_mean"`
+	WillTimeP50  float64 `json:"will_time_p50"`
+	WillTimeP90  float64 `json:"will_time_p90"`
+	WillTimeP99  float64 `json:"will_time_p99"`
+}
+
+// FaultResults describes the network conditions injected b/w clients and brokers
+// and the number of faults actually injected.
+type FaultResults struct {
+	Latency       float64 `json:"latency"` // in milliseconds
+	Jitter        float64 `json:"jitter"`  // in milliseconds
+	Bandwidth     int64   `json:"bandwidth"`
+	DropRate      float64 `json:"drop_rate"`
+	ResetInterval float64 `json:"reset_interval"` // in seconds
+	ResetRatio    float64 `json:"reset_ratio"`
+	Seed          int64   `json:"seed"`
+
+	Connections int64 `json:"connections"`
+	Resets      int64 `json:"resets"`
+	Drops       int64 `json:"drops"`
+	BytesUp     int64 `json:"bytes_up"`
+	BytesDown   int64 `json:"bytes_down"`
+}
+
+// NodeResults describes results of the clients connected to a single broker node.
+type NodeResults struct {
+	Broker      string  `json:"broker"`
+	Clients     int     `json:"num_clients"`
+	Ratio       float64 `json:"ratio"`
+	Successes   int64   `json:"successes"`
+	Failures    int64   `json:"failures"`
+	MsgTimeMin  float64 `json:"msg_time_min"`
+	MsgTimeMax  float64 `json:"msg_time_max"`
+	MsgTimeMean float64 `json:"msg_time_mean_mean"`
+
+	// MsgsPerSec is the throughput of the node's clients over the total execution time.
+	MsgsPerSec float64 `json:"msgs_per_sec"`
+}
+
+// JSONResults are used to export results as a JSON document
+type JSONResults struct {
+	Runs   []*RunResults `json:"runs"`
+	Totals *TotalResults `json:"totals"`
+}
+
+var (
+	// Replace with your Workspace ID
+	customerID = os.Getenv("LOGANALYTICS_CUSTOMER_ID")
+
+	// Replace with your Primary or Secondary ID
+	sharedKey = os.Getenv("LOGANALYTICS_SHARED_KEY")
+
+	//Specify the name of the record type that you'll be creating
+	logType = os.Getenv("LOGANALYTICS_LOG_NAME")
+
+	//Specify a field with the created time for the records
+	timeStampField = "DateValue"
+)
+
+func calculateTotalResults(runID string, caseID string, results []*RunResults, startTime time.Time, endTime time.Time,
+	testType string, clients int, topics int, messages int, size int, qos int, dop int) *TotalResults {
+
+	totals := new(TotalResults)
+	var hostname, _ = os.Hostname()
+	totals.TestRunID = runID
+	totals.TestCaseID = caseID
+	totals.TestInstance = hostname
+	totals.TotalRunTime = endTime.Sub(startTime).Seconds()
+	totals.TestStart = startTime
+	totals.TestEnd = endTime
+
+	msgTimeMeans := make([]float64, len(results))
+	msgsPerClient := make([]float64, len(results))
+	msgsPerSecs := make([]float64, len(results))
+	runTimes := make([]float64, len(results))
+
+	totals.MsgTimeMin = results[0].MsgTimeMin
+	for i, res := range results {
+		totals.Successes += res.Successes
+		totals.Failures += res.Failures
+
+		if res.MsgTimeMin < totals.MsgTimeMin {
+			totals.MsgTimeMin = res.MsgTimeMin
+		}
+
+		if res.MsgTimeMax > totals.MsgTimeMax {
+			totals.MsgTimeMax = res.MsgTimeMax
+		}
+
+		msgTimeMeans[i] = res.MsgTimeMean
+		msgsPerClient[i] = float64(res.Successes + res.Failures)
+		runTimes[i] = res.ClientRunTime
+		msgsPerSecs[i] = float64(res.Successes) / res.ClientRunTime
+
+	}
+	totals.TestRunType = testType
+	totals.QoS = qos
+	totals.Dop = dop
+	totals.Clients = clients
+	totals.Topics = topics
+	totals.Messages = messages
+	totals.MessageSize = size
+
+	totals.TotalMsgsPerSec = float64(totals.Successes) / totals.TotalRunTime
+	if totals.Successes+totals.Failures > 0 {
+		totals.Ratio = float64(totals.Successes) / float64(totals.Successes+totals.Failures)
+	}
+	totals.AvgMsgsPerSec = stats.StatsMean(msgsPerSecs)
+
+	totals.ClientRunTimeMean = stats.StatsMean(runTimes)
+	totals.ClientRunTimeMin = stats.StatsMin(runTimes)
+	totals.ClientRunTimeMax = stats.StatsMax(runTimes)
+
+	totals.MsgPerClientMean = stats.StatsMean(msgsPerClient)
+	totals.MsgPerClientMin = stats.StatsMin(msgsPerClient)
+	totals.MsgPerClientMax = stats.StatsMax(msgsPerClient)
+
+	totals.MsgTimeMin = stats.StatsMin(msgTimeMeans)
+	totals.MsgTimeMax = stats.StatsMax(msgTimeMeans)
+	totals.MsgTimeMean = stats.StatsMean(msgTimeMeans)
+
+	var msgTimes []float64
+	for _, res := range results {
+		msgTimes = append(msgTimes, res.MsgTimes...)
+	}
+	if len(msgTimes) > 0 {
+		sort.Float64s(msgTimes)
+		totals.MsgTimeP50 = percentile(msgTimes, 50)
+		totals.MsgTimeP90 = percentile(msgTimes, 90)
+		totals.MsgTimeP99 = percentile(msgTimes, 99)
+		totals.MsgTimeHistogram = NewHistogram(msgTimes)
+	}
+
+	// calculate std if # of clients is > 1, otherwise leave as 0 (convention)
+	if len(results) > 1 {
+		totals.ClientRunTimeStd = stats.StatsSampleStandardDeviation(runTimes)
+		totals.MsgTimeStd = stats.StatsSampleStandardDeviation(msgTimeMeans)
+		totals.MsgPerClientStd = stats.StatsSampleStandardDeviation(msgsPerClient)
+	}
+
+	return totals
+}
+
+func calculateRequestResults(results []*RunResults, responders []*RunResults, totalRunTime float64) *RequestResults {
+	requestResults := &RequestResults{
+		Responders: len(responders),
+	}
+	var times []float64
+	for _, res := range results {
+		requestResults.Requests += res.Successes + res.Failures
+		requestResults.Responses += res.Successes
+		requestResults.Timeouts += res.Timeouts
+		requestResults.Unmatched += res.Unmatched
+		times = append(times, res.RequestTimes...)
+	}
+	for _, res := range responders {
+		requestResults.Echoed += res.Successes
+	}
+	if totalRunTime > 0 {
+		requestResults.RequestsPerSec = float64(requestResults.Responses) / totalRunTime
+	}
+	if len(times) == 0 {
+		return requestResults
+	}
+
+	sort.Float64s(times)
+	requestResults.RTTMin = stats.StatsMin(times)
+	requestResults.RTTMax = stats.StatsMax(times)
+	requestResults.RTTMean = stats.StatsMean(times)
+	requestResults.RTTP50 = percentile(times, 50)
+	requestResults.RTTP90 = percentile(times, 90)
+	requestResults.RTTP99 = percentile(times, 99)
+
+	// calculate std if sample is > 1, otherwise leave as 0 (convention)
+	if len(times) > 1 {
+		requestResults.RTTStd = stats.StatsSampleStandardDeviation(times)
+	}
+	return requestResults
+}
+
+func calculateWillResults(results []*RunResults, controller *WillController) *WillResults {
+	killed, killedIDs, killTime, wills := controller.Results()
+	willResults := &WillResults{
+		Kill:   controller.Kill,
+		Killed: killed,
+	}
+	for _, res := range results {
+		willResults.Connected += res.Successes
+	}
+
+	times := make([]float64, 0, len(wills))
+	for id, delivered := range wills {
+		if !killedIDs[id] {
+			willResults.Unexpected += len(delivered)
+			continue
+		}
+		willResults.Received++
+		willResults.Duplicates += len(delivered) - 1
+		times = append(times, float64(delivered[0].Sub(killTime).Microseconds())/1000)
+	}
+	if killed > willResults.Received {
+		willResults.Missing = killed - willResults.Received
+	}
+	if killed > 0 {
+		willResults.Ratio = float64(willResults.Received) / float64(killed)
+	}
+	if len(times) == 0 {
+		return willResults
+	}
+
+	sort.Float64s(times)
+	willResults.WillTimeMin = stats.StatsMin(times)
+	willResults.WillTimeMax = stats.StatsMax(times)
+	willResults.WillTimeMean = stats.StatsMean(times)
+	willResults.WillTimeP50 = percentile(times, 50)
+	willResults.WillTimeP90 = percentile(times, 90)
+	willResults.WillTimeP99 = percentile(times, 99)
+	return willResults
+}
+
+func calculateFaultResults(conditions NetworkConditions, proxies []*FaultProxy) *FaultResults {
+	faultResults := &FaultResults{
+		Latency:       float64(conditions.Latency.Microseconds()) / 1000,
+		Jitter:        float64(conditions.Jitter.Microseconds()) / 1000,
+		Bandwidth:     conditions.Bandwidth,
+		DropRate:      conditions.DropRate,
+		ResetInterval: conditions.ResetInterval.Seconds(),
+		ResetRatio:    conditions.ResetRatio,
+		Seed:          conditions.Seed,
+	}
+	for _, p := range proxies {
+		p.addStats(faultResults)
+	}
+	return faultResults
+}
+
+// calculateNodeResults breaks the results down per broker node in the order of brokers.
+// Clients that never connected are reported under the "unconnected" node.
+func calculateNodeResults(results []*RunResults, brokers []string, totalRunTime float64) []*NodeResults {
+	nodes := make(map[string]*NodeResults)
+	var nodeResults []*NodeResults
+	for _, broker := range brokers {
+		nodes[broker] = &NodeResults{Broker: broker}
+		nodeResults = append(nodeResults, nodes[broker])
+	}
+
+	msgTimeMeans := make(map[string][]float64)
+	for _, res := range results {
+		broker := res.Broker
+		if broker == "" {
+			broker = "unconnected"
+		}
+		node, ok := nodes[broker]
+		if !ok {
+			node = &NodeResults{Broker: broker}
+			nodes[broker] = node
+			nodeResults = append(nodeResults, node)
+		}
+		node.Clients++
+		node.Successes += res.Successes
+		node.Failures += res.Failures
+		if res.Successes == 0 {
+			continue // no latency stats
+		}
+		if len(msgTimeMeans[broker]) == 0 || res.MsgTimeMin < node.MsgTimeMin {
+			node.MsgTimeMin = res.MsgTimeMin
+		}
+		if res.MsgTimeMax > node.MsgTimeMax {
+			node.MsgTimeMax = res.MsgTimeMax
+		}
+		msgTimeMeans[broker] = append(msgTimeMeans[broker], res.MsgTimeMean)
+	}
+
+	// drop nodes without clients, e.g. unused failover nodes
+	used := nodeResults[:0]
+	for _, node := range nodeResults {
+		if node.Clients == 0 {
+			continue
+		}
+		if node.Successes+node.Failures > 0 {
+			node.Ratio = float64(node.Successes) / float64(node.Successes+node.Failures)
+		}
+		if len(msgTimeMeans[node.Broker]) > 0 {
+			node.MsgTimeMean = stats.StatsMean(msgTimeMeans[node.Broker])
+		}
+		if totalRunTime > 0 {
+			node.MsgsPerSec = float64(node.Successes) / totalRunTime
+		}
+		used = append(used, node)
+	}
+	return used
+}
+
+func calculateGroupResults(results []*RunResults, groups []*SubscriberGroup) []*GroupResults {
+	members := make(map[string][]float64)
+	failures := make(map[string]int64)
+	for _, res := range results {
+		if res.Group == "" {
+			continue
+		}
+		members[res.Group] = append(members[res.Group], float64(res.Successes))
+		failures[res.Group] += res.Failures
+	}
+
+	groupResults := make([]*GroupResults, 0, len(groups))
+	for _, g := range groups {
+		received := members[g.Name]
+		gr := &GroupResults{
+			Name:     g.Name,
+			Members:  len(received),
+			Received: int64(stats.StatsSum(received)),
+			Expected: g.MsgCount,
+		}
+		if len(received) == 0 {
+			groupResults = append(groupResults, gr)
+			continue
+		}
+
+		// delivery ratio against the expected count if known, otherwise against failures
+		if gr.Expected > 0 {
+			gr.Ratio = float64(gr.Received) / float64(gr.Expected)
+		} else if gr.Received+failures[g.Name] > 0 {
+			gr.Ratio = float64(gr.Received) / float64(gr.Received+failures[g.Name])
+		}
+
+		gr.MsgPerMemberMin = stats.StatsMin(received)
+		gr.MsgPerMemberMax = stats.StatsMax(received)
+		gr.MsgPerMemberMean = stats.StatsMean(received)
+		gr.Gini = gini(received)
+
+		// calculate std if # of members is > 1, otherwise leave as 0 (convention)
+		if len(received) > 1 {
+			gr.MsgPerMemberStd = stats.StatsSampleStandardDeviation(received)
+		}
+		groupResults = append(groupResults, gr)
+	}
+	return groupResults
+}
+
+func calculateTopicResults(results []*RunResults, strategy string) *TopicResults {
+	topicResults := &TopicResults{
+		Strategy:    strategy,
+		MsgPerTopic: make(map[string]int64),
+	}
+	for _, res := range results {
+		for topic, n := range res.MsgPerTopic {
+			topicResults.MsgPerTopic[topic] += n
+		}
+	}
+
+	counts := make([]float64, 0, len(topicResults.MsgPerTopic))
+	for _, n := range topicResults.MsgPerTopic {
+		counts = append(counts, float64(n))
+	}
+	topicResults.Topics = len(counts)
+	if len(counts) == 0 {
+		return topicResults
+	}
+
+	topicResults.MsgPerTopicMin = stats.StatsMin(counts)
+	topicResults.MsgPerTopicMax = stats.StatsMax(counts)
+	topicResults.MsgPerTopicMean = stats.StatsMean(counts)
+
+	// calculate std if # of topics is > 1, otherwise leave as 0 (convention)
+	if len(counts) > 1 {
+		topicResults.MsgPerTopicStd = stats.StatsSampleStandardDeviation(counts)
+	}
+	return topicResults
+}
+
+func calculateTopologyResults(results []*RunResults, topology *Topology, testType string, msgCount int) *TopologyResults {
+	topologyResults := &TopologyResults{
+		Name:        topology.Name,
+		Publishers:  topology.Publishers,
+		Subscribers: topology.Subscribers,
+		Topics:      topology.Topics,
+	}
+	if testType != "sub" {
+		return topologyResults
+	}
+
+	topologyResults.ExpectedPerSubscriber = topology.ExpectedPerSubscriber(msgCount)
+	topologyResults.Expected = int64(topologyResults.ExpectedPerSubscriber) * int64(len(results))
+	for _, res := range results {
+		topologyResults.Received += res.Successes
+		if res.Successes >= int64(topologyResults.ExpectedPerSubscriber) {
+			topologyResults.CompleteSubscribers++
+		}
+	}
+	if topologyResults.Expected > 0 {
+		topologyResults.DeliveryRatio = float64(topologyResults.Received) / float64(topologyResults.Expected)
+	}
+	return topologyResults
+}
+
+func calculateRetainedResults(results []*RunResults, load *RunResults, messages int) *RetainedResults {
+	retainedResults := &RetainedResults{
+		Messages:     messages,
+		LoadFailures: load.Failures,
+		LoadTime:     load.ClientRunTime,
+	}
+	if load.ClientRunTime > 0 {
+		retainedResults.LoadMsgsPerSec = float64(load.Successes) / load.ClientRunTime
+	}
+
+	var received int64
+	setTimes := make([]float64, 0, len(results))
+	for _, res := range results {
+		received += res.Successes
+		if res.Failures == 0 {
+			setTimes = append(setTimes, res.RetainedSetTime)
+		}
+	}
+	retainedResults.CompleteSubscribers = len(setTimes)
+	if messages > 0 {
+		retainedResults.Completeness = float64(received) / float64(messages*len(results))
+	}
+	if len(setTimes) == 0 {
+		return retainedResults
+	}
+
+	retainedResults.SetTimeMin = stats.StatsMin(setTimes)
+	retainedResults.SetTimeMax = stats.StatsMax(setTimes)
+	retainedResults.SetTimeMean = stats.StatsMean(setTimes)
+
+	// calculate std if # of subscribers is > 1, otherwise leave as 0 (convention)
+	if len(setTimes) > 1 {
+		retainedResults.SetTimeStd = stats.StatsSampleStandardDeviation(setTimes)
+	}
+	return retainedResults
+}
+
+func calculateConnectResults(results []*RunResults, maxConcurrent int64, connectPhase float64) *ConnectResults {
+	connectResults := &ConnectResults{
+		Attempted:     len(results),
+		MaxConcurrent: maxConcurrent,
+	}
+
+	times := make([]float64, 0, len(results))
+	for _, res := range results {
+		connectResults.Accepted += res.Successes
+		connectResults.Refused += res.Refused
+		connectResults.Failed += res.Failures - res.Refused
+		if res.Successes > 0 {
+			times = append(times, res.ConnectTime)
+		}
+	}
+	if connectPhase > 0 {
+		connectResults.ConnectsPerSec = float64(connectResults.Accepted) / connectPhase
+	}
+	if len(times) == 0 {
+		return connectResults
+	}
+
+	sort.Float64s(times)
+	connectResults.ConnectTimeMin = stats.StatsMin(times)
+	connectResults.ConnectTimeMax = stats.StatsMax(times)
+	connectResults.ConnectTimeMean = stats.StatsMean(times)
+	connectResults.ConnectTimeP50 = percentile(times, 50)
+	connectResults.ConnectTimeP90 = percentile(times, 90)
+	connectResults.ConnectTimeP99 = percentile(times, 99)
+
+	// calculate std if sample is > 1, otherwise leave as 0 (convention)
+	if len(times) > 1 {
+		connectResults.ConnectTimeStd = stats.StatsSampleStandardDeviation(times)
+	}
+	return connectResults
+}
+
+func calculateChurnResults(results []*RunResults, totalRunTime float64) *ChurnResults {
+	churnResults := new(ChurnResults)
+	var subTimes, unsubTimes []float64
+	for _, res := range results {
+		subTimes = append(subTimes, res.SubscribeTimes...)
+		unsubTimes = append(unsubTimes, res.UnsubscribeTimes...)
+		churnResults.OpFailures += res.Failures
+		churnResults.Stale += res.Stale
+	}
+	churnResults.Subscribes = len(subTimes)
+	churnResults.Unsubscribes = len(unsubTimes)
+	if totalRunTime > 0 {
+		churnResults.OpsPerSec = float64(len(subTimes)+len(unsubTimes)) / totalRunTime
+	}
+
+	if len(subTimes) > 0 {
+		sort.Float64s(subTimes)
+		churnResults.SubTimeMin = stats.StatsMin(subTimes)
+		churnResults.SubTimeMax = stats.StatsMax(subTimes)
+		churnResults.SubTimeMean = stats.StatsMean(subTimes)
+		churnResults.SubTimeP50 = percentile(subTimes, 50)
+		churnResults.SubTimeP90 = percentile(subTimes, 90)
+		churnResults.SubTimeP99 = percentile(subTimes, 99)
+	}
+	if len(unsubTimes) > 0 {
+		sort.Float64s(unsubTimes)
+		churnResults.UnsubTimeMin = stats.StatsMin(unsubTimes)
+		churnResults.UnsubTimeMax = stats.StatsMax(unsubTimes)
+		churnResults.UnsubTimeMean = stats.StatsMean(unsubTimes)
+		churnResults.UnsubTimeP50 = percentile(unsubTimes, 50)
+		churnResults.UnsubTimeP90 = percentile(unsubTimes, 90)
+		churnResults.UnsubTimeP99 = percentile(unsubTimes, 99)
+	}
+	return churnResults
+}
+
+func calculateSessionResults(results []*RunResults, offline time.Duration) *SessionResults {
+	sessionResults := &SessionResults{
+		OfflineTime: offline.Seconds(),
+	}
+
+	var drainTimes, redeliveryTimes []float64
+	for _, res := range results {
+		sessionResults.Queued += res.Queued
+		sessionResults.Lost += res.Lost
+		sessionResults.Duplicates += res.Duplicates
+		if res.Queued > 0 {
+			drainTimes = append(drainTimes, res.QueueDrainTime)
+		}
+		redeliveryTimes = append(redeliveryTimes, res.RedeliveryTimes...)
+	}
+
+	if len(drainTimes) > 0 {
+		sessionResults.DrainTimeMin = stats.StatsMin(drainTimes)
+		sessionResults.DrainTimeMax = stats.StatsMax(drainTimes)
+		sessionResults.DrainTimeMean = stats.StatsMean(drainTimes)
+	}
+	if len(redeliveryTimes) > 0 {
+		sort.Float64s(redeliveryTimes)
+		sessionResults.RedeliveryTimeMean = stats.StatsMean(redeliveryTimes)
+		sessionResults.RedeliveryTimeP50 = percentile(redeliveryTimes, 50)
+		sessionResults.RedeliveryTimeP90 = percentile(redeliveryTimes, 90)
+		sessionResults.RedeliveryTimeP99 = percentile(redeliveryTimes, 99)
+		sessionResults.RedeliveryTimeMax = stats.StatsMax(redeliveryTimes)
+	}
+	return sessionResults
+}
+
+func calculateReconnectResults(results []*RunResults, policy string) *ReconnectResults {
+	reconnectResults := &ReconnectResults{
+		Policy:   policy,
+		Timeline: []ConnectionEvent{},
+	}
+
+	var times []float64
+	for _, res := range results {
+		reconnectResults.DisconnectedFailures += res.DisconnectedFailures
+		for _, e := range res.ConnectionEvents {
+			reconnectResults.Timeline = append(reconnectResults.Timeline, e)
+			if !e.Reconnected.IsZero() {
+				times = append(times, e.ReconnectTime)
+			}
+		}
+	}
+	sort.Slice(reconnectResults.Timeline, func(i, j int) bool {
+		return reconnectResults.Timeline[i].Lost.Before(reconnectResults.Timeline[j].Lost)
+	})
+	reconnectResults.Disconnects = len(reconnectResults.Timeline)
+	reconnectResults.Reconnects = len(times)
+	if len(times) == 0 {
+		return reconnectResults
+	}
+
+	sort.Float64s(times)
+	reconnectResults.ReconnectTimeMin = stats.StatsMin(times)
+	reconnectResults.ReconnectTimeMax = stats.StatsMax(times)
+	reconnectResults.ReconnectTimeMean = stats.StatsMean(times)
+	reconnectResults.ReconnectTimeP50 = percentile(times, 50)
+	reconnectResults.ReconnectTimeP99 = percentile(times, 99)
+	return reconnectResults
+}
+
+// percentile returns the p-th percentile (nearest rank) of the sorted sample.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// gini calculates the Gini coefficient of the given sample.
+func gini(data []float64) float64 {
+	sorted := make([]float64, len(data))
+	copy(sorted, data)
+	sort.Float64s(sorted)
+
+	var sum, weighted float64
+	for i, v := range sorted {
+		sum += v
+		weighted += float64(i+1) * v
+	}
+	if sum == 0 {
+		return 0
+	}
+	n := float64(len(sorted))
+	return 2*weighted/(n*sum) - (n+1)/n
+}
+
+// PublishResults sends the total results to Log Analytics.
+func PublishResults(results []*RunResults, totals *TotalResults) {
+	log.Println("Publishing test results...")
+
+	data, err := json.Marshal(totals)
+	if err != nil {
+		log.Printf("Error marshalling results: %v", err)
+		return
+	}
+
+	err = sendResults(string(data))
+	if err != nil {
+		log.Printf("Error publishing test results to log analytics: %v", err)
+	}
+	log.Println("Done")
+}
+
+// WriteJSONResults writes the results as an indented JSON document.
+func WriteJSONResults(w io.Writer, results *JSONResults) error {
+	data, err := json.MarshalIndent(results, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// LoadResults reads results written by WriteJSONResults. It also reads the results of
+// repeated runs, in which case it returns the run with the median throughput, as the
+// most representative of the runs.
+func LoadResults(path string) (*JSONResults, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Totals  json.RawMessage   `json:"totals"`
+		Results []json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing results %v: %v", path, err)
+	}
+	if doc.Totals != nil || len(doc.Results) == 0 {
+		results, err := parseResults(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing results %v: %v", path, err)
+		}
+		return results, nil
+	}
+
+	runs := make([]*JSONResults, len(doc.Results))
+	for i, data := range doc.Results {
+		if runs[i], err = parseResults(data); err != nil {
+			return nil, fmt.Errorf("error parsing results %v, run %d: %v", path, i+1, err)
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Totals.TotalMsgsPerSec < runs[j].Totals.TotalMsgsPerSec })
+	return runs[(len(runs)-1)/2], nil
+}
+
+// parseResults parses the JSON results of a run and records the metrics present in them.
+func parseResults(data []byte) (*JSONResults, error) {
+	results := new(JSONResults)
+	if err := json.Unmarshal(data, results); err != nil {
+		return nil, err
+	}
+	if results.Totals == nil {
+		return nil, errors.New("no totals")
+	}
+	var raw struct {
+		Totals map[string]interface{} `json:"totals"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	results.Totals.fields = make(map[string]bool)
+	addFields(results.Totals.fields, "", raw.Totals)
+	return results, nil
+}
+
+// addFields adds the paths of the values of the JSON object to the fields.
+func addFields(fields map[string]bool, prefix string, object map[string]interface{}) {
+	for name, v := range object {
+		if inner, ok := v.(map[string]interface{}); ok {
+			addFields(fields, prefix+name+".", inner)
+			continue
+		}
+		fields[prefix+name] = true
+	}
+}
+
+// PrintResults prints the test parameters and the total results.
+func PrintResults(results []*RunResults, totals *TotalResults) {
+	FprintResults(os.Stdout, results, totals)
+}
+
+// FprintResults writes the test parameters and the total results to w.
+func FprintResults(w io.Writer, results []*RunResults, totals *TotalResults) {
+	fmt.Fprintf(w, "========= TEST PARAMS =========\n")
+	fmt.Fprintf(w, "Test Run Id:                      %v\n", totals.TestRunID)
+	fmt.Fprintf(w, "Test Case Id:                     %v\n", totals.TestCaseID)
+	fmt.Fprintf(w, "Test Instance:                    %v\n", totals.TestInstance)
+	fmt.Fprintf(w, "Test Type:                        %v\n", totals.TestRunType)
+	fmt.Fprintf(w, "Number of Clients:                %v\n", totals.Clients)
+	fmt.Fprintf(w, "Number of Topics:                 %v\n", totals.Topics)
+	if totals.Messages > 0 {
+		fmt.Fprintf(w, "Messages per Client:              %v\n", totals.Messages)
+	}
+	fmt.Fprintf(w, "Messag size (bytes):              %v\n", totals.MessageSize)
+	fmt.Fprintf(w, "QoS:                              %v\n", totals.QoS)
+	fmt.Fprintf(w, "DOP (Max threads):                %v\n", totals.Dop)
+	fmt.Fprintf(w, "========= TEST RESULTS =========\n")
+	if totals.Interrupted {
+		fmt.Fprintf(w, "Interrupted:                      %v (%d clients unreported)\n", totals.Interrupted, totals.Unreported)
+	}
+	fmt.Fprintf(w, "Total Ratio:                      %.3f (%d/%d)\n", totals.Ratio, totals.Successes, totals.Successes+totals.Failures)
+	fmt.Fprintf(w, "Total Runtime (sec):              %.3f\n", totals.TotalRunTime)
+	fmt.Fprintf(w, "Client Runtime Avg (sec):         %.3f\n", totals.ClientRunTimeMean)
+	fmt.Fprintf(w, "Client Runtime Min (sec):         %.3f\n", totals.ClientRunTimeMin)
+	fmt.Fprintf(w, "Client Runtime Max (sec):         %.3f\n", totals.ClientRunTimeMax)
+	fmt.Fprintf(w, "Client Runtime Std (sec):         %.3f\n", totals.ClientRunTimeStd)
+
+	fmt.Fprintf(w, "Messages per Client Avg:         %.3f\n", totals.MsgPerClientMean)
+	fmt.Fprintf(w, "Messages per Client Min:         %.3f\n", totals.MsgPerClientMin)
+	fmt.Fprintf(w, "Messages per Client Max:         %.3f\n", totals.MsgPerClientMax)
+	fmt.Fprintf(w, "Messages per Client Std:         %.3f\n", totals.MsgPerClientStd)
+
+	fmt.Fprintf(w, "Msg Latency Avg (ms):             %.3f\n", totals.MsgTimeMean)
+	fmt.Fprintf(w, "Msg Latency Min (ms):             %.3f\n", totals.MsgTimeMin)
+	fmt.Fprintf(w, "Msg Latency Max (ms):             %.3f\n", totals.MsgTimeMax)
+	fmt.Fprintf(w, "Msg Latency Std (ms):             %.3f\n", totals.MsgTimeStd)
+	if totals.MsgTimeP99 > 0 {
+		fmt.Fprintf(w, "Msg Latency P50 (ms):             %.3f\n", totals.MsgTimeP50)
+		fmt.Fprintf(w, "Msg Latency P90 (ms):             %.3f\n", totals.MsgTimeP90)
+		fmt.Fprintf(w, "Msg Latency P99 (ms):             %.3f\n", totals.MsgTimeP99)
+	}
+	fmt.Fprintf(w, "Avg Bandwidth p/client (msg/sec): %.3f\n", totals.AvgMsgsPerSec)
+	fmt.Fprintf(w, "Total Test Bandwidth (msg/sec):   %.3f\n", totals.TotalMsgsPerSec)
+	if totals.ClientIDTakeovers > 0 {
+		fmt.Fprintf(w, "Client ID Takeovers:              %v\n", totals.ClientIDTakeovers)
+	}
+	if r := totals.Request; r != nil {
+		fmt.Fprintf(w, "========= REQUEST =========\n")
+		fmt.Fprintf(w, "Number of Responders:             %v\n", r.Responders)
+		fmt.Fprintf(w, "Requests / Responses:             %v / %v\n", r.Requests, r.Responses)
+		fmt.Fprintf(w, "Echoed by Responders:             %v\n", r.Echoed)
+		fmt.Fprintf(w, "Timeouts / Unmatched:             %v / %v\n", r.Timeouts, r.Unmatched)
+		fmt.Fprintf(w, "Request Rate (req/sec):           %.3f\n", r.RequestsPerSec)
+		fmt.Fprintf(w, "RTT Avg (ms):                     %.3f\n", r.RTTMean)
+		fmt.Fprintf(w, "RTT Min (ms):                     %.3f\n", r.RTTMin)
+		fmt.Fprintf(w, "RTT Max (ms):                     %.3f\n", r.RTTMax)
+		fmt.Fprintf(w, "RTT Std (ms):                     %.3f\n", r.RTTStd)
+		fmt.Fprintf(w, "RTT P50 (ms):                     %.3f\n", r.RTTP50)
+		fmt.Fprintf(w, "RTT P90 (ms):                     %.3f\n", r.RTTP90)
+		fmt.Fprintf(w, "RTT P99 (ms):                     %.3f\n", r.RTTP99)
+	}
+	if wr := totals.Will; wr != nil {
+		fmt.Fprintf(w, "========= WILL =========\n")
+		fmt.Fprintf(w, "%-34s%v / %v\n", fmt.Sprintf("Connected / Killed (%v):", wr.Kill), wr.Connected, wr.Killed)
+		fmt.Fprintf(w, "Will Ratio:                       %.3f (%d/%d)\n", wr.Ratio, wr.Received, wr.Killed)
+		fmt.Fprintf(w, "Missing / Duplicate Wills:        %v / %v\n", wr.Missing, wr.Duplicates)
+		fmt.Fprintf(w, "Unexpected Wills:                 %v\n", wr.Unexpected)
+		fmt.Fprintf(w, "Will Latency Avg (ms):            %.3f\n", wr.WillTimeMean)
+		fmt.Fprintf(w, "Will Latency Min (ms):            %.3f\n", wr.WillTimeMin)
+		fmt.Fprintf(w, "Will Latency Max (ms):            %.3f\n", wr.WillTimeMax)
+		fmt.Fprintf(w, "Will Latency P50 (ms):            %.3f\n", wr.WillTimeP50)
+		fmt.Fprintf(w, "Will Latency P90 (ms):            %.3f\n", wr.WillTimeP90)
+		fmt.Fprintf(w, "Will Latency P99 (ms):            %.3f\n", wr.WillTimeP99)
+	}
+	if f := totals.Faults; f != nil {
+		fmt.Fprintf(w, "========= FAULTS =========\n")
+		fmt.Fprintf(w, "Latency / Jitter (ms):            %.3f / %.3f\n", f.Latency, f.Jitter)
+		fmt.Fprintf(w, "Bandwidth (bytes/sec):            %v\n", f.Bandwidth)
+		fmt.Fprintf(w, "Drop Rate:                        %.3f\n", f.DropRate)
+		fmt.Fprintf(w, "Reset Interval (sec) / Ratio:     %.3f / %.3f\n", f.ResetInterval, f.ResetRatio)
+		fmt.Fprintf(w, "Seed:                             %v\n", f.Seed)
+		fmt.Fprintf(w, "Proxied Connections:              %v\n", f.Connections)
+		fmt.Fprintf(w, "Injected Resets / Drops:          %v / %v\n", f.Resets, f.Drops)
+		fmt.Fprintf(w, "Bytes Up / Down:                  %v / %v\n", f.BytesUp, f.BytesDown)
+	}
+	for _, n := range totals.Nodes {
+		fmt.Fprintf(w, "========= NODE %v (%v) =========\n", n.Broker, totals.Strategy)
+		fmt.Fprintf(w, "Number of Clients:                %v\n", n.Clients)
+		fmt.Fprintf(w, "Ratio:                            %.3f (%d/%d)\n", n.Ratio, n.Successes, n.Successes+n.Failures)
+		fmt.Fprintf(w, "Msg Latency Avg (ms):             %.3f\n", n.MsgTimeMean)
+		fmt.Fprintf(w, "Msg Latency Min (ms):             %.3f\n", n.MsgTimeMin)
+		fmt.Fprintf(w, "Msg Latency Max (ms):             %.3f\n", n.MsgTimeMax)
+		fmt.Fprintf(w, "Bandwidth (msg/sec):              %.3f\n", n.MsgsPerSec)
+	}
+	if c := totals.Connect; c != nil {
+		fmt.Fprintf(w, "========= CONNECT =========\n")
+		fmt.Fprintf(w, "Attempted Connections:            %v\n", c.Attempted)
+		fmt.Fprintf(w, "Accepted / Refused / Failed:      %v / %v / %v\n", c.Accepted, c.Refused, c.Failed)
+		fmt.Fprintf(w, "Max Concurrent Connections:       %v\n", c.MaxConcurrent)
+		fmt.Fprintf(w, "Connect Rate (conn/sec):          %.3f\n", c.ConnectsPerSec)
+		fmt.Fprintf(w, "Connect Latency Avg (ms):         %.3f\n", c.ConnectTimeMean)
+		fmt.Fprintf(w, "Connect Latency Min (ms):         %.3f\n", c.ConnectTimeMin)
+		fmt.Fprintf(w, "Connect Latency Max (ms):         %.3f\n", c.ConnectTimeMax)
+		fmt.Fprintf(w, "Connect Latency Std (ms):         %.3f\n", c.ConnectTimeStd)
+		fmt.Fprintf(w, "Connect Latency P50 (ms):         %.3f\n", c.ConnectTimeP50)
+		fmt.Fprintf(w, "Connect Latency P90 (ms):         %.3f\n", c.ConnectTimeP90)
+		fmt.Fprintf(w, "Connect Latency P99 (ms):         %.3f\n", c.ConnectTimeP99)
+	}
+	if r := totals.Reconnect; r != nil {
+		fmt.Fprintf(w, "========= RECONNECT =========\n")
+		fmt.Fprintf(w, "Reconnect Policy:                 %v\n", r.Policy)
+		fmt.Fprintf(w, "Disconnects / Reconnects:         %v / %v\n", r.Disconnects, r.Reconnects)
+		fmt.Fprintf(w, "Failed while Disconnected:        %v\n", r.DisconnectedFailures)
+		fmt.Fprintf(w, "Reconnect Time Avg (ms):          %.3f\n", r.ReconnectTimeMean)
+		fmt.Fprintf(w, "Reconnect Time Min (ms):          %.3f\n", r.ReconnectTimeMin)
+		fmt.Fprintf(w, "Reconnect Time Max (ms):          %.3f\n", r.ReconnectTimeMax)
+		fmt.Fprintf(w, "Reconnect Time P50 (ms):          %.3f\n", r.ReconnectTimeP50)
+		fmt.Fprintf(w, "Reconnect Time P99 (ms):          %.3f\n", r.ReconnectTimeP99)
+		for _, e := range r.Timeline {
+			if e.Reconnected.IsZero() {
+				fmt.Fprintf(w, "  %v %v lost: %v (not recovered after %d attempt(s))\n", e.Lost.Format(time.RFC3339Nano), e.ClientID, e.Reason, e.Attempts)
+			} else {
+				fmt.Fprintf(w, "  %v %v lost: %v (recovered in %.0f ms, %d attempt(s))\n", e.Lost.Format(time.RFC3339Nano), e.ClientID, e.Reason, e.ReconnectTime, e.Attempts)
+			}
+		}
+	}
+	if s := totals.Session; s != nil {
+		fmt.Fprintf(w, "========= SESSION =========\n")
+		fmt.Fprintf(w, "Offline Time (sec):               %.3f\n", s.OfflineTime)
+		fmt.Fprintf(w, "Queued Messages:                  %v\n", s.Queued)
+		fmt.Fprintf(w, "Lost Messages:                    %v\n", s.Lost)
+		fmt.Fprintf(w, "Duplicate Messages:               %v\n", s.Duplicates)
+		fmt.Fprintf(w, "Queue Drain Time Avg (ms):        %.3f\n", s.DrainTimeMean)
+		fmt.Fprintf(w, "Queue Drain Time Min (ms):        %.3f\n", s.DrainTimeMin)
+		fmt.Fprintf(w, "Queue Drain Time Max (ms):        %.3f\n", s.DrainTimeMax)
+		fmt.Fprintf(w, "Redelivery Latency Avg (ms):      %.3f\n", s.RedeliveryTimeMean)
+		fmt.Fprintf(w, "Redelivery Latency P50 (ms):      %.3f\n", s.RedeliveryTimeP50)
+		fmt.Fprintf(w, "Redelivery Latency P90 (ms):      %.3f\n", s.RedeliveryTimeP90)
+		fmt.Fprintf(w, "Redelivery Latency P99 (ms):      %.3f\n", s.RedeliveryTimeP99)
+		fmt.Fprintf(w, "Redelivery Latency Max (ms):      %.3f\n", s.RedeliveryTimeMax)
+	}
+	if c := totals.Churn; c != nil {
+		fmt.Fprintf(w, "========= CHURN =========\n")
+		fmt.Fprintf(w, "Subscribes / Unsubscribes:        %v / %v\n", c.Subscribes, c.Unsubscribes)
+		fmt.Fprintf(w, "Failed Operations:                %v\n", c.OpFailures)
+		fmt.Fprintf(w, "Operations Rate (op/sec):         %.3f\n", c.OpsPerSec)
+		fmt.Fprintf(w, "Stale Messages:                   %v\n", c.Stale)
+		fmt.Fprintf(w, "Subscribe Latency Avg (ms):       %.3f\n", c.SubTimeMean)
+		fmt.Fprintf(w, "Subscribe Latency Min (ms):       %.3f\n", c.SubTimeMin)
+		fmt.Fprintf(w, "Subscribe Latency Max (ms):       %.3f\n", c.SubTimeMax)
+		fmt.Fprintf(w, "Subscribe Latency P50 (ms):       %.3f\n", c.SubTimeP50)
+		fmt.Fprintf(w, "Subscribe Latency P90 (ms):       %.3f\n", c.SubTimeP90)
+		fmt.Fprintf(w, "Subscribe Latency P99 (ms):       %.3f\n", c.SubTimeP99)
+		fmt.Fprintf(w, "Unsubscribe Latency Avg (ms):     %.3f\n", c.UnsubTimeMean)
+		fmt.Fprintf(w, "Unsubscribe Latency Min (ms):     %.3f\n", c.UnsubTimeMin)
+		fmt.Fprintf(w, "Unsubscribe Latency Max (ms):     %.3f\n", c.UnsubTimeMax)
+		fmt.Fprintf(w, "Unsubscribe Latency P50 (ms):     %.3f\n", c.UnsubTimeP50)
+		fmt.Fprintf(w, "Unsubscribe Latency P90 (ms):     %.3f\n", c.UnsubTimeP90)
+		fmt.Fprintf(w, "Unsubscribe Latency P99 (ms):     %.3f\n", c.UnsubTimeP99)
+	}
+	if r := totals.Retained; r != nil {
+		fmt.Fprintf(w, "========= RETAINED =========\n")
+		fmt.Fprintf(w, "Retained Messages:                %v\n", r.Messages)
+		fmt.Fprintf(w, "Load Time (sec):                  %.3f\n", r.LoadTime)
+		fmt.Fprintf(w, "Load Rate (msg/sec):              %.3f\n", r.LoadMsgsPerSec)
+		fmt.Fprintf(w, "Load Failures:                    %v\n", r.LoadFailures)
+		fmt.Fprintf(w, "Completeness:                     %.3f\n", r.Completeness)
+		fmt.Fprintf(w, "Complete Subscribers:             %v/%v\n", r.CompleteSubscribers, totals.Clients)
+		fmt.Fprintf(w, "Retained Set Time Avg (ms):       %.3f\n", r.SetTimeMean)
+		fmt.Fprintf(w, "Retained Set Time Min (ms):       %.3f\n", r.SetTimeMin)
+		fmt.Fprintf(w, "Retained Set Time Max (ms):       %.3f\n", r.SetTimeMax)
+		fmt.Fprintf(w, "Retained Set Time Std (ms):       %.3f\n", r.SetTimeStd)
+	}
+	if t := totals.Topology; t != nil {
+		fmt.Fprintf(w, "========= TOPOLOGY =========\n")
+		fmt.Fprintf(w, "Topology:                         %v\n", t.Name)
+		fmt.Fprintf(w, "Publishers / Subscribers:         %v / %v\n", t.Publishers, t.Subscribers)
+		fmt.Fprintf(w, "Topics:                           %v\n", t.Topics)
+		if t.Expected > 0 {
+			fmt.Fprintf(w, "Expected per Subscriber:          %v\n", t.ExpectedPerSubscriber)
+			fmt.Fprintf(w, "Delivery Ratio:                   %.3f (%d/%d)\n", t.DeliveryRatio, t.Received, t.Expected)
+			fmt.Fprintf(w, "Complete Subscribers:             %v/%v\n", t.CompleteSubscribers, t.Subscribers)
+		}
+	}
+	if t := totals.TopicDistribution; t != nil {
+		fmt.Fprintf(w, "========= TOPICS =========\n")
+		fmt.Fprintf(w, "Topic Selection:                  %v\n", t.Strategy)
+		fmt.Fprintf(w, "Topics Used:                      %v\n", t.Topics)
+		fmt.Fprintf(w, "Messages per Topic Avg:           %.3f\n", t.MsgPerTopicMean)
+		fmt.Fprintf(w, "Messages per Topic Min:           %.3f\n", t.MsgPerTopicMin)
+		fmt.Fprintf(w, "Messages per Topic Max:           %.3f\n", t.MsgPerTopicMax)
+		fmt.Fprintf(w, "Messages per Topic Std:           %.3f\n", t.MsgPerTopicStd)
+	}
+	for _, g := range totals.Groups {
+		fmt.Fprintf(w, "========= GROUP %v =========\n", g.Name)
+		fmt.Fprintf(w, "Members:                          %v\n", g.Members)
+		if g.Expected > 0 {
+			fmt.Fprintf(w, "Delivery Ratio:                   %.3f (%d/%d)\n", g.Ratio, g.Received, g.Expected)
+		} else {
+			fmt.Fprintf(w, "Delivery Ratio:                   %.3f (%d)\n", g.Ratio, g.Received)
+		}
+		fmt.Fprintf(w, "Messages per Member Avg:          %.3f\n", g.MsgPerMemberMean)
+		fmt.Fprintf(w, "Messages per Member Min:          %.3f\n", g.MsgPerMemberMin)
+		fmt.Fprintf(w, "Messages per Member Max:          %.3f\n", g.MsgPerMemberMax)
+		fmt.Fprintf(w, "Messages per Member Std:          %.3f\n", g.MsgPerMemberStd)
+		fmt.Fprintf(w, "Gini Coefficient:                 %.3f\n", g.Gini)
+	}
+	fmt.Fprintf(w, "==============================\n")
+}
+
+// sendResults posts json data to azure log analytics.
+func sendResults(data string) error {
+
+	dateString := time.Now().UTC().Format(time.RFC1123)
+	dateString = strings.Replace(dateString, "UTC", "GMT", -1)
+
+	stringToHash := "POST\n" + strconv.Itoa(utf8.RuneCountInString(data)) + "\napplication/json\n" + "x-ms-date:" + dateString + "\n/api/logs"
+	hashedString, err := buildSignature(stringToHash, sharedKey)
+	if err != nil {
+		return err
+	}
+
+	signature := "SharedKey " + customerID + ":" + hashedString
+	url := "https://" + customerID + ".ods.opinsights.azure.com/api/logs?api-version=2016-04-01"
+
+	client := &http.Client{}
+	req, err := http.NewRequest("POST", url, bytes.NewReader([]byte(data)))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Log-Type", logType)
+	req.Header.Add("Authorization", signature)
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("x-ms-date", dateString)
+	req.Header.Add("time-generated-field", timeStampField)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	log.Println(resp.Status)
+	return nil
+}
+
+func buildSignature(message, secret string) (string, error) {
+
+	keyBytes, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, keyBytes)
+	mac.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
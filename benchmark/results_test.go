@@ -0,0 +1,91 @@
+package benchmark
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGini(t *testing.T) {
+	tests := []struct {
+		data []float64
+		want float64
+	}{
+		{nil, 0},
+		{[]float64{0, 0, 0}, 0},
+		{[]float64{5, 5, 5, 5}, 0},
+		// one member of four receives everything: (n-1)/n
+		{[]float64{0, 0, 0, 100}, 0.75},
+		{[]float64{3, 1, 2}, 2.0 / 9},
+		{[]float64{1, 2, 3, 4}, 0.25},
+	}
+	for _, tt := range tests {
+		if got := gini(tt.data); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("gini(%v) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestCalculateGroupResults(t *testing.T) {
+	groups := []*SubscriberGroup{NewSubscriberGroup(0, 300), NewSubscriberGroup(1, 0), NewSubscriberGroup(2, 100)}
+	results := []*RunResults{
+		{ID: "0", Group: "group0", Successes: 100},
+		{ID: "1", Group: "group0", Successes: 100},
+		{ID: "2", Group: "group0", Successes: 70},
+		{ID: "3", Group: "group1", Successes: 90, Failures: 10},
+		{ID: "4", Successes: 100},
+	}
+	res := calculateGroupResults(results, groups)
+	if len(res) != 3 {
+		t.Fatalf("%d group results, want 3", len(res))
+	}
+
+	g := res[0]
+	if g.Members != 3 || g.Received != 270 || g.Ratio != 0.9 {
+		t.Errorf("group0: %d members, %d received, ratio %v, want 3, 270, 0.9", g.Members, g.Received, g.Ratio)
+	}
+	if g.MsgPerMemberMin != 70 || g.MsgPerMemberMax != 100 || g.MsgPerMemberMean != 90 {
+		t.Errorf("group0: per member min %v, max %v, mean %v, want 70, 100, 90", g.MsgPerMemberMin, g.MsgPerMemberMax, g.MsgPerMemberMean)
+	}
+	if math.Abs(g.Gini-gini([]float64{100, 100, 70})) > 1e-9 || g.MsgPerMemberStd == 0 {
+		t.Errorf("group0: gini %v, std %v", g.Gini, g.MsgPerMemberStd)
+	}
+
+	// without an expected count the ratio is against the failures
+	if g := res[1]; g.Ratio != 0.9 || g.MsgPerMemberStd != 0 {
+		t.Errorf("group1: ratio %v, std %v, want 0.9 and 0 for a single member", g.Ratio, g.MsgPerMemberStd)
+	}
+	if g := res[2]; g.Members != 0 || g.Ratio != 0 {
+		t.Errorf("group2: %d members, ratio %v, want an empty group", g.Members, g.Ratio)
+	}
+}
+
+func TestLoadRepeatedResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "repeated.json")
+	data := `{"results": [
+		{"runs": [], "totals": {"run_id": "1", "total_msgs_per_sec": 300}},
+		{"runs": [], "totals": {"run_id": "2", "total_msgs_per_sec": 100}},
+		{"runs": [], "totals": {"run_id": "3", "total_msgs_per_sec": 200}}
+	], "repeat": {"runs": 3}}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := LoadResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Totals.TestRunID != "3" {
+		t.Errorf("loaded run %v, want the median run 3", res.Totals.TestRunID)
+	}
+	if !res.Totals.has("total_msgs_per_sec") || res.Totals.has("msg_time_p99") {
+		t.Errorf("fields %v, want run_id and total_msgs_per_sec only", res.Totals.fields)
+	}
+}
@@ -0,0 +1,278 @@
+package benchmark
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/GaryBoone/GoStats/stats"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// ModeRetained loads retained messages to a topic tree and measures
+	// how long new subscribers take to receive the full retained set.
+	ModeRetained = "retained"
+
+	// retainedBranchSize is the number of retained topics per branch of the topic tree.
+	retainedBranchSize = 100
+)
+
+// newRetainedRoot returns the root of a new retained topic tree. Every run loads its own
+// tree, so messages left retained by an aborted earlier run are not counted.
+func newRetainedRoot() string {
+	return fmt.Sprintf("/retained/%x", time.Now().UnixNano())
+}
+
+// getRetainedTopics returns the topic tree under the root for the given number of retained
+// messages: {root}/b0/t0 .. {root}/b0/t99, {root}/b1/t100 .. etc.
+func getRetainedTopics(root string, count int) []string {
+	topics := make([]string, count)
+	for i := range topics {
+		topics[i] = fmt.Sprintf("%s/b%d/t%d", root, i/retainedBranchSize, i)
+	}
+	return topics
+}
+
+// RetainedLoader publishes retained messages to the topic tree before the test
+// and clears them after the test is over.
+type RetainedLoader struct {
+	brokerURL  string
+	brokerUser string
+	brokerPass string
+	ids        *clientIDRegistry
+	Topics     []string
+	MsgSize    int
+	MsgQoS     byte
+	Quiet      bool
+	Panic      bool
+
+	// clear is true when the loader removes retained messages
+	// by publishing empty payloads.
+	clear bool
+}
+
+func (c RetainedLoader) ClientId() string {
+	if c.clear {
+		return "retained-cleaner"
+	}
+	return "retained-loader"
+}
+
+func (c RetainedLoader) BrokerUrl() string {
+	return c.brokerURL
+}
+
+func (c RetainedLoader) BrokerUser() string {
+	return c.brokerUser
+}
+
+func (c RetainedLoader) BrokerPass() string {
+	return c.brokerPass
+}
+
+func (c RetainedLoader) PanicMode() bool {
+	return c.Panic
+}
+
+func (c RetainedLoader) clientIDs() *clientIDRegistry {
+	return c.ids
+}
+
+// Run publishes a retained message to every topic and reports publish latencies.
+func (c RetainedLoader) Run(res chan *RunResults) {
+	done := make(chan *RunResults)
+	onConnected := func(client mqtt.Client) {
+		runResults := &RunResults{
+			ID: c.ClientId(),
+		}
+		payload := make([]byte, c.MsgSize)
+		if c.clear {
+			payload = []byte{}
+		}
+
+		start := time.Now()
+		times := make([]float64, 0, len(c.Topics))
+		for _, topic := range c.Topics {
+			sent := time.Now()
+			token := client.Publish(topic, c.MsgQoS, true, payload)
+			token.Wait()
+			if token.Error() != nil {
+				log.Printf("CLIENT %v Error sending retained message: %v\n", c.ClientId(), token.Error())
+				if c.Panic {
+					panic(token.Error())
+				}
+				runResults.Failures++
+				continue
+			}
+			runResults.Successes++
+			times = append(times, float64(time.Since(sent).Milliseconds()))
+		}
+		runResults.ClientRunTime = time.Since(start).Seconds()
+		if len(times) > 0 {
+			runResults.MsgTimeMin = stats.StatsMin(times)
+			runResults.MsgTimeMax = stats.StatsMax(times)
+			runResults.MsgTimeMean = stats.StatsMean(times)
+		}
+
+		client.Disconnect(1000)
+		done <- runResults
+	}
+
+	if !c.Quiet {
+		log.Printf("CLIENT %v is publishing %v retained messages\n", c.ClientId(), len(c.Topics))
+	}
+	client := connect(c, onConnected)
+	if !client.IsConnected() {
+		res <- &RunResults{ID: c.ClientId(), Failures: int64(len(c.Topics))}
+		return
+	}
+	res <- <-done
+}
+
+// Load publishes the retained messages and waits for the results.
+func (c RetainedLoader) Load() *RunResults {
+	res := make(chan *RunResults)
+	go c.Run(res)
+	return <-res
+}
+
+// Clear removes the retained messages published by the loader.
+func (c RetainedLoader) Clear() *RunResults {
+	c.clear = true
+	return c.Load()
+}
+
+// RetainedSubscriber subscribes to the retained topic tree and measures how long it
+// takes to receive the full set of retained messages.
+type RetainedSubscriber struct {
+	id         int
+	brokerURL  string
+	brokerUser string
+	brokerPass string
+	ids        *clientIDRegistry
+
+	// TopicFilter matches the whole retained topic tree, Expected is the number
+	// of retained messages loaded to the tree.
+	TopicFilter string
+	Expected    int
+	MsgQoS      byte
+	Quiet       bool
+	Panic       bool
+
+	// TestDuration is the max time to wait for the full retained set.
+	TestDuration time.Duration
+
+	// IdleTimeout is the max idle time b/w incoming retained messages.
+	IdleTimeout time.Duration
+
+	// Stop is closed to stop waiting for the retained set, nil if not used.
+	Stop chan bool
+}
+
+func (c RetainedSubscriber) ClientId() string {
+	return fmt.Sprintf("retained-sub-%d", c.id)
+}
+
+func (c RetainedSubscriber) BrokerUrl() string {
+	return c.brokerURL
+}
+
+func (c RetainedSubscriber) BrokerUser() string {
+	return c.brokerUser
+}
+
+func (c RetainedSubscriber) BrokerPass() string {
+	return c.brokerPass
+}
+
+func (c RetainedSubscriber) PanicMode() bool {
+	return c.Panic
+}
+
+func (c RetainedSubscriber) clientIDs() *clientIDRegistry {
+	return c.ids
+}
+
+func (c RetainedSubscriber) Run(res chan *RunResults) {
+	rcvMsgs := make(chan *Message, c.Expected)
+	runResults := &RunResults{
+		ID: c.ClientId(),
+	}
+
+	onConnected := func(client mqtt.Client) {
+		subscribed := time.Now()
+		onMessage := func(inner mqtt.Client, m mqtt.Message) {
+			// only messages delivered from the retained store are measured
+			if !m.Retained() {
+				return
+			}
+			rcvMsgs <- &Message{
+				Topic:     m.Topic(),
+				QoS:       m.Qos(),
+				Sent:      subscribed,
+				Delivered: time.Now(),
+			}
+		}
+
+		token := client.Subscribe(c.TopicFilter, c.MsgQoS, onMessage)
+		token.Wait()
+		if token.Error() != nil {
+			log.Printf("CLIENT %v Error subscribing to the topic %v: %v\n", c.ClientId(), c.TopicFilter, token.Error())
+			if c.Panic {
+				panic(token.Error())
+			}
+		}
+	}
+
+	connected := time.Now()
+	client := connect(c, onConnected)
+	defer client.Disconnect(1000)
+
+	testTimer := time.NewTimer(c.TestDuration)
+	idleTimer := time.NewTimer(c.IdleTimeout)
+	times := make([]float64, 0, c.Expected)
+loop:
+	for runResults.Successes < int64(c.Expected) {
+		select {
+		case m := <-rcvMsgs:
+			runResults.Successes++
+			runResults.RetainedSetTime = float64(m.Delivered.Sub(m.Sent).Milliseconds())
+			times = append(times, runResults.RetainedSetTime)
+			idleTimer.Reset(c.IdleTimeout)
+		case <-testTimer.C:
+			log.Printf("CLIENT %v test duration is over: %v\n", c.ClientId(), c.TestDuration)
+			break loop
+		case <-c.Stop:
+			break loop
+		case <-idleTimer.C:
+			log.Printf("CLIENT %v stopping after idle time: %v\n", c.ClientId(), c.IdleTimeout)
+			break loop
+		}
+	}
+
+	if !c.Quiet && runResults.Successes == int64(c.Expected) {
+		log.Printf("CLIENT %v received the full retained set\n", c.ClientId())
+	}
+	c.report(res, runResults, times, connected)
+}
+
+func (c RetainedSubscriber) report(res chan *RunResults, runResults *RunResults, times []float64, connected time.Time) {
+	// missing retained messages are reported as failures
+	runResults.Failures = int64(c.Expected) - runResults.Successes
+	if runResults.Failures > 0 {
+		runResults.RetainedSetTime = 0
+	}
+	runResults.ClientRunTime = time.Since(connected).Seconds()
+	if len(times) > 0 {
+		runResults.MsgTimeMin = stats.StatsMin(times)
+		runResults.MsgTimeMax = stats.StatsMax(times)
+		runResults.MsgTimeMean = stats.StatsMean(times)
+	}
+
+	// calculate std if sample is > 1, otherwise leave as 0 (convention)
+	if len(times) > 1 {
+		runResults.MsgTimeStd = stats.StatsSampleStandardDeviation(times)
+	}
+	res <- runResults
+}
@@ -0,0 +1,47 @@
+package benchmark
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetRetainedTopics(t *testing.T) {
+	topics := getRetainedTopics("/retained/1", 250)
+	if len(topics) != 250 {
+		t.Fatalf("%d topics, want 250", len(topics))
+	}
+	for i, want := range map[int]string{0: "/retained/1/b0/t0", 99: "/retained/1/b0/t99", 100: "/retained/1/b1/t100", 249: "/retained/1/b2/t249"} {
+		if topics[i] != want {
+			t.Errorf("topic %d = %v, want %v", i, topics[i], want)
+		}
+	}
+
+	if root := newRetainedRoot(); !strings.HasPrefix(root, "/retained/") || strings.Count(root, "/") != 2 {
+		t.Errorf("retained root %v, want a tree under /retained", root)
+	}
+}
+
+func TestCalculateRetainedResults(t *testing.T) {
+	load := &RunResults{Successes: 100, Failures: 2, ClientRunTime: 0.5}
+	results := []*RunResults{
+		{Successes: 100, RetainedSetTime: 20},
+		{Successes: 100, RetainedSetTime: 40},
+		// incomplete set, no set time
+		{Successes: 60, Failures: 40},
+	}
+	res := calculateRetainedResults(results, load, 100)
+	if res.LoadFailures != 2 || res.LoadMsgsPerSec != 200 {
+		t.Errorf("load failures %d, rate %v, want 2 and 200", res.LoadFailures, res.LoadMsgsPerSec)
+	}
+	if res.CompleteSubscribers != 2 || res.Completeness != 260.0/300 {
+		t.Errorf("%d complete subscribers, completeness %v, want 2 and 0.867", res.CompleteSubscribers, res.Completeness)
+	}
+	if res.SetTimeMin != 20 || res.SetTimeMax != 40 || res.SetTimeMean != 30 {
+		t.Errorf("set time min %v, max %v, mean %v, want 20, 40, 30", res.SetTimeMin, res.SetTimeMax, res.SetTimeMean)
+	}
+
+	res = calculateRetainedResults(results[2:], &RunResults{}, 100)
+	if res.CompleteSubscribers != 0 || res.SetTimeMean != 0 || res.LoadMsgsPerSec != 0 {
+		t.Errorf("results without complete sets: %+v", res)
+	}
+}
@@ -0,0 +1,891 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	rehttp "github.com/PuerkitoBio/rehttp"
+)
+
+// Config describes a benchmark, the fields mirror the command line flags.
+type Config struct {
+	// Pub and Sub run the test clients as publishers or subscribers,
+	// Mode runs one of the other benchmark modes instead.
+	Pub  bool
+	Sub  bool
+	Mode string
+
+	// Broker is the broker endpoint as scheme://host:port, or a comma separated list
+	// of cluster nodes, BrokerStrategy is how clients are assigned to the nodes.
+	Broker         string
+	BrokerStrategy string
+	Username       string
+	Password       string
+
+	Topics      int
+	TopicSelect string
+	Topology    string
+	Groups      int
+	Clients     int
+	QoS         int
+	Size        int
+	Count       int
+	Duration    time.Duration
+	IdleTimeout time.Duration
+	Quiet       bool
+	Panic       bool
+
+	// DrainTimeout is the max time to wait for the clients to drain the remaining
+	// messages and report their results when the test is interrupted.
+	DrainTimeout time.Duration
+
+	// Dop is the max number of threads, it is only reported: the caller is in charge of GOMAXPROCS.
+	Dop    int
+	RunID  string
+	CaseID string
+
+	// WaitFor is the ready endpoint of a subscriber tool publishers wait for before starting,
+	// ReadyAddr is the address subscribers expose their ready endpoint on, empty to disable.
+	WaitFor   string
+	ReadyAddr string
+
+	Reconnect            string
+	ReconnectMaxInterval time.Duration
+
+	// Faults are the network conditions injected by the fault proxy.
+	Faults NetworkConditions
+
+	OfflineAfter   time.Duration
+	Offline        time.Duration
+	ChurnRate      float64
+	ConnectRate    float64
+	ConnectHold    time.Duration
+	ConnectClose   bool
+	WillKill       string
+	WillKillRatio  float64
+	WillKillAfter  time.Duration
+	WillKeepAlive  time.Duration
+	Responders     int
+	RequestTimeout time.Duration
+
+	// Profile is the device profile of pub/sub clients, Azure is used by the azure profile.
+	Profile string
+	Azure   AzureProfile
+
+	// Credentials is the path of a per-client credentials file.
+	Credentials       string
+	CredentialsAssign string
+
+	// ClientID is the client id template, StableIDs keeps client ids stable across runs.
+	ClientID  string
+	StableIDs bool
+
+	// KeepSamples exports the latencies of all messages with the results of each client.
+	KeepSamples bool
+
+	// Interval records the time series of the messages with the results, if > 0.
+	// It overrides the interval of the hooks.
+	Interval time.Duration
+}
+
+// DefaultConfig returns the configuration with the defaults of the command line flags.
+func DefaultConfig() Config {
+	return Config{
+		Broker:               "tcp://localhost:1883",
+		BrokerStrategy:       BrokerRoundRobin,
+		Topics:               1,
+		TopicSelect:          TopicSelectRoundRobin,
+		Clients:              10,
+		QoS:                  1,
+		Size:                 100,
+		Duration:             60 * time.Minute,
+		IdleTimeout:          10 * time.Second,
+		DrainTimeout:         5 * time.Second,
+		Dop:                  1,
+		ReadyAddr:            ":8080",
+		Reconnect:            ReconnectOff,
+		ReconnectMaxInterval: 30 * time.Second,
+		Faults: NetworkConditions{
+			ResetRatio: 0.1,
+			Seed:       1,
+		},
+		OfflineAfter:   10 * time.Second,
+		Offline:        10 * time.Second,
+		ChurnRate:      10,
+		WillKill:       WillKillReset,
+		WillKillRatio:  0.5,
+		WillKillAfter:  5 * time.Second,
+		WillKeepAlive:  30 * time.Second,
+		Responders:     1,
+		RequestTimeout: 5 * time.Second,
+		Azure: AzureProfile{
+			DevicePrefix: "bench-device-",
+			Direction:    AzureD2C,
+			TokenTTL:     time.Hour,
+		},
+		CredentialsAssign: CredentialsRoundRobin,
+	}
+}
+
+// plan is the validated configuration with the values derived from it.
+type plan struct {
+	brokers     []string
+	topics      int
+	clients     int
+	topology    *Topology
+	azure       *AzureProfile
+	credentials []*Credentials
+	clientIDs   ClientIDTemplate
+}
+
+// Validate returns an error if the configuration is invalid.
+func (c Config) Validate() error {
+	_, err := c.plan()
+	return err
+}
+
+func (c Config) plan() (*plan, error) {
+	p := &plan{topics: c.Topics, clients: c.Clients}
+	if c.Mode != "" && (c.Pub || c.Sub) {
+		return nil, errors.New("must specify either pub, sub or mode")
+	}
+
+	if c.Mode == "" && !(c.Pub != c.Sub) {
+		return nil, errors.New("must specify either pub or sub mode")
+	}
+
+	if c.Mode != "" && !isValidMode(c.Mode) {
+		return nil, fmt.Errorf("unsupported mode: %v", c.Mode)
+	}
+
+	p.brokers = getBrokerURLs(c.Broker)
+	if len(p.brokers) == 0 {
+		return nil, errors.New("at least one broker should be specified")
+	}
+
+	if !isValidBrokerStrategy(c.BrokerStrategy) {
+		return nil, fmt.Errorf("unsupported broker strategy: %v", c.BrokerStrategy)
+	}
+
+	if c.BrokerStrategy == BrokerSplit && len(p.brokers) < 2 {
+		return nil, fmt.Errorf("split broker strategy requires at least two brokers, given: %v", len(p.brokers))
+	}
+
+	f := c.Faults
+	if f.Latency < 0 || f.Jitter < 0 || f.Bandwidth < 0 || f.ResetInterval < 0 {
+		return nil, errors.New("fault latency, jitter, bandwidth and reset interval should be >= 0")
+	}
+
+	if f.DropRate < 0 || f.DropRate > 1 || f.ResetRatio < 0 || f.ResetRatio > 1 {
+		return nil, errors.New("fault drop rate and reset ratio should be b/w 0 and 1")
+	}
+
+	if !isValidReconnectMode(c.Reconnect) {
+		return nil, fmt.Errorf("unsupported reconnect policy: %v", c.Reconnect)
+	}
+
+	if c.Reconnect != ReconnectOff && c.Mode != "" {
+		return nil, errors.New("reconnect policies are only supported in pub or sub mode")
+	}
+
+	if c.Mode == ModeSession && c.QoS == 0 {
+		return nil, errors.New("session mode requires QoS > 0 for messages to be queued")
+	}
+
+	if c.Mode == ModeWill && !isValidWillKill(c.WillKill) {
+		return nil, fmt.Errorf("unsupported will kill: %v", c.WillKill)
+	}
+
+	if c.Mode == ModeWill && (c.WillKillRatio < 0 || c.WillKillRatio > 1) {
+		return nil, fmt.Errorf("will kill ratio should be b/w 0 and 1, given: %v", c.WillKillRatio)
+	}
+
+	if c.Mode == ModeRequest && (c.Responders < 1 || c.Responders > c.Topics) {
+		return nil, fmt.Errorf("number of responders should be >= 1 and <= topics count, given: %v", c.Responders)
+	}
+
+	if c.Profile != "" {
+		if c.Profile != ProfileAzure {
+			return nil, fmt.Errorf("unsupported profile: %v", c.Profile)
+		}
+
+		if c.Mode != "" || c.Topology != "" || c.Groups > 0 {
+			return nil, errors.New("profiles are only supported in pub or sub mode without topology or groups")
+		}
+
+		if c.Azure.Hub == "" || c.Azure.Key == "" {
+			return nil, errors.New("azure profile requires the hub name and the device key")
+		}
+
+		if !isValidAzureDirection(c.Azure.Direction) {
+			return nil, fmt.Errorf("unsupported azure direction: %v", c.Azure.Direction)
+		}
+
+		azure := c.Azure
+		p.azure = &azure
+		// every device owns a single topic
+		if p.azure.IsDevice(c.Pub) && c.Topics != c.Clients {
+			return nil, fmt.Errorf("azure devices own a single topic each, topics count should be equal to the number of clients, given: %v", c.Topics)
+		}
+
+		if _, err := p.azure.Credentials(p.azure.DeviceID(0)); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Credentials != "" {
+		if c.Mode != "" || p.azure != nil {
+			return nil, errors.New("credentials files are only supported in pub or sub mode without a profile")
+		}
+
+		if !isValidCredentialsAssign(c.CredentialsAssign) {
+			return nil, fmt.Errorf("unsupported credentials assignment: %v", c.CredentialsAssign)
+		}
+
+		var err error
+		if p.credentials, err = loadCredentials(c.Credentials); err != nil {
+			return nil, err
+		}
+	}
+
+	var hostname, _ = os.Hostname()
+	p.clientIDs = ClientIDTemplate{Template: c.ClientID, RunID: c.RunID, Host: hostname}
+	if p.clientIDs.Template == "" {
+		p.clientIDs.Template = DefaultClientIDTemplate
+		if c.StableIDs {
+			p.clientIDs.Template = StableClientIDTemplate
+		}
+	}
+	if c.StableIDs && !p.clientIDs.Stable() {
+		return nil, fmt.Errorf("stable client ids can not use the {time} placeholder: %v", p.clientIDs.Template)
+	}
+
+	if c.Mode == ModeChurn && c.ChurnRate <= 0 {
+		return nil, fmt.Errorf("churn rate should be > 0, given: %v", c.ChurnRate)
+	}
+
+	if c.Clients < 1 {
+		return nil, fmt.Errorf("number of clients should be > 1, given: %v", c.Clients)
+	}
+
+	if c.DrainTimeout < 0 {
+		return nil, fmt.Errorf("drain timeout should be >= 0, given: %v", c.DrainTimeout)
+	}
+
+	if c.Interval < 0 {
+		return nil, fmt.Errorf("interval should be >= 0, given: %v", c.Interval)
+	}
+
+	if c.Count < 0 {
+		return nil, fmt.Errorf("messages count should be >= 0, given: %v", c.Count)
+	}
+
+	if c.Topics < 1 {
+		return nil, fmt.Errorf("topics count should be > 1 and <= number of clients, given: %v", c.Topics)
+	}
+
+	if c.Topology != "" {
+		t, err := NewTopology(c.Topology, c.Clients)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.Mode != "" {
+			return nil, errors.New("topology presets are only supported in pub or sub mode")
+		}
+
+		if c.Count == 0 {
+			return nil, errors.New("topology presets require messages count to compute expected counts")
+		}
+
+		if c.Groups > 0 {
+			return nil, errors.New("topology presets can not be combined with shared subscription groups")
+		}
+
+		// topology presets replace the clients/topics arithmetic
+		p.topology = t
+		p.topics = t.Topics
+		if c.Pub {
+			p.clients = t.Publishers
+		} else {
+			p.clients = t.Subscribers
+		}
+	}
+
+	if !isValidTopicSelect(c.TopicSelect) {
+		return nil, fmt.Errorf("unsupported topic selection strategy: %v", c.TopicSelect)
+	}
+
+	if c.Groups < 0 {
+		return nil, fmt.Errorf("groups count should be >= 0, given: %v", c.Groups)
+	}
+
+	if c.Groups > 0 && !c.Sub {
+		return nil, errors.New("shared subscription groups are only supported in sub mode")
+	}
+
+	if c.Groups > p.clients {
+		return nil, fmt.Errorf("groups count should not be greater than the number of clients, given: %v", c.Groups)
+	}
+
+	if c.Groups > 0 && p.topics > c.Groups && p.topics%c.Groups > 0 {
+		return nil, fmt.Errorf("number of groups should be submultiple of or greater than the topics count, given: %v", p.topics%c.Groups)
+	}
+
+	if c.Sub && c.Groups == 0 && p.topics > p.clients && p.topics%p.clients > 0 {
+		return nil, fmt.Errorf("number of clients should be submultiple of or greater than the topics count, given: %v", p.topics%p.clients)
+	}
+
+	if p.credentials != nil {
+		if err := checkCredentials(p.credentials, c.CredentialsAssign, p.clients); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func isValidMode(mode string) bool {
+	switch mode {
+	case ModeRetained, ModeConnect, ModeChurn, ModeSession, ModeWill, ModeRequest:
+		return true
+	}
+	return false
+}
+
+// Runner runs a benchmark. Every run creates its clients with a client id registry of
+// its own, so several runners may run at the same time, as long as their client ids do
+// not collide on the broker, e.g. with the default {time} ids, and their subscribers do
+// not expose the ready endpoint on the same ReadyAddr.
+type Runner struct {
+	Config Config
+	Hooks  Hooks
+}
+
+// NewRunner creates a runner of the benchmark described by the config.
+func NewRunner(config Config) *Runner {
+	return &Runner{Config: config}
+}
+
+// reportGracePeriod is the time for the clients to report their results after the drain timeout.
+const reportGracePeriod = time.Second
+
+// Run runs the benchmark and returns the results of all clients. If the context is done
+// before the clients are done, the clients are stopped: subscribers drain the remaining
+// messages for up to DrainTimeout, and the results of the clients reported by then are
+// returned marked as interrupted. It returns ctx.Err() if no client reported in time.
+func (r *Runner) Run(ctx context.Context) (*JSONResults, error) {
+	cfg := r.Config
+	p, err := cfg.plan()
+	if err != nil {
+		return nil, err
+	}
+	brokers, topics, clients := p.brokers, p.topics, p.clients
+	ids := newClientIDRegistry(p.clientIDs)
+
+	if cfg.Pub && cfg.WaitFor != "" {
+		log.Printf("Waiting for subscriber at %v to start.", cfg.WaitFor)
+		if err := waitForSubscriber(ctx, cfg.WaitFor); err != nil {
+			return nil, err
+		}
+	}
+
+	// route clients through a fault proxy per broker node, paho has no pluggable dialer
+	var proxies []*FaultProxy
+	upstreams := make(map[string]string)
+	if cfg.Faults.Enabled() {
+		for i, b := range brokers {
+			cond := cfg.Faults
+			cond.Seed += int64(i) // each node gets its own reproducible sequence of faults
+			fp, err := NewFaultProxy(b, cond)
+			if err != nil {
+				return nil, err
+			}
+			defer fp.Close()
+			proxies = append(proxies, fp)
+			brokers[i] = fp.URL()
+			upstreams[brokers[i]] = b
+			log.Printf("Routing broker %v through the fault proxy at %v", b, brokers[i])
+		}
+	}
+
+	// with shared subscriptions '-count' is the expected number of messages per group
+	subscriberGroups := make([]*SubscriberGroup, cfg.Groups)
+	for i := range subscriberGroups {
+		subscriberGroups[i] = NewSubscriberGroup(i, cfg.Count)
+	}
+
+	var retainedLoader RetainedLoader
+	var retainedLoad *RunResults
+	retainedRoot := newRetainedRoot()
+	if cfg.Mode == ModeRetained {
+		retainedLoader = RetainedLoader{
+			brokerURL:  assignBroker(cfg.BrokerStrategy, brokers, true, 0),
+			brokerUser: cfg.Username,
+			brokerPass: cfg.Password,
+			ids:        ids,
+			Topics:     getRetainedTopics(retainedRoot, topics),
+			MsgSize:    cfg.Size,
+			MsgQoS:     byte(cfg.QoS),
+			Quiet:      cfg.Quiet,
+			Panic:      cfg.Panic,
+		}
+		retainedLoad = retainedLoader.Load()
+		log.Printf("Loaded %v retained messages in %.3f sec.", retainedLoad.Successes, retainedLoad.ClientRunTime)
+	}
+
+	var storm *ConnectionStorm
+	var launch <-chan time.Time
+	if cfg.Mode == ModeConnect {
+		storm = NewConnectionStorm(clients, cfg.ConnectHold, cfg.ConnectClose)
+		if cfg.ConnectRate > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.ConnectRate))
+			defer ticker.Stop()
+			launch = ticker.C
+		}
+	}
+
+	var willController *WillController
+	if cfg.Mode == ModeWill {
+		watcher := WillWatcher{
+			brokerURL:  assignBroker(cfg.BrokerStrategy, brokers, false, 0),
+			brokerUser: cfg.Username,
+			brokerPass: cfg.Password,
+			ids:        ids,
+			Panic:      cfg.Panic,
+		}
+		// the broker detects silent connections after 1.5x keepalive
+		timeout := 2*cfg.WillKeepAlive + cfg.IdleTimeout
+		willController, err = NewWillController(assignBroker(cfg.BrokerStrategy, brokers, true, 0), clients,
+			cfg.WillKillRatio, cfg.WillKill, cfg.WillKillAfter, timeout, watcher, byte(cfg.QoS), cfg.Faults.Seed)
+		if err != nil {
+			return nil, fmt.Errorf("error starting the will benchmark: %v", err)
+		}
+	}
+
+	// responders are started and subscribed before the requesters
+	respCh := make(chan *RunResults)
+	stopResponders := make(chan bool)
+	if cfg.Mode == ModeRequest {
+		ready := make(chan bool)
+		for i := 0; i < cfg.Responders; i++ {
+			c := Responder{
+				id:             i,
+				brokerURL:      assignBroker(cfg.BrokerStrategy, brokers, false, i),
+				brokerUser:     cfg.Username,
+				brokerPass:     cfg.Password,
+				ids:            ids,
+				ResponderCount: cfg.Responders,
+				TopicsCount:    topics,
+				MsgQoS:         byte(cfg.QoS),
+				Quiet:          cfg.Quiet,
+				Panic:          cfg.Panic,
+				Ready:          ready,
+				Stop:           stopResponders,
+			}
+			go c.Run(respCh)
+		}
+		for i := 0; i < cfg.Responders; i++ {
+			<-ready
+		}
+	}
+
+	reconnectPolicy := NewReconnectPolicy(cfg.Reconnect, cfg.ReconnectMaxInterval)
+	hooks := r.Hooks
+	var intervals []IntervalEvent
+	if cfg.Interval > 0 {
+		onInterval := hooks.OnInterval
+		hooks.Interval = cfg.Interval
+		hooks.OnInterval = func(e IntervalEvent) {
+			intervals = append(intervals, e)
+			if onInterval != nil {
+				onInterval(e)
+			}
+		}
+	}
+	meter := newMessageMeter(hooks)
+	onMessage := meter.onMessage()
+
+	// the results channel is buffered, so the clients reporting after the drain timeout do not block
+	resCh := make(chan *RunResults, clients)
+	stop := make(chan bool)
+	started := 0
+	startTime := time.Now()
+	meter.Start()
+start:
+	for i := 0; i < clients; i++ {
+		select {
+		case <-ctx.Done():
+			break start
+		default:
+		}
+		if launch != nil {
+			select {
+			case <-launch:
+			case <-ctx.Done():
+				break start
+			}
+		}
+		started++
+		if !cfg.Quiet {
+			log.Println("Starting client ", i)
+		}
+		brokerURL := assignBroker(cfg.BrokerStrategy, brokers, cfg.Pub, i)
+		if cfg.Pub {
+			c := Publisher{
+				id:           i,
+				brokerURL:    brokerURL,
+				brokerUser:   cfg.Username,
+				brokerPass:   cfg.Password,
+				ids:          ids,
+				MsgTopics:    getPublisherTopicNames(i, clients, topics),
+				TopicSelect:  cfg.TopicSelect,
+				MsgSize:      cfg.Size,
+				MsgCount:     cfg.Count,
+				MsgQoS:       byte(cfg.QoS),
+				Quiet:        cfg.Quiet,
+				Panic:        cfg.Panic,
+				TestDuration: cfg.Duration,
+				Reconnect:    reconnectPolicy,
+				Stop:         stop,
+				onMessage:    onMessage,
+			}
+			if p.azure != nil {
+				c.MsgTopics = p.azure.Topics(c.MsgTopics, true)
+				c.Credentials = azureCredentials(p.azure, true, i)
+			}
+			if p.credentials != nil {
+				c.Credentials = assignCredentials(p.credentials, cfg.CredentialsAssign, i)
+			}
+			go c.Run(resCh)
+		} else if cfg.Mode == ModeConnect {
+			c := ConnectClient{
+				id:         i,
+				brokerURL:  brokerURL,
+				brokerUser: cfg.Username,
+				brokerPass: cfg.Password,
+				ids:        ids,
+				Storm:      storm,
+				Quiet:      cfg.Quiet,
+				Panic:      cfg.Panic,
+			}
+			go c.Run(resCh)
+		} else if cfg.Mode == ModeRequest {
+			c := Requester{
+				id:             i,
+				brokerURL:      brokerURL,
+				brokerUser:     cfg.Username,
+				brokerPass:     cfg.Password,
+				ids:            ids,
+				TopicsCount:    topics,
+				MsgSize:        cfg.Size,
+				MsgQoS:         byte(cfg.QoS),
+				Quiet:          cfg.Quiet,
+				Panic:          cfg.Panic,
+				MsgCount:       cfg.Count,
+				TestDuration:   cfg.Duration,
+				RequestTimeout: cfg.RequestTimeout,
+				Stop:           stop,
+			}
+			go c.Run(resCh)
+		} else if cfg.Mode == ModeWill {
+			c := WillClient{
+				id:         i,
+				brokerURL:  willController.URL(),
+				brokerUser: cfg.Username,
+				brokerPass: cfg.Password,
+				ids:        ids,
+				Controller: willController,
+				MsgQoS:     byte(cfg.QoS),
+				KeepAlive:  cfg.WillKeepAlive,
+				Quiet:      cfg.Quiet,
+				Panic:      cfg.Panic,
+			}
+			go c.Run(resCh)
+		} else if cfg.Mode == ModeChurn {
+			c := &ChurnSubscriber{
+				id:           i,
+				brokerURL:    brokerURL,
+				brokerUser:   cfg.Username,
+				brokerPass:   cfg.Password,
+				ids:          ids,
+				TopicsCount:  topics,
+				MsgQoS:       byte(cfg.QoS),
+				Quiet:        cfg.Quiet,
+				Panic:        cfg.Panic,
+				ChurnRate:    cfg.ChurnRate,
+				CycleCount:   cfg.Count,
+				TestDuration: cfg.Duration,
+				Stop:         stop,
+			}
+			go c.Run(resCh)
+		} else if cfg.Mode == ModeSession {
+			c := SessionSubscriber{
+				id:           i,
+				brokerURL:    brokerURL,
+				brokerUser:   cfg.Username,
+				brokerPass:   cfg.Password,
+				ids:          ids,
+				ClientsCount: clients,
+				TopicsCount:  topics,
+				MsgQoS:       byte(cfg.QoS),
+				Quiet:        cfg.Quiet,
+				Panic:        cfg.Panic,
+				OfflineAfter: cfg.OfflineAfter,
+				Offline:      cfg.Offline,
+				TestDuration: cfg.Duration,
+				IdleTimeout:  cfg.IdleTimeout,
+				Stop:         stop,
+			}
+			go c.Run(resCh)
+		} else if cfg.Mode == ModeRetained {
+			c := RetainedSubscriber{
+				id:           i,
+				brokerURL:    brokerURL,
+				brokerUser:   cfg.Username,
+				brokerPass:   cfg.Password,
+				ids:          ids,
+				TopicFilter:  retainedRoot + "/#",
+				Expected:     topics,
+				MsgQoS:       byte(cfg.QoS),
+				Quiet:        cfg.Quiet,
+				Panic:        cfg.Panic,
+				TestDuration: cfg.Duration,
+				IdleTimeout:  cfg.IdleTimeout,
+				Stop:         stop,
+			}
+			go c.Run(resCh)
+		} else {
+			c := Subscriber{
+				id:           i,
+				brokerURL:    brokerURL,
+				brokerUser:   cfg.Username,
+				brokerPass:   cfg.Password,
+				ids:          ids,
+				ClientsCount: clients,
+				TopicsCount:  topics,
+				MsgSize:      cfg.Size,
+				MsgCount:     cfg.Count,
+				MsgQoS:       byte(cfg.QoS),
+				Quiet:        cfg.Quiet,
+				Panic:        cfg.Panic,
+				TestDuration: cfg.Duration,
+				IdleTimeout:  cfg.IdleTimeout,
+				GroupsCount:  cfg.Groups,
+				Reconnect:    reconnectPolicy,
+				Stop:         stop,
+				DrainTimeout: cfg.DrainTimeout,
+				onMessage:    onMessage,
+			}
+			if cfg.Groups > 0 {
+				c.Group = subscriberGroups[i%cfg.Groups]
+			}
+			if p.topology != nil {
+				c.MsgTopics = p.topology.SubscriberTopics(i)
+				c.MsgCount = p.topology.ExpectedPerSubscriber(cfg.Count)
+			}
+			if p.azure != nil {
+				c.MsgTopics = p.azure.Topics(getTopicNames(i, clients, topics), false)
+				c.Credentials = azureCredentials(p.azure, false, i)
+			}
+			if p.credentials != nil {
+				c.Credentials = assignCredentials(p.credentials, cfg.CredentialsAssign, i)
+			}
+			go c.Run(resCh)
+		}
+	}
+
+	if started == clients {
+		log.Printf("All clients have started.")
+	} else if storm != nil {
+		storm.Skip(clients - started)
+	}
+	if cfg.ReadyAddr != "" && (cfg.Sub || cfg.Mode == ModeChurn || cfg.Mode == ModeSession) {
+		srv := exposeReadyEndpoint(cfg.ReadyAddr)
+		defer srv.Close()
+	}
+
+	// collect the results, connect and will clients can not be stopped and
+	// are left unreported if they are not done by the drain timeout
+	results := make([]*RunResults, 0, clients)
+	interrupted := ctx.Done()
+	var deadline <-chan time.Time
+	if started < clients {
+		interrupted = nil
+		deadline = stopClients(stop, cfg.DrainTimeout)
+	}
+collect:
+	for len(results) < started {
+		select {
+		case res := <-resCh:
+			results = append(results, res)
+		case <-interrupted:
+			interrupted = nil
+			deadline = stopClients(stop, cfg.DrainTimeout)
+		case <-deadline:
+			break collect
+		}
+	}
+	endTime := time.Now()
+	meter.Stop()
+	reconnectPolicy.Stop()
+	if len(results) == 0 {
+		close(stopResponders)
+		if cfg.Mode == ModeRequest {
+			// the responders send their results once stopped
+			for i := 0; i < cfg.Responders; i++ {
+				<-respCh
+			}
+		}
+		if willController != nil {
+			willController.Close()
+		}
+		if cfg.Mode == ModeRetained {
+			retainedLoader.Clear()
+		}
+		return nil, ctx.Err()
+	}
+	testType := "pub"
+	if cfg.Sub {
+		testType = "sub"
+		// subtract IdleTimeout from total duration, if the last subscriber waited for it
+		if results[len(results)-1].Idle {
+			endTime = endTime.Add(-cfg.IdleTimeout)
+		}
+	}
+	if cfg.Mode != "" {
+		testType = cfg.Mode
+	}
+	totals := calculateTotalResults(cfg.RunID, cfg.CaseID, results, startTime, endTime, testType, clients, topics, cfg.Count, cfg.Size, cfg.QoS, cfg.Dop)
+	if reconnectPolicy.Enabled() {
+		totals.Reconnect = calculateReconnectResults(results, cfg.Reconnect)
+	}
+	if len(brokers) > 1 && cfg.Mode == "" {
+		totals.Strategy = cfg.BrokerStrategy
+		totals.Nodes = calculateNodeResults(results, brokers, totals.TotalRunTime)
+		for _, n := range totals.Nodes {
+			if upstream, ok := upstreams[n.Broker]; ok {
+				n.Broker = upstream
+			}
+		}
+	}
+	if cfg.Faults.Enabled() {
+		totals.Faults = calculateFaultResults(cfg.Faults, proxies)
+	}
+	if p.topology != nil {
+		totals.Topology = calculateTopologyResults(results, p.topology, testType, cfg.Count)
+	}
+	if cfg.Pub {
+		totals.TopicDistribution = calculateTopicResults(results, cfg.TopicSelect)
+	}
+	if cfg.Groups > 0 {
+		totals.Groups = calculateGroupResults(results, subscriberGroups)
+	}
+	if cfg.Mode == ModeConnect {
+		// the connections are held until released, which an interrupted test does not wait for
+		var connectPhase time.Duration
+		if deadline == nil {
+			connectPhase = storm.ConnectPhase()
+		}
+		totals.Connect = calculateConnectResults(results, storm.MaxConcurrent(), connectPhase.Seconds())
+	}
+	if cfg.Mode == ModeRequest {
+		close(stopResponders)
+		responderResults := make([]*RunResults, cfg.Responders)
+		for i := range responderResults {
+			responderResults[i] = <-respCh
+		}
+		totals.Request = calculateRequestResults(results, responderResults, totals.TotalRunTime)
+	}
+	if cfg.Mode == ModeWill {
+		totals.Will = calculateWillResults(results, willController)
+		willController.Close()
+	}
+	if cfg.Mode == ModeSession {
+		totals.Session = calculateSessionResults(results, cfg.Offline)
+	}
+	if cfg.Mode == ModeChurn {
+		totals.Churn = calculateChurnResults(results, totals.TotalRunTime)
+	}
+	if cfg.Mode == ModeRetained {
+		totals.Retained = calculateRetainedResults(results, retainedLoad, topics)
+		retainedLoader.Clear()
+	}
+
+	totals.ClientIDTakeovers = ids.Takeovers()
+	totals.Interrupted = deadline != nil
+	totals.Unreported = clients - len(results)
+	totals.Intervals = intervals
+	if !cfg.KeepSamples {
+		for _, res := range results {
+			res.MsgTimes = nil
+		}
+	}
+
+	return &JSONResults{Runs: results, Totals: totals}, nil
+}
+
+// stopClients stops the clients and returns the deadline for them to report their results.
+func stopClients(stop chan bool, drainTimeout time.Duration) <-chan time.Time {
+	log.Printf("Interrupted, stopping the clients and draining messages for up to %v.", drainTimeout)
+	close(stop)
+	return time.After(drainTimeout + reportGracePeriod)
+}
+
+// azureCredentials returns the credentials of the i-th publisher or subscriber with the azure profile.
+// The key is checked by Config.Validate, so signing can not fail here.
+func azureCredentials(azure *AzureProfile, publisher bool, i int) *Credentials {
+	id := azure.BackendID(i)
+	if azure.IsDevice(publisher) {
+		id = azure.DeviceID(i)
+	}
+	cred, _ := azure.Credentials(id)
+	return cred
+}
+
+func exposeReadyEndpoint(addr string) *http.Server {
+	log.Printf("Exposing ready endpoint on http://%v/.", addr)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", readyHandler)
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Error exposing ready endpoint: %v", err)
+		}
+	}()
+	return srv
+}
+
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Confirming ready status.")
+	w.WriteHeader(http.StatusOK)
+}
+
+func waitForSubscriber(ctx context.Context, subAddress string) error {
+	// retry for 30 sec
+	tr := rehttp.NewTransport(
+		nil,
+		rehttp.RetryAll(rehttp.RetryMaxRetries(30),
+			rehttp.RetryIsErr(func(e error) bool { return e != nil })),
+		rehttp.ConstDelay(time.Second),
+	)
+	client := &http.Client{
+		Transport: tr,
+		Timeout:   60 * time.Second, // Client timeout applies to all retries as a whole
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, subAddress, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("did not get the ready confirmation from subscriber in time: %v", err)
+	}
+	defer resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,272 @@
+package benchmark
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// ModeSession runs subscribers with persistent sessions that go offline for a while
+// and measures how the broker drains the queued messages once they reconnect.
+const ModeSession = "session"
+
+// SessionSubscriber subscribes with a persistent session, disconnects after OfflineAfter,
+// stays offline for Offline and then reconnects to receive the queued messages.
+// Queued messages are recognized by the sent time in the payload header, so publishers
+// and subscribers should run on hosts with synchronized clocks.
+type SessionSubscriber struct {
+	id           int
+	brokerURL    string
+	brokerUser   string
+	brokerPass   string
+	ids          *clientIDRegistry
+	ClientsCount int
+	TopicsCount  int
+	MsgQoS       byte
+	Quiet        bool
+	Panic        bool
+
+	// OfflineAfter is how long the subscriber stays online before disconnecting.
+	OfflineAfter time.Duration
+
+	// Offline is how long the subscriber stays disconnected.
+	Offline time.Duration
+
+	// TestDuration is the max duration of the test, IdleTimeout is the max idle
+	// time b/w incoming messages after the subscriber reconnected.
+	TestDuration time.Duration
+	IdleTimeout  time.Duration
+
+	// Stop is closed to end the test before TestDuration, nil if not used.
+	Stop chan bool
+}
+
+func (c SessionSubscriber) ClientId() string {
+	return fmt.Sprintf("session-%d", c.id)
+}
+
+func (c SessionSubscriber) BrokerUrl() string {
+	return c.brokerURL
+}
+
+func (c SessionSubscriber) BrokerUser() string {
+	return c.brokerUser
+}
+
+func (c SessionSubscriber) BrokerPass() string {
+	return c.brokerPass
+}
+
+func (c SessionSubscriber) PanicMode() bool {
+	return c.Panic
+}
+
+func (c SessionSubscriber) clientIDs() *clientIDRegistry {
+	return c.ids
+}
+
+func (c SessionSubscriber) Run(res chan *RunResults) {
+	rcvMsgs := make(chan *Message)
+	stopped := make(chan bool)
+	runResults := &RunResults{
+		ID: c.ClientId(),
+	}
+
+	onMessage := func(inner mqtt.Client, m mqtt.Message) {
+		msg := &Message{
+			Topic:     m.Topic(),
+			QoS:       m.Qos(),
+			Delivered: time.Now(),
+		}
+		if h, ok := readPayloadHeader(m.Payload()); ok {
+			msg.Sent = h.Sent
+			msg.Publisher = h.Publisher
+			msg.Seq = h.Seq
+		}
+		// paho waits for handlers when disconnecting, so they must not block once the test is over
+		select {
+		case rcvMsgs <- msg:
+		case <-stopped:
+		}
+	}
+
+	// paho can not reliably reconnect a client after Disconnect, so a new client with
+	// the same options (and client id) is used to resume the session. Messages are
+	// dispatched by the default handler, as the new client has no subscription routes.
+	opts := newClientOptions(c, nil).
+		SetCleanSession(false).
+		SetDefaultPublishHandler(onMessage)
+	client := mqtt.NewClient(opts)
+	start := time.Now()
+	if !c.connect(client, runResults) {
+		runResults.ClientRunTime = time.Since(start).Seconds()
+		res <- runResults
+		return
+	}
+
+	topics := getTopicsForSubscriber(c.id, c.ClientsCount, c.TopicsCount, c.MsgQoS)
+	token := client.SubscribeMultiple(topics, nil)
+	token.Wait()
+	if token.Error() != nil {
+		log.Printf("CLIENT %v Error subscribing to the topic %v: %v\n", c.ClientId(), topics, token.Error())
+		if c.Panic {
+			panic(token.Error())
+		}
+	}
+	if !c.Quiet {
+		log.Printf("CLIENT %v is connected to the broker %v with persistent session and topic(s) %v\n", c.ClientId(), c.BrokerUrl(), topics)
+	}
+
+	tracker := newSeqTracker()
+	offlineTimer := time.NewTimer(c.OfflineAfter)
+	testTimer := time.NewTimer(c.TestDuration)
+	idleTimer := time.NewTimer(c.IdleTimeout)
+	idleTimer.Stop()
+
+	// offlineC, reconnectC and idleC stay nil (block forever) until the subscriber
+	// disconnects / goes offline / reconnects
+	var offlineC, reconnectC, idleC <-chan time.Time
+	var reconnected time.Time
+	var lastQueued time.Time
+
+loop:
+	for {
+		select {
+		case m := <-rcvMsgs:
+			runResults.Successes++
+			if !tracker.add(m) {
+				runResults.Duplicates++
+			}
+			// messages sent before the subscriber reconnected were queued by the broker
+			if !reconnected.IsZero() && m.Sent.Before(reconnected) {
+				runResults.Queued++
+				runResults.RedeliveryTimes = append(runResults.RedeliveryTimes, float64(m.Delivered.Sub(reconnected).Milliseconds()))
+				lastQueued = m.Delivered
+			}
+			if idleC != nil {
+				idleTimer.Reset(c.IdleTimeout)
+			}
+		case <-offlineTimer.C:
+			if !c.Quiet {
+				log.Printf("CLIENT %v is going offline for %v\n", c.ClientId(), c.Offline)
+			}
+			// keep receiving messages while disconnecting, see onMessage
+			disconnected := make(chan time.Time)
+			go func() {
+				client.Disconnect(250)
+				disconnected <- time.Now()
+			}()
+			offlineC = disconnected
+		case <-offlineC:
+			offlineC = nil
+			reconnectC = time.After(c.Offline)
+		case <-reconnectC:
+			reconnectC = nil
+			client = mqtt.NewClient(opts)
+			if !c.connect(client, runResults) {
+				break loop
+			}
+			reconnected = time.Now()
+			idleTimer.Reset(c.IdleTimeout)
+			idleC = idleTimer.C
+			if !c.Quiet {
+				log.Printf("CLIENT %v is back online, draining queued messages\n", c.ClientId())
+			}
+		case <-idleC:
+			if !c.Quiet {
+				log.Printf("CLIENT %v stopping after idle time: %v\n", c.ClientId(), c.IdleTimeout)
+			}
+			runResults.Idle = true
+			break loop
+		case <-testTimer.C:
+			log.Printf("CLIENT %v test duration is over: %v\n", c.ClientId(), c.TestDuration)
+			break loop
+		case <-c.Stop:
+			break loop
+		}
+	}
+
+	close(stopped)
+
+	if !lastQueued.IsZero() {
+		runResults.QueueDrainTime = float64(lastQueued.Sub(reconnected).Milliseconds())
+	}
+	runResults.Lost = tracker.lost()
+	runResults.ClientRunTime = time.Since(start).Seconds() - c.Offline.Seconds()
+	if runResults.Idle {
+		runResults.ClientRunTime -= c.IdleTimeout.Seconds() // subtract IdleTimeout from total duration.
+	}
+
+	// drop the persistent session, so it does not pile up messages after the test
+	client.Disconnect(250)
+	cleanup := mqtt.NewClient(opts.SetCleanSession(true))
+	if token := cleanup.Connect(); token.Wait() && token.Error() == nil {
+		cleanup.Disconnect(250)
+	}
+	res <- runResults
+}
+
+// connect connects the client, returns false on error.
+func (c SessionSubscriber) connect(client mqtt.Client, runResults *RunResults) bool {
+	token := client.Connect()
+	token.Wait()
+	if token.Error() != nil {
+		log.Printf("CLIENT %v had error connecting to the broker: %v\n", c.ClientId(), token.Error())
+		if c.Panic {
+			panic(token.Error())
+		}
+		runResults.Failures++
+		return false
+	}
+	return true
+}
+
+// seqTracker tracks sequence numbers received from each publisher on each topic
+// to detect lost and duplicate messages.
+type seqTracker struct {
+	seqs map[string]map[int]bool
+}
+
+func newSeqTracker() *seqTracker {
+	return &seqTracker{
+		seqs: make(map[string]map[int]bool),
+	}
+}
+
+// add records the message, returns false if it is a duplicate.
+func (t *seqTracker) add(m *Message) bool {
+	if m.Sent.IsZero() {
+		return true // no header, can not track
+	}
+	key := fmt.Sprintf("%s/%d", m.Topic, m.Publisher)
+	received, ok := t.seqs[key]
+	if !ok {
+		received = make(map[int]bool)
+		t.seqs[key] = received
+	}
+	if received[m.Seq] {
+		return false
+	}
+	received[m.Seq] = true
+	return true
+}
+
+// lost returns the number of gaps in the received sequences.
+func (t *seqTracker) lost() int64 {
+	var lost int64
+	for _, received := range t.seqs {
+		min, max := -1, -1
+		for seq := range received {
+			if min < 0 || seq < min {
+				min = seq
+			}
+			if seq > max {
+				max = seq
+			}
+		}
+		lost += int64(max-min+1) - int64(len(received))
+	}
+	return lost
+}
@@ -0,0 +1,91 @@
+package benchmark
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeqTracker(t *testing.T) {
+	sent := time.Now()
+	msg := func(topic string, publisher, seq int) *Message {
+		return &Message{Topic: topic, Publisher: publisher, Seq: seq, Sent: sent}
+	}
+
+	tr := newSeqTracker()
+	for _, seq := range []int{0, 1, 3, 4, 7} {
+		if !tr.add(msg("a", 0, seq)) {
+			t.Fatalf("message %d reported as a duplicate", seq)
+		}
+	}
+	// the same sequence from another publisher or on another topic is not a duplicate
+	for _, m := range []*Message{msg("a", 1, 5), msg("a", 1, 6), msg("b", 0, 1)} {
+		if !tr.add(m) {
+			t.Fatalf("message %+v reported as a duplicate", m)
+		}
+	}
+	if tr.add(msg("a", 0, 3)) {
+		t.Error("duplicate message 3 not reported")
+	}
+	// messages without a header can not be tracked
+	if !tr.add(&Message{Topic: "a"}) || !tr.add(&Message{Topic: "a"}) {
+		t.Error("message without a header reported as a duplicate")
+	}
+
+	// 2, 5 and 6 are missing from a/0
+	if got := tr.lost(); got != 3 {
+		t.Errorf("lost %d, want 3", got)
+	}
+	if got := newSeqTracker().lost(); got != 0 {
+		t.Errorf("lost %d without messages, want 0", got)
+	}
+}
+
+func TestPayloadHeader(t *testing.T) {
+	h := payloadHeader{Sent: time.Unix(0, 1600000000123456789), Publisher: 7, Seq: 1 << 20}
+	payload := make([]byte, 100)
+	writePayloadHeader(payload, h)
+	got, ok := readPayloadHeader(payload)
+	if !ok || !got.Sent.Equal(h.Sent) || got.Publisher != h.Publisher || got.Seq != h.Seq {
+		t.Errorf("read header %+v, %v, want %+v", got, ok, h)
+	}
+
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{"too small", make([]byte, payloadHeaderSize-1)},
+		// a zero filled payload without the header
+		{"zero sent time", make([]byte, payloadHeaderSize)},
+	}
+	for _, tt := range tests {
+		if _, ok := readPayloadHeader(tt.payload); ok {
+			t.Errorf("%v: read a header", tt.name)
+		}
+	}
+
+	// small payloads are sent without the header
+	small := []byte("0123456789")
+	writePayloadHeader(small, h)
+	if string(small) != "0123456789" {
+		t.Errorf("header written to a payload of %d bytes", len(small))
+	}
+}
+
+func TestCalculateSessionResults(t *testing.T) {
+	results := []*RunResults{
+		{Queued: 100, Lost: 2, Duplicates: 1, QueueDrainTime: 50, RedeliveryTimes: []float64{10, 30}},
+		{Queued: 50, QueueDrainTime: 30, RedeliveryTimes: []float64{20}},
+		// nothing queued, no drain time
+		{},
+	}
+	res := calculateSessionResults(results, 10*time.Second)
+	if res.Queued != 150 || res.Lost != 2 || res.Duplicates != 1 || res.OfflineTime != 10 {
+		t.Errorf("queued %d, lost %d, duplicates %d, offline %v, want 150, 2, 1, 10", res.Queued, res.Lost, res.Duplicates, res.OfflineTime)
+	}
+	if res.DrainTimeMin != 30 || res.DrainTimeMax != 50 || res.DrainTimeMean != 40 {
+		t.Errorf("drain time min %v, max %v, mean %v, want 30, 50, 40", res.DrainTimeMin, res.DrainTimeMax, res.DrainTimeMean)
+	}
+	if res.RedeliveryTimeMean != 20 || res.RedeliveryTimeP50 != 20 || res.RedeliveryTimeMax != 30 {
+		t.Errorf("redelivery time mean %v, p50 %v, max %v, want 20, 20, 30", res.RedeliveryTimeMean, res.RedeliveryTimeP50, res.RedeliveryTimeMax)
+	}
+}
@@ -0,0 +1,225 @@
+package benchmark
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Sources of the latency samples of the significance tests.
+const (
+	SampleSourceAuto      = "auto"
+	SampleSourceSamples   = "samples"
+	SampleSourceHistogram = "histogram"
+)
+
+// Sample is a sorted set of latencies (ms), Counts are the number of occurrences of each value.
+type Sample struct {
+	Source string
+	Values []float64
+	Counts []int64
+	N      int64
+}
+
+// NewSample creates a sample of the values.
+func NewSample(values []float64) *Sample {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	s := &Sample{Source: SampleSourceSamples}
+	for _, v := range sorted {
+		if n := len(s.Values); n > 0 && s.Values[n-1] == v {
+			s.Counts[n-1]++
+		} else {
+			s.Values = append(s.Values, v)
+			s.Counts = append(s.Counts, 1)
+		}
+		s.N++
+	}
+	return s
+}
+
+// NewHistogramSample creates a sample of the histogram, the values of a bucket are tied at its midpoint.
+func NewHistogramSample(h *Histogram) *Sample {
+	s := &Sample{Source: SampleSourceHistogram}
+	for _, b := range h.Buckets {
+		s.Values = append(s.Values, math.Max(h.Min, math.Min(h.Max, b.value())))
+		s.Counts = append(s.Counts, b.Count)
+		s.N += b.Count
+	}
+	return s
+}
+
+// LatencySample returns the message latencies of the results from the source: the raw
+// samples of the clients, the histogram of the totals, or auto for the raw samples if
+// they were kept and the histogram otherwise.
+func LatencySample(res *JSONResults, source string) (*Sample, error) {
+	var values []float64
+	for _, r := range res.Runs {
+		values = append(values, r.MsgTimes...)
+	}
+	hasHistogram := res.Totals != nil && res.Totals.MsgTimeHistogram != nil && res.Totals.MsgTimeHistogram.Count > 0
+
+	switch {
+	case source == SampleSourceSamples || source == SampleSourceAuto && len(values) > 0:
+		if len(values) == 0 {
+			return nil, errors.New("no raw latency samples, run the benchmark with -samples")
+		}
+		return NewSample(values), nil
+	case source == SampleSourceHistogram || source == SampleSourceAuto:
+		if !hasHistogram {
+			return nil, errors.New("no latency samples or histogram")
+		}
+		return NewHistogramSample(res.Totals.MsgTimeHistogram), nil
+	}
+	return nil, fmt.Errorf("invalid sample source: %v", source)
+}
+
+// Median returns the median of the sample.
+func (s *Sample) Median() float64 {
+	var n int64
+	for i, c := range s.Counts {
+		n += c
+		if 2*n >= s.N {
+			return s.Values[i]
+		}
+	}
+	return 0
+}
+
+// SignificanceResults describe whether the latencies of two benchmarks differ significantly.
+type SignificanceResults struct {
+	Source          string  `json:"source"`
+	BaselineN       int64   `json:"baseline_n"`
+	CandidateN      int64   `json:"candidate_n"`
+	BaselineMedian  float64 `json:"baseline_median"`
+	CandidateMedian float64 `json:"candidate_median"`
+
+	// MannWhitneyU is the U statistic of the candidate, MannWhitneyZ its normal approximation
+	// and MannWhitneyP the two-sided p-value.
+	MannWhitneyU float64 `json:"mann_whitney_u"`
+	MannWhitneyZ float64 `json:"mann_whitney_z"`
+	MannWhitneyP float64 `json:"mann_whitney_p"`
+
+	// CliffsDelta is the effect size: the probability that a candidate latency is higher
+	// than a baseline latency minus the probability that it is lower, from -1 to 1.
+	CliffsDelta float64 `json:"cliffs_delta"`
+
+	// KSD is the largest distance between the distribution functions, KSP its asymptotic p-value.
+	KSD float64 `json:"ks_d"`
+	KSP float64 `json:"ks_p"`
+
+	Alpha       float64 `json:"alpha"`
+	Significant bool    `json:"significant"`
+}
+
+// TestSignificance runs the Mann-Whitney U and Kolmogorov-Smirnov tests on the latencies of the
+// baseline and the candidate. The difference is significant if either p-value is below alpha.
+func TestSignificance(baseline, candidate *Sample, alpha float64) (*SignificanceResults, error) {
+	if baseline.N == 0 || candidate.N == 0 {
+		return nil, errors.New("empty latency sample")
+	}
+	res := &SignificanceResults{
+		Source:          baseline.Source,
+		BaselineN:       baseline.N,
+		CandidateN:      candidate.N,
+		BaselineMedian:  baseline.Median(),
+		CandidateMedian: candidate.Median(),
+		Alpha:           alpha,
+	}
+
+	n1, n2 := float64(candidate.N), float64(baseline.N)
+	n := n1 + n2
+
+	// walk both samples in order, ties get the average rank
+	var rank, rankSum, ties, cdf1, cdf2 float64
+	i, j := 0, 0
+	for i < len(candidate.Values) || j < len(baseline.Values) {
+		var c1, c2 float64
+		switch {
+		case j == len(baseline.Values) || i < len(candidate.Values) && candidate.Values[i] < baseline.Values[j]:
+			c1 = float64(candidate.Counts[i])
+			i++
+		case i == len(candidate.Values) || baseline.Values[j] < candidate.Values[i]:
+			c2 = float64(baseline.Counts[j])
+			j++
+		default:
+			c1, c2 = float64(candidate.Counts[i]), float64(baseline.Counts[j])
+			i++
+			j++
+		}
+		t := c1 + c2
+		rankSum += c1 * (rank + (t+1)/2)
+		rank += t
+		ties += t*t*t - t
+
+		cdf1 += c1 / n1
+		cdf2 += c2 / n2
+		res.KSD = math.Max(res.KSD, math.Abs(cdf1-cdf2))
+	}
+
+	res.MannWhitneyU = rankSum - n1*(n1+1)/2
+	res.CliffsDelta = 2*res.MannWhitneyU/(n1*n2) - 1
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	res.MannWhitneyP = 1
+	if variance > 0 {
+		// continuity correction towards the mean
+		d := res.MannWhitneyU - mean
+		res.MannWhitneyZ = math.Copysign(math.Max(math.Abs(d)-0.5, 0), d) / math.Sqrt(variance)
+		res.MannWhitneyP = math.Erfc(math.Abs(res.MannWhitneyZ) / math.Sqrt2)
+	}
+
+	ne := n1 * n2 / n
+	res.KSP = kolmogorovQ((math.Sqrt(ne) + 0.12 + 0.11/math.Sqrt(ne)) * res.KSD)
+
+	res.Significant = res.MannWhitneyP < alpha || res.KSP < alpha
+	return res, nil
+}
+
+// kolmogorovQ returns the complementary distribution function of the Kolmogorov distribution.
+func kolmogorovQ(lambda float64) float64 {
+	if lambda < 0.2 {
+		return 1
+	}
+	var sum, sign float64 = 0, 1
+	for k := 1; k <= 100; k++ {
+		term := sign * 2 * math.Exp(-2*float64(k*k)*lambda*lambda)
+		sum += term
+		if math.Abs(term) < 1e-12 {
+			break
+		}
+		sign = -sign
+	}
+	return math.Max(0, math.Min(1, sum))
+}
+
+// effectSize describes the magnitude of Cliff's delta (Romano et al.).
+func effectSize(delta float64) string {
+	switch d := math.Abs(delta); {
+	case d < 0.147:
+		return "negligible"
+	case d < 0.33:
+		return "small"
+	case d < 0.474:
+		return "medium"
+	}
+	return "large"
+}
+
+// PrintSignificanceResults prints the outcome of the significance tests.
+func PrintSignificanceResults(r *SignificanceResults) {
+	fmt.Printf("========= SIGNIFICANCE =========\n")
+	fmt.Printf("Latency Source:                   %v\n", r.Source)
+	fmt.Printf("Baseline Samples:                 %d\n", r.BaselineN)
+	fmt.Printf("Candidate Samples:                %d\n", r.CandidateN)
+	fmt.Printf("Baseline Median (ms):             %.3f\n", r.BaselineMedian)
+	fmt.Printf("Candidate Median (ms):            %.3f\n", r.CandidateMedian)
+	fmt.Printf("Mann-Whitney U:                   %.1f (z %.3f)\n", r.MannWhitneyU, r.MannWhitneyZ)
+	fmt.Printf("Mann-Whitney p-value:             %.4g\n", r.MannWhitneyP)
+	fmt.Printf("Kolmogorov-Smirnov D:             %.4f\n", r.KSD)
+	fmt.Printf("Kolmogorov-Smirnov p-value:       %.4g\n", r.KSP)
+	fmt.Printf("Effect Size (Cliff's delta):      %.3f (%v)\n", r.CliffsDelta, effectSize(r.CliffsDelta))
+	fmt.Printf("%-34s%v\n", fmt.Sprintf("Significant (alpha %v):", r.Alpha), r.Significant)
+	fmt.Printf("==============================\n")
+}
@@ -0,0 +1,130 @@
+package benchmark
+
+import (
+	"testing"
+)
+
+func TestMannWhitney(t *testing.T) {
+	tests := []struct {
+		name                 string
+		candidate, baseline  []float64
+		u, z, p, cliffsDelta float64
+	}{
+		{
+			// the example of R's wilcox.test(x, y, exact = FALSE), no ties
+			name:        "no ties",
+			candidate:   []float64{0.80, 0.83, 1.89, 1.04, 1.45, 1.38, 1.91, 1.64, 0.73, 1.46},
+			baseline:    []float64{1.15, 0.88, 0.90, 0.74, 1.21},
+			u:           35,
+			z:           1.1635,
+			p:           0.2446,
+			cliffsDelta: 0.4,
+		},
+		{
+			// ties within and across the samples reduce the variance
+			name:        "ties",
+			candidate:   []float64{1, 2, 2, 3, 3, 3, 5},
+			baseline:    []float64{2, 3, 4, 4, 5, 6, 6, 7},
+			u:           10,
+			z:           -2.0567,
+			p:           0.0397,
+			cliffsDelta: -9.0 / 14,
+		},
+		{
+			name:        "small",
+			candidate:   []float64{1, 2, 3},
+			baseline:    []float64{2, 3, 4},
+			u:           2,
+			z:           -0.8989,
+			p:           0.3687,
+			cliffsDelta: -5.0 / 9,
+		},
+		{
+			name:        "identical",
+			candidate:   []float64{1, 2, 3, 4},
+			baseline:    []float64{1, 2, 3, 4},
+			u:           8,
+			z:           0,
+			p:           1,
+			cliffsDelta: 0,
+		},
+	}
+	for _, tt := range tests {
+		res, err := TestSignificance(NewSample(tt.baseline), NewSample(tt.candidate), 0.05)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if !almostEqual(res.MannWhitneyU, tt.u, 1e-9) {
+			t.Errorf("%v: U = %v, want %v", tt.name, res.MannWhitneyU, tt.u)
+		}
+		if !almostEqual(res.MannWhitneyZ, tt.z, 1e-4) {
+			t.Errorf("%v: z = %.4f, want %.4f", tt.name, res.MannWhitneyZ, tt.z)
+		}
+		if !almostEqual(res.MannWhitneyP, tt.p, 1e-4) {
+			t.Errorf("%v: p = %.4f, want %.4f", tt.name, res.MannWhitneyP, tt.p)
+		}
+		if !almostEqual(res.CliffsDelta, tt.cliffsDelta, 1e-9) {
+			t.Errorf("%v: Cliff's delta = %.4f, want %.4f", tt.name, res.CliffsDelta, tt.cliffsDelta)
+		}
+	}
+}
+
+func TestKolmogorovSmirnov(t *testing.T) {
+	tests := []struct {
+		candidate, baseline []float64
+		d                   float64
+	}{
+		{[]float64{1, 2, 3, 4}, []float64{1, 2, 3, 4}, 0},
+		{[]float64{1, 2, 3}, []float64{2, 3, 4}, 1.0 / 3},
+		{[]float64{1, 2}, []float64{3, 4, 5, 6}, 1},
+		{[]float64{1, 2, 2, 3, 3, 3, 5}, []float64{2, 3, 4, 4, 5, 6, 6, 7}, 6.0/7 - 2.0/8},
+	}
+	for _, tt := range tests {
+		res, err := TestSignificance(NewSample(tt.baseline), NewSample(tt.candidate), 0.05)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !almostEqual(res.KSD, tt.d, 1e-9) {
+			t.Errorf("KS D of %v and %v = %.4f, want %.4f", tt.candidate, tt.baseline, res.KSD, tt.d)
+		}
+	}
+}
+
+func TestKolmogorovQ(t *testing.T) {
+	// critical values of the Kolmogorov distribution
+	tests := []struct {
+		lambda, p float64
+	}{
+		{0.1, 1},
+		{0.5, 0.9639},
+		{1.0, 0.2700},
+		{1.2238, 0.10},
+		{1.3581, 0.05},
+		{1.6276, 0.01},
+		{1.9495, 0.001},
+	}
+	for _, tt := range tests {
+		if got := kolmogorovQ(tt.lambda); !almostEqual(got, tt.p, 1e-4) {
+			t.Errorf("kolmogorovQ(%v) = %.4f, want %.4f", tt.lambda, got, tt.p)
+		}
+	}
+}
+
+func TestEffectSize(t *testing.T) {
+	tests := []struct {
+		delta float64
+		want  string
+	}{
+		{0, "negligible"},
+		{-0.1, "negligible"},
+		{0.147, "small"},
+		{-0.4, "medium"},
+		{0.474, "large"},
+		{-1, "large"},
+	}
+	for _, tt := range tests {
+		if got := effectSize(tt.delta); got != tt.want {
+			t.Errorf("effectSize(%v) = %v, want %v", tt.delta, got, tt.want)
+		}
+	}
+}
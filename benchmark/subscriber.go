@@ -0,0 +1,369 @@
+package benchmark
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GaryBoone/GoStats/stats"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+type Subscriber struct {
+	id           int
+	brokerURL    string
+	brokerUser   string
+	brokerPass   string
+	ids          *clientIDRegistry
+	ClientsCount int
+	TopicsCount  int
+	MsgSize      int
+	MsgCount     int
+	MsgQoS       byte
+	Quiet        bool
+	Panic        bool
+
+	// MsgTopics are the topic filters to subscribe to. If empty, the topics
+	// are spread among clients based on ClientsCount and TopicsCount.
+	MsgTopics []string
+
+	// Group is the shared subscription group the subscriber belongs to,
+	// nil if shared subscriptions are not used.
+	Group       *SubscriberGroup
+	GroupsCount int
+
+	// TestDuration is the expected duration of the test.
+	// The test will run for *at least* the specified duration,
+	// after that if will continue process incoming messages (if any)
+	// or stop after IdleTimeout.
+	TestDuration time.Duration
+
+	// IdleTimeout is the max idle time b/w incoming messages.
+	// If idle timeout reached b/w incoming messages, the test will stop.
+	IdleTimeout time.Duration
+	testTimer   *time.Timer
+	idleTimer   *time.Timer
+
+	// endgame is true when the test duration is over and we just
+	// waiting for remaining message queue to drain.
+	endgame bool
+
+	// connected is the time when a MQTT client first connected to
+	// the broker. User for reporting results
+	connected time.Time
+
+	// Reconnect is the policy to recover from connection loss.
+	Reconnect *ReconnectPolicy
+	tracker   *ConnectionTracker
+
+	// Credentials override the default client id and username/password, if set.
+	Credentials *Credentials
+
+	// Stop is closed to end the test before TestDuration, nil if not used.
+	// The subscriber then drains the remaining messages as if the test duration
+	// was over, but for no longer than DrainTimeout.
+	Stop         chan bool
+	DrainTimeout time.Duration
+
+	onMessage func(MessageEvent)
+}
+
+func (c Subscriber) ClientId() string {
+	return fmt.Sprintf("sub-%d", c.id)
+}
+
+func (c Subscriber) BrokerUrl() string {
+	return c.brokerURL
+}
+
+func (c Subscriber) BrokerUser() string {
+	return c.brokerUser
+}
+
+func (c Subscriber) BrokerPass() string {
+	return c.brokerPass
+}
+
+func (c Subscriber) PanicMode() bool {
+	return c.Panic
+}
+
+func (c Subscriber) clientIDs() *clientIDRegistry {
+	return c.ids
+}
+
+func (c *Subscriber) connectionTracker() *ConnectionTracker {
+	return c.tracker
+}
+
+func (c *Subscriber) credentials() *Credentials {
+	return c.Credentials
+}
+
+func (c Subscriber) Run(res chan *RunResults) {
+	doneSub := make(chan bool, 1)
+	rcvMsgs := make(chan *Message)
+	stopped := make(chan bool)
+	runResults := &RunResults{
+		ID: c.ClientId(),
+	}
+
+	// groupDone stays nil (blocks forever) unless the subscriber is a member of a group.
+	var groupDone chan bool
+	if c.Group != nil {
+		runResults.Group = c.Group.Name
+		groupDone = c.Group.done
+	}
+
+	c.idleTimer = time.NewTimer(0)
+	<-c.idleTimer.C
+
+	c.testTimer = time.NewTimer(c.TestDuration)
+	c.tracker = newConnectionTracker(c.Reconnect)
+
+	c.subscribe(rcvMsgs, doneSub, stopped)
+
+	// drainC stays nil (blocks forever) until the subscriber is stopped
+	var drainC <-chan time.Time
+
+	// end-to-end latencies of messages with a payload header
+	times := make([]float64, 0, c.MsgCount)
+	for {
+		select {
+		case m := <-rcvMsgs:
+			if m.Error {
+				log.Printf("CLIENT %v ERROR receiving message: %v: at %v\n", c.ClientId(), m.Topic, m.Sent.Unix())
+				runResults.Failures++
+			} else {
+				runResults.Successes++
+				if !m.Sent.IsZero() {
+					times = append(times, float64(m.Delivered.Sub(m.Sent).Microseconds())/1000) // in milliseconds
+				}
+				if c.endgame {
+					c.idleTimer.Reset(c.IdleTimeout)
+				}
+			}
+			if c.onMessage != nil {
+				c.onMessage(c.messageEvent(m))
+			}
+		case <-doneSub:
+			// Received expected number of messages. Test is over.
+			c.finish(stopped, res, c.prepareResult(runResults, times))
+			return
+		case <-groupDone:
+			// The group received expected number of messages. Test is over.
+			if !c.Quiet {
+				log.Printf("CLIENT %v group %v is done receiving messages\n", c.ClientId(), c.Group.Name)
+			}
+			c.finish(stopped, res, c.prepareResult(runResults, times))
+			return
+		case <-c.testTimer.C:
+			// Test duration is over, start idle timer.
+			if !c.Quiet {
+				log.Printf("CLIENT %v test duration is over: %v\n", c.ClientId(), c.TestDuration)
+			}
+			c.idleTimer.Reset(c.IdleTimeout)
+			c.endgame = true
+		case <-c.Stop:
+			if !c.Quiet {
+				log.Printf("CLIENT %v is stopped, draining messages for up to %v\n", c.ClientId(), c.DrainTimeout)
+			}
+			if !c.endgame {
+				c.idleTimer.Reset(c.IdleTimeout)
+				c.endgame = true
+			}
+			c.Stop = nil
+			drainC = time.After(c.DrainTimeout)
+		case <-drainC:
+			if !c.Quiet {
+				log.Printf("CLIENT %v stopping after drain timeout: %v\n", c.ClientId(), c.DrainTimeout)
+			}
+			c.finish(stopped, res, c.prepareResult(runResults, times))
+			return
+		case <-c.idleTimer.C:
+			if !c.Quiet {
+				log.Printf("CLIENT %v stopping after idle time: %v\n", c.ClientId(), c.IdleTimeout)
+			}
+			runResults.Idle = true
+			c.finish(stopped, res, c.prepareResult(runResults, times))
+			return
+		}
+	}
+}
+
+// finish disconnects the client and sends the results. The handlers are released first,
+// as paho waits for them when disconnecting.
+func (c *Subscriber) finish(stopped chan bool, res chan *RunResults, runResults *RunResults) {
+	close(stopped)
+	c.tracker.disconnect(250)
+	res <- runResults
+}
+
+func (c *Subscriber) subscribe(rcvMsg chan *Message, doneSub, stopped chan bool) {
+	// the counter is shared by reconnected clients
+	ctr := 0
+	onConnected := func(client mqtt.Client) {
+		// keep the time of the first connection when reconnecting
+		if c.connected.IsZero() {
+			c.connected = time.Now()
+		}
+
+		onMessage := func(inner mqtt.Client, m mqtt.Message) {
+			ctr++
+			msg := &Message{
+				Topic:     m.Topic(),
+				QoS:       m.Qos(),
+				Delivered: time.Now(),
+			}
+			if h, ok := readPayloadHeader(m.Payload()); ok {
+				msg.Sent = h.Sent
+				msg.Publisher = h.Publisher
+				msg.Seq = h.Seq
+			}
+			// paho waits for handlers when disconnecting, so they must not block once the test is over
+			select {
+			case rcvMsg <- msg:
+			case <-stopped:
+				return
+			}
+
+			if c.Group != nil {
+				c.Group.add()
+				return
+			}
+
+			// the client is disconnected by Run, as disconnecting here would wait for this handler
+			if c.MsgCount > 0 && ctr == c.MsgCount {
+				if !c.Quiet {
+					log.Printf("CLIENT %v is done receiving messages\n", c.ClientId())
+				}
+				doneSub <- true
+			}
+		}
+
+		var topics map[string]byte
+		if c.Group != nil {
+			topics = getTopicsForGroup(c.Group, c.GroupsCount, c.TopicsCount, c.MsgQoS)
+		} else if len(c.MsgTopics) > 0 {
+			topics = make(map[string]byte)
+			for _, topicName := range c.MsgTopics {
+				topics[topicName] = c.MsgQoS
+			}
+		} else {
+			topics = getTopicsForSubscriber(c.id, c.ClientsCount, c.TopicsCount, c.MsgQoS)
+		}
+
+		if !c.Quiet {
+			log.Printf("CLIENT %v is connected to the broker %v and topic(s) %v\n", c.ClientId(), c.BrokerUrl(), topics)
+		}
+
+		token := client.SubscribeMultiple(topics, onMessage)
+		token.Wait()
+
+		if token.Error() != nil {
+			log.Printf("CLIENT %v Error subscribing to the topic %v: %v\n", c.ClientId(), topics, token.Error())
+			if c.Panic {
+				panic(token.Error())
+			}
+		}
+	}
+
+	connect(c, onConnected)
+}
+
+func (c Subscriber) messageEvent(m *Message) MessageEvent {
+	e := MessageEvent{
+		ClientID: c.ClientId(),
+		Topic:    m.Topic,
+		Seq:      m.Seq,
+		Sent:     m.Sent,
+		Received: m.Delivered,
+		Error:    m.Error,
+	}
+	if !m.Sent.IsZero() {
+		e.Latency = m.Delivered.Sub(m.Sent)
+	}
+	return e
+}
+
+// prepareResult calculates the end-to-end latency stats from the sent time in the payload header,
+// so publishers and subscribers should run on hosts with synchronized clocks.
+func (c Subscriber) prepareResult(runResults *RunResults, times []float64) *RunResults {
+	duration := time.Since(c.connected)
+	if runResults.Idle {
+		duration = duration - c.IdleTimeout // subtract IdleTimeout from total duration.
+	}
+	runResults.ClientRunTime = duration.Seconds()
+	runResults.MsgTimes = times
+	runResults.Broker = c.tracker.Broker()
+	if len(times) > 0 {
+		runResults.MsgTimeMin = stats.StatsMin(times)
+		runResults.MsgTimeMax = stats.StatsMax(times)
+		runResults.MsgTimeMean = stats.StatsMean(times)
+	}
+	// calculate std if sample is > 1, otherwise leave as 0 (convention)
+	if len(times) > 1 {
+		runResults.MsgTimeStd = stats.StatsSampleStandardDeviation(times)
+	}
+	runResults.ConnectionEvents = c.tracker.Events()
+	runResults.Disconnects = int64(len(runResults.ConnectionEvents))
+	return runResults
+}
+
+/// getTopicsForSubscriber calculates the topic filters to subscribe based on the number of topics
+/// and the total number of clients, see getTopicNames for details.
+func getTopicsForSubscriber(subscriberID int, subscribers int, topics int, qos byte) map[string]byte {
+	result := make(map[string]byte)
+	for _, topicName := range getTopicNames(subscriberID, subscribers, topics) {
+		result[topicName] = qos
+	}
+	return result
+}
+
+// SubscriberGroup describes a shared subscription group. All members of the group
+// subscribe to the same topic filters as $share/{group}/{filter}, so the broker
+// load-balances messages among them.
+type SubscriberGroup struct {
+	ID   int
+	Name string
+
+	// MsgCount is the expected number of messages for the whole group,
+	// 0 if the test is limited by duration only.
+	MsgCount int
+
+	received int64
+	done     chan bool
+	doneOnce sync.Once
+}
+
+// NewSubscriberGroup creates a shared subscription group with the given id.
+func NewSubscriberGroup(id int, msgCount int) *SubscriberGroup {
+	return &SubscriberGroup{
+		ID:       id,
+		Name:     fmt.Sprintf("group%d", id),
+		MsgCount: msgCount,
+		done:     make(chan bool),
+	}
+}
+
+// add counts a message received by any member of the group and
+// signals the members once the expected number of messages is reached.
+func (g *SubscriberGroup) add() {
+	n := atomic.AddInt64(&g.received, 1)
+	if g.MsgCount > 0 && n >= int64(g.MsgCount) {
+		g.doneOnce.Do(func() { close(g.done) })
+	}
+}
+
+// getTopicsForGroup calculates the shared topic filters for the members of a group.
+// Groups act as logical subscribers, so the topics are spread among groups the same
+// way getTopicsForSubscriber spreads them among clients.
+func getTopicsForGroup(group *SubscriberGroup, groups int, topics int, qos byte) map[string]byte {
+	result := make(map[string]byte)
+	for topicName := range getTopicsForSubscriber(group.ID, groups, topics, qos) {
+		result[fmt.Sprintf("$share/%s/%s", group.Name, topicName)] = qos
+	}
+	return result
+}
@@ -0,0 +1,110 @@
+package benchmark
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"time"
+)
+
+const (
+	// TopicSelectRoundRobin cycles through the client's topics in order.
+	TopicSelectRoundRobin = "roundrobin"
+	// TopicSelectRandom picks a random topic for every message.
+	TopicSelectRandom = "random"
+	// TopicSelectHash picks a topic by hashing the client id and message sequence number,
+	// so the mapping is random-looking but reproducible between runs.
+	TopicSelectHash = "hash"
+)
+
+// topicPicker returns the topic for the message with the given sequence number.
+type topicPicker func(seq int) string
+
+// getTopicNames returns the ordered list of topics for a client based on the number of topics
+// and the total number of clients. There are two cases: if clients >= topics, then each client uses
+// a single topic = [client id] % [topic count].
+// Otherwise we spread the topics among clients, for example (clients = 3, topics = 12)
+//
+//	client0: [0, 1, 2, 3]
+//	client1: [4, 5, 6, 7]
+//	client2: [8, 9, 10, 11]
+func getTopicNames(clientID int, clients int, topics int) []string {
+	if clients >= topics {
+		return []string{fmt.Sprintf("/test%d", clientID%topics)}
+	}
+
+	topicsPerClient := topics / clients
+	result := make([]string, 0, topicsPerClient)
+	topicID := clientID * topicsPerClient
+
+	for i := 0; i < topicsPerClient; i++ {
+		result = append(result, fmt.Sprintf("/test%d", topicID))
+		topicID++
+	}
+	return result
+}
+
+// getPublisherTopicNames returns the topics of a publisher like getTopicNames, but the topics
+// count need not be a multiple of the number of publishers: the first [topic count] % [clients]
+// publishers send to one topic more, for example (clients = 3, topics = 11)
+//
+//	client0: [0, 1, 2, 3]
+//	client1: [4, 5, 6, 7]
+//	client2: [8, 9, 10]
+//
+// Subscribers keep the even split of getTopicNames, so that every subscriber expects
+// the same number of messages.
+func getPublisherTopicNames(clientID int, clients int, topics int) []string {
+	extra := topics % clients
+	if clients >= topics || extra == 0 {
+		return getTopicNames(clientID, clients, topics)
+	}
+
+	topicsPerClient := topics / clients
+	topicID := clientID*topicsPerClient + extra
+	if clientID < extra {
+		topicsPerClient++
+		topicID = clientID * topicsPerClient
+	}
+
+	result := make([]string, 0, topicsPerClient)
+	for i := 0; i < topicsPerClient; i++ {
+		result = append(result, fmt.Sprintf("/test%d", topicID))
+		topicID++
+	}
+	return result
+}
+
+// isValidTopicSelect checks whether the given topic selection strategy is supported.
+func isValidTopicSelect(strategy string) bool {
+	switch strategy {
+	case TopicSelectRoundRobin, TopicSelectRandom, TopicSelectHash:
+		return true
+	}
+	return false
+}
+
+// newTopicPicker creates a topicPicker for the given strategy and the client's topics.
+func newTopicPicker(strategy string, clientID string, topics []string) topicPicker {
+	if len(topics) == 1 {
+		return func(int) string { return topics[0] }
+	}
+
+	switch strategy {
+	case TopicSelectRandom:
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+		return func(int) string {
+			return topics[rnd.Intn(len(topics))]
+		}
+	case TopicSelectHash:
+		return func(seq int) string {
+			h := fnv.New32a()
+			fmt.Fprintf(h, "%s/%d", clientID, seq)
+			return topics[h.Sum32()%uint32(len(topics))]
+		}
+	default:
+		return func(seq int) string {
+			return topics[seq%len(topics)]
+		}
+	}
+}
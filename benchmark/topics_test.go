@@ -0,0 +1,142 @@
+package benchmark
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetTopicNames(t *testing.T) {
+	tests := []struct {
+		clientID, clients, topics int
+		want                      []string
+	}{
+		{0, 1, 1, []string{"/test0"}},
+		{4, 5, 2, []string{"/test0"}},
+		{3, 5, 2, []string{"/test1"}},
+		{0, 3, 12, []string{"/test0", "/test1", "/test2", "/test3"}},
+		{2, 3, 12, []string{"/test8", "/test9", "/test10", "/test11"}},
+		{1, 2, 4, []string{"/test2", "/test3"}},
+	}
+	for _, tt := range tests {
+		if got := getTopicNames(tt.clientID, tt.clients, tt.topics); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("getTopicNames(%d, %d, %d) = %v, want %v", tt.clientID, tt.clients, tt.topics, got, tt.want)
+		}
+	}
+}
+
+func TestGetPublisherTopicNames(t *testing.T) {
+	tests := []struct {
+		clientID, clients, topics int
+		want                      []string
+	}{
+		{3, 5, 2, []string{"/test1"}},
+		// a multiple of the clients is split like the topics of subscribers
+		{2, 3, 12, []string{"/test8", "/test9", "/test10", "/test11"}},
+		{0, 3, 11, []string{"/test0", "/test1", "/test2", "/test3"}},
+		{1, 3, 11, []string{"/test4", "/test5", "/test6", "/test7"}},
+		{2, 3, 11, []string{"/test8", "/test9", "/test10"}},
+		{0, 4, 5, []string{"/test0", "/test1"}},
+		{3, 4, 5, []string{"/test4"}},
+	}
+	for _, tt := range tests {
+		if got := getPublisherTopicNames(tt.clientID, tt.clients, tt.topics); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("getPublisherTopicNames(%d, %d, %d) = %v, want %v", tt.clientID, tt.clients, tt.topics, got, tt.want)
+		}
+	}
+}
+
+func TestTopicPicker(t *testing.T) {
+	topics := []string{"/test0", "/test1", "/test2"}
+
+	roundRobin := newTopicPicker(TopicSelectRoundRobin, "c0", topics)
+	for seq, want := range []string{"/test0", "/test1", "/test2", "/test0", "/test1"} {
+		if got := roundRobin(seq); got != want {
+			t.Errorf("roundrobin(%d) = %v, want %v", seq, got, want)
+		}
+	}
+
+	// the hash picker is reproducible and depends on the client
+	hash, again, other := newTopicPicker(TopicSelectHash, "c0", topics), newTopicPicker(TopicSelectHash, "c0", topics), newTopicPicker(TopicSelectHash, "c1", topics)
+	differs := false
+	for seq := 0; seq < 100; seq++ {
+		if hash(seq) != again(seq) {
+			t.Fatalf("hash(%d) = %v, then %v", seq, hash(seq), again(seq))
+		}
+		differs = differs || hash(seq) != other(seq)
+	}
+	if !differs {
+		t.Error("hash picker of two clients picks the same topics")
+	}
+
+	for _, strategy := range []string{TopicSelectRandom, TopicSelectHash} {
+		pick := newTopicPicker(strategy, "c0", topics)
+		seen := make(map[string]int)
+		for seq := 0; seq < 3000; seq++ {
+			seen[pick(seq)]++
+		}
+		for _, topic := range topics {
+			if seen[topic] < 800 {
+				t.Errorf("%v picked %v %d times out of 3000", strategy, topic, seen[topic])
+			}
+		}
+		if len(seen) != len(topics) {
+			t.Errorf("%v picked topics %v, want only %v", strategy, seen, topics)
+		}
+	}
+
+	single := newTopicPicker(TopicSelectRandom, "c0", []string{"/test7"})
+	if got := single(5); got != "/test7" {
+		t.Errorf("picker of a single topic = %v, want /test7", got)
+	}
+}
+
+func TestIsValidTopicSelect(t *testing.T) {
+	for _, strategy := range []string{TopicSelectRoundRobin, TopicSelectRandom, TopicSelectHash} {
+		if !isValidTopicSelect(strategy) {
+			t.Errorf("isValidTopicSelect(%v) = false", strategy)
+		}
+	}
+	if isValidTopicSelect("sticky") {
+		t.Error("isValidTopicSelect(sticky) = true")
+	}
+}
+
+func TestCalculateTopicResults(t *testing.T) {
+	results := []*RunResults{
+		{MsgPerTopic: map[string]int64{"/test0": 10, "/test1": 20}},
+		{MsgPerTopic: map[string]int64{"/test1": 10, "/test2": 60}},
+		{},
+	}
+	res := calculateTopicResults(results, TopicSelectRandom)
+	want := map[string]int64{"/test0": 10, "/test1": 30, "/test2": 60}
+	if !reflect.DeepEqual(res.MsgPerTopic, want) || res.Topics != 3 {
+		t.Errorf("messages per topic %v of %d topics, want %v", res.MsgPerTopic, res.Topics, want)
+	}
+	if res.MsgPerTopicMin != 10 || res.MsgPerTopicMax != 60 || res.MsgPerTopicMean != 100.0/3 {
+		t.Errorf("min %v, max %v, mean %v, want 10, 60, 33.3", res.MsgPerTopicMin, res.MsgPerTopicMax, res.MsgPerTopicMean)
+	}
+	if empty := calculateTopicResults(nil, TopicSelectHash); empty.Topics != 0 || empty.Strategy != TopicSelectHash {
+		t.Errorf("results without messages: %+v", empty)
+	}
+}
+
+func TestPlanTopics(t *testing.T) {
+	tests := []struct {
+		pub, sub bool
+		topics   int
+		valid    bool
+	}{
+		{true, false, 11, true},
+		{true, false, 12, true},
+		// subscribers expect the same number of messages each
+		{false, true, 11, false},
+		{false, true, 12, true},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Pub, cfg.Sub, cfg.Clients, cfg.Topics = tt.pub, tt.sub, 3, tt.topics
+		if _, err := cfg.plan(); (err == nil) != tt.valid {
+			t.Errorf("pub %v, sub %v, %d topics for 3 clients: error %v", tt.pub, tt.sub, tt.topics, err)
+		}
+	}
+}
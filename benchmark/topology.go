@@ -0,0 +1,60 @@
+package benchmark
+
+import (
+	"fmt"
+)
+
+const (
+	// TopologyFanOut is one publisher and many subscribers on a single topic.
+	TopologyFanOut = "fanout"
+	// TopologyFanIn is many publishers and a single subscriber on a single topic.
+	TopologyFanIn = "fanin"
+	// TopologyPointToPoint is pairs of publishers and subscribers, each pair on its own topic.
+	TopologyPointToPoint = "p2p"
+	// TopologyMesh is many publishers, each on its own topic, and many subscribers
+	// subscribed to all topics.
+	TopologyMesh = "mesh"
+)
+
+// Topology describes a named preset of publishers, subscribers and topics.
+type Topology struct {
+	Name        string
+	Publishers  int
+	Subscribers int
+	Topics      int
+}
+
+// NewTopology creates a topology preset with the given name, where size is
+// the number of clients on the "many" side of the topology.
+func NewTopology(name string, size int) (*Topology, error) {
+	switch name {
+	case TopologyFanOut:
+		return &Topology{Name: name, Publishers: 1, Subscribers: size, Topics: 1}, nil
+	case TopologyFanIn:
+		return &Topology{Name: name, Publishers: size, Subscribers: 1, Topics: 1}, nil
+	case TopologyPointToPoint, TopologyMesh:
+		return &Topology{Name: name, Publishers: size, Subscribers: size, Topics: size}, nil
+	}
+	return nil, fmt.Errorf("unsupported topology: %v", name)
+}
+
+// SubscriberTopics returns the topic filters the subscriber with the given id subscribes to.
+func (t *Topology) SubscriberTopics(subscriberID int) []string {
+	if t.Name == TopologyMesh {
+		topics := make([]string, 0, t.Topics)
+		for i := 0; i < t.Topics; i++ {
+			topics = append(topics, fmt.Sprintf("/test%d", i))
+		}
+		return topics
+	}
+	return getTopicNames(subscriberID, t.Subscribers, t.Topics)
+}
+
+// ExpectedPerSubscriber returns the number of messages each subscriber should receive
+// when every publisher sends msgCount messages.
+func (t *Topology) ExpectedPerSubscriber(msgCount int) int {
+	// number of publishers sending to each of the topics
+	publishersPerTopic := t.Publishers / t.Topics
+	topicsPerSubscriber := len(t.SubscriberTopics(0))
+	return msgCount * publishersPerTopic * topicsPerSubscriber
+}
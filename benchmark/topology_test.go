@@ -0,0 +1,60 @@
+package benchmark
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewTopology(t *testing.T) {
+	tests := []struct {
+		name                            string
+		publishers, subscribers, topics int
+		subscriberTopics                []string
+		expected                        int
+	}{
+		// every subscriber receives all messages of the publisher
+		{TopologyFanOut, 1, 4, 1, []string{"/test0"}, 10},
+		// the subscriber receives the messages of all publishers
+		{TopologyFanIn, 4, 1, 1, []string{"/test0"}, 40},
+		{TopologyPointToPoint, 4, 4, 4, []string{"/test0"}, 10},
+		{TopologyMesh, 4, 4, 4, []string{"/test0", "/test1", "/test2", "/test3"}, 40},
+	}
+	for _, tt := range tests {
+		topology, err := NewTopology(tt.name, 4)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if topology.Publishers != tt.publishers || topology.Subscribers != tt.subscribers || topology.Topics != tt.topics {
+			t.Errorf("%v: %d publishers, %d subscribers, %d topics, want %d, %d, %d", tt.name,
+				topology.Publishers, topology.Subscribers, topology.Topics, tt.publishers, tt.subscribers, tt.topics)
+		}
+		if got := topology.SubscriberTopics(0); !reflect.DeepEqual(got, tt.subscriberTopics) {
+			t.Errorf("%v: subscriber topics %v, want %v", tt.name, got, tt.subscriberTopics)
+		}
+		if got := topology.ExpectedPerSubscriber(10); got != tt.expected {
+			t.Errorf("%v: %d expected messages per subscriber, want %d", tt.name, got, tt.expected)
+		}
+	}
+
+	if _, err := NewTopology("ring", 4); err == nil {
+		t.Error("created an unsupported topology")
+	}
+}
+
+func TestCalculateTopologyResults(t *testing.T) {
+	topology, _ := NewTopology(TopologyFanIn, 3)
+	results := []*RunResults{{Successes: 25}}
+
+	res := calculateTopologyResults(results, topology, "sub", 10)
+	if res.ExpectedPerSubscriber != 30 || res.Expected != 30 || res.Received != 25 {
+		t.Errorf("expected %d per subscriber, %d in total, received %d, want 30, 30, 25", res.ExpectedPerSubscriber, res.Expected, res.Received)
+	}
+	if res.CompleteSubscribers != 0 || res.DeliveryRatio != 25.0/30 {
+		t.Errorf("%d complete subscribers, delivery ratio %v, want 0 and 0.833", res.CompleteSubscribers, res.DeliveryRatio)
+	}
+
+	// the delivery is validated by the subscribers only
+	if res := calculateTopologyResults(results, topology, "pub", 10); res.Expected != 0 || res.Publishers != 3 {
+		t.Errorf("publisher results %+v, want the topology only", res)
+	}
+}
@@ -0,0 +1,285 @@
+package benchmark
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// ModeWill connects clients with a Last Will and Testament, kills a part of the
+	// connections abruptly and measures how the broker delivers their wills.
+	ModeWill = "will"
+
+	// WillKillReset kills connections with TCP RST, so the broker detects them right away.
+	WillKillReset = "reset"
+	// WillKillSilent drops all traffic of the connections, so the broker detects them by keepalive.
+	WillKillSilent = "silent"
+
+	// willTopicFilter matches the will topics of all clients.
+	willTopicFilter = "/will/#"
+)
+
+func isValidWillKill(kill string) bool {
+	return kill == WillKillReset || kill == WillKillSilent
+}
+
+// WillController coordinates the clients of the will benchmark: it routes them through
+// a proxy, kills a ratio of their connections once all of them are connected, and
+// watches the will topics for the wills published by the broker. The will of a client
+// carries its client id, so the wills of the killed clients can be told from the wills
+// of other clients, e.g. of another test publishing to the will topics.
+type WillController struct {
+	// KillRatio is the ratio of connections to kill, Kill is how they are killed.
+	KillRatio float64
+	Kill      string
+
+	// KillAfter is the delay b/w connecting all clients and killing the connections.
+	KillAfter time.Duration
+
+	// Timeout is the max time to wait for wills after the connections are killed.
+	Timeout time.Duration
+
+	proxy    *FaultProxy
+	watcher  mqtt.Client
+	attempts sync.WaitGroup
+	release  chan bool
+
+	mu        sync.Mutex
+	killed    int
+	killedIDs map[string]bool
+	killTime  time.Time
+	wills     map[string][]time.Time // will delivery times per client id
+	received  int                    // killed clients with a will
+	complete  chan bool
+}
+
+// NewWillController starts the proxy for the broker and subscribes the watcher to the will topics.
+func NewWillController(broker string, clients int, killRatio float64, kill string, killAfter, timeout time.Duration,
+	watcher Client, qos byte, seed int64) (*WillController, error) {
+
+	// the proxy only injects the kill, so connections are not affected otherwise
+	proxy, err := NewFaultProxy(broker, NetworkConditions{Seed: seed})
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WillController{
+		KillRatio: killRatio,
+		Kill:      kill,
+		KillAfter: killAfter,
+		Timeout:   timeout,
+		proxy:     proxy,
+		release:   make(chan bool),
+		wills:     make(map[string][]time.Time),
+		complete:  make(chan bool, 1),
+	}
+
+	w.watcher = mqtt.NewClient(newClientOptions(watcher, nil))
+	if token := w.watcher.Connect(); token.Wait() && token.Error() != nil {
+		proxy.Close()
+		return nil, token.Error()
+	}
+	if token := w.watcher.Subscribe(willTopicFilter, qos, w.onWill); token.Wait() && token.Error() != nil {
+		proxy.Close()
+		return nil, token.Error()
+	}
+
+	w.attempts.Add(clients)
+	go w.run()
+	return w, nil
+}
+
+// URL returns the broker endpoint the will clients connect to.
+func (w *WillController) URL() string {
+	return w.proxy.URL()
+}
+
+// Results returns the number of killed connections, the client ids of the killed
+// connections, the time they were killed and the will delivery times per client id.
+func (w *WillController) Results() (int, map[string]bool, time.Time, map[string][]time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.killed, w.killedIDs, w.killTime, w.wills
+}
+
+// Close disconnects the watcher and stops the proxy.
+func (w *WillController) Close() {
+	w.watcher.Disconnect(250)
+	w.proxy.Close()
+}
+
+func (w *WillController) onWill(client mqtt.Client, m mqtt.Message) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := string(m.Payload())
+	w.wills[id] = append(w.wills[id], time.Now())
+	if !w.killedIDs[id] || len(w.wills[id]) > 1 {
+		return
+	}
+	w.received++
+	if w.received >= w.killed {
+		select {
+		case w.complete <- true:
+		default:
+		}
+	}
+}
+
+func (w *WillController) run() {
+	w.attempts.Wait()
+	time.Sleep(w.KillAfter)
+
+	w.mu.Lock()
+	w.killTime = time.Now()
+	ids := w.proxy.kill(w.KillRatio, w.Kill == WillKillSilent)
+	w.killed = len(ids)
+	w.killedIDs = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		w.killedIDs[id] = true
+		if len(w.wills[id]) > 0 {
+			w.received++
+		}
+	}
+	if w.killedIDs[""] {
+		log.Printf("Killed connections without a client id, their wills can not be matched.")
+	}
+	done := w.received >= w.killed
+	w.mu.Unlock()
+	log.Printf("Killed %v connections (%v), waiting for wills.", w.killed, w.Kill)
+
+	if !done {
+		select {
+		case <-w.complete:
+		case <-time.After(w.Timeout):
+			log.Printf("Timed out waiting for wills after %v.", w.Timeout)
+		}
+	}
+	close(w.release)
+}
+
+// WillClient connects with a will and holds the connection until the will test is over.
+// Clients that were not killed disconnect cleanly, so the broker should discard their wills.
+type WillClient struct {
+	id         int
+	brokerURL  string
+	brokerUser string
+	brokerPass string
+	ids        *clientIDRegistry
+	Controller *WillController
+	MsgQoS     byte
+	KeepAlive  time.Duration
+	Quiet      bool
+	Panic      bool
+}
+
+func (c WillClient) ClientId() string {
+	return fmt.Sprintf("will-%d", c.id)
+}
+
+func (c WillClient) BrokerUrl() string {
+	return c.brokerURL
+}
+
+func (c WillClient) BrokerUser() string {
+	return c.brokerUser
+}
+
+func (c WillClient) BrokerPass() string {
+	return c.brokerPass
+}
+
+func (c WillClient) PanicMode() bool {
+	return c.Panic
+}
+
+func (c WillClient) clientIDs() *clientIDRegistry {
+	return c.ids
+}
+
+func (c WillClient) Run(res chan *RunResults) {
+	runResults := &RunResults{
+		ID: c.ClientId(),
+	}
+
+	// lost connections are expected, as the controller kills them
+	var lost int32
+	opts := newClientOptions(c, nil)
+	// the will carries the client id, so the controller can match it with the killed connection
+	opts.SetKeepAlive(c.KeepAlive).
+		SetWill(fmt.Sprintf("/will/%d", c.id), opts.ClientID, c.MsgQoS, false).
+		SetConnectionLostHandler(func(client mqtt.Client, reason error) {
+			atomic.StoreInt32(&lost, 1)
+			if !c.Quiet {
+				log.Printf("CLIENT %v lost connection to the broker: %v.\n", c.ClientId(), reason.Error())
+			}
+		})
+	client := mqtt.NewClient(opts)
+
+	start := time.Now()
+	token := client.Connect()
+	token.Wait()
+	c.Controller.attempts.Done()
+	if token.Error() != nil {
+		log.Printf("CLIENT %v had error connecting to the broker: %v\n", c.ClientId(), token.Error())
+		if c.Panic {
+			panic(token.Error())
+		}
+		runResults.Failures++
+		runResults.ClientRunTime = time.Since(start).Seconds()
+		res <- runResults
+		return
+	}
+	runResults.Successes++
+	if !c.Quiet {
+		log.Printf("CLIENT %v is connected to the broker %v with a will\n", c.ClientId(), c.BrokerUrl())
+	}
+
+	<-c.Controller.release
+	if atomic.LoadInt32(&lost) == 0 {
+		client.Disconnect(250)
+	}
+	runResults.ClientRunTime = time.Since(start).Seconds()
+	res <- runResults
+}
+
+// WillWatcher is the client subscribed to the will topics of all will clients.
+type WillWatcher struct {
+	brokerURL  string
+	brokerUser string
+	brokerPass string
+	ids        *clientIDRegistry
+	Panic      bool
+}
+
+func (c WillWatcher) ClientId() string {
+	return "will-watcher"
+}
+
+func (c WillWatcher) BrokerUrl() string {
+	return c.brokerURL
+}
+
+func (c WillWatcher) BrokerUser() string {
+	return c.brokerUser
+}
+
+func (c WillWatcher) BrokerPass() string {
+	return c.brokerPass
+}
+
+func (c WillWatcher) PanicMode() bool {
+	return c.Panic
+}
+
+func (c WillWatcher) clientIDs() *clientIDRegistry {
+	return c.ids
+}
+
+// Run is not used, the watcher is connected and driven by the WillController.
+func (c WillWatcher) Run(res chan *RunResults) {
+}
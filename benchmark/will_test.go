@@ -0,0 +1,187 @@
+package benchmark
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// connectPacket returns the CONNECT packet of the client as sent by the MQTT 3.1.1 clients.
+func connectPacket(t *testing.T, clientID string) []byte {
+	p := packets.NewControlPacket(packets.Connect).(*packets.ConnectPacket)
+	p.ProtocolName, p.ProtocolVersion = "MQTT", 4
+	p.ClientIdentifier = clientID
+	p.WillFlag, p.WillTopic, p.WillMessage = true, "/will/0", []byte(clientID)
+	p.UsernameFlag, p.Username = true, "user"
+	var b bytes.Buffer
+	if err := p.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// websocketFrame returns the data as a masked binary websocket frame after the upgrade request.
+func websocketFrame(data []byte) []byte {
+	frame := []byte("GET /mqtt HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\n\r\n")
+	frame = append(frame, 0x82, 0x80|126, byte(len(data)>>8), byte(len(data)))
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask...)
+	for i, b := range data {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+func TestReadConnectClientID(t *testing.T) {
+	connect := connectPacket(t, "mqtt-benchmark-will-1")
+	ws := websocketFrame(connect)
+	tests := []struct {
+		name      string
+		data      []byte
+		websocket bool
+		id        string
+		ok        bool
+	}{
+		{"connect", connect, false, "mqtt-benchmark-will-1", true},
+		{"partial client id", connect[:20], false, "", false},
+		{"fixed header", connect[:2], false, "", false},
+		{"empty", nil, false, "", false},
+		{"publish", []byte{0x30, 2, 0, 0}, false, "", true},
+		{"websocket", ws, true, "mqtt-benchmark-will-1", true},
+		{"websocket upgrade", ws[:40], true, "", false},
+		{"partial websocket frame", ws[:len(ws)-len(connect)+20], true, "", false},
+	}
+	for _, tt := range tests {
+		if id, ok := readConnectClientID(tt.data, tt.websocket); id != tt.id || ok != tt.ok {
+			t.Errorf("%v: client id %q, %v, want %q, %v", tt.name, id, ok, tt.id, tt.ok)
+		}
+	}
+}
+
+func TestCalculateWillResults(t *testing.T) {
+	killTime := time.Now()
+	controller := &WillController{
+		Kill:      WillKillReset,
+		killed:    4,
+		killedIDs: map[string]bool{"will-0": true, "will-1": true, "will-2": true, "will-3": true},
+		killTime:  killTime,
+		wills: map[string][]time.Time{
+			"will-0": {killTime.Add(10 * time.Millisecond)},
+			"will-1": {killTime.Add(20 * time.Millisecond), killTime.Add(25 * time.Millisecond)},
+			"will-2": {killTime.Add(30 * time.Millisecond)},
+			// a client which was not killed and a client of another test
+			"will-4":  {killTime.Add(5 * time.Millisecond)},
+			"other-0": {killTime.Add(time.Millisecond), killTime.Add(2 * time.Millisecond)},
+		},
+	}
+	results := []*RunResults{{Successes: 1}, {Successes: 1}, {Successes: 1}, {Successes: 1}, {Failures: 1}}
+
+	res := calculateWillResults(results, controller)
+	if res.Kill != WillKillReset || res.Connected != 4 || res.Killed != 4 || res.Received != 3 || res.Missing != 1 || res.Duplicates != 1 {
+		t.Errorf("will results %+v, want 4 connected and killed, 3 received, 1 missing, 1 duplicate", res)
+	}
+	if res.Ratio != 0.75 || res.Unexpected != 3 {
+		t.Errorf("ratio %v, %d unexpected wills, want 0.75 and 3", res.Ratio, res.Unexpected)
+	}
+	if res.WillTimeMin != 10 || res.WillTimeMax != 30 || res.WillTimeMean != 20 || res.WillTimeP50 != 20 {
+		t.Errorf("will time min %v, max %v, mean %v, p50 %v, want 10, 30, 20, 20", res.WillTimeMin, res.WillTimeMax, res.WillTimeMean, res.WillTimeP50)
+	}
+
+	for kill, want := range map[string]bool{WillKillReset: true, WillKillSilent: true, "close": false} {
+		if got := isValidWillKill(kill); got != want {
+			t.Errorf("isValidWillKill(%v) = %v", kill, got)
+		}
+	}
+}
+
+func TestFaultProxyKill(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(c, c)
+		}
+	}()
+
+	tests := []struct {
+		silent bool
+		ratio  float64
+		want   int
+	}{
+		{false, 0.5, 2},
+		{true, 0.5, 2},
+		{false, 0, 0},
+		{true, 1, 4},
+	}
+	for _, tt := range tests {
+		p, err := NewFaultProxy("tcp://"+l.Addr().String(), NetworkConditions{Seed: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, _ := url.Parse(p.URL())
+		for i := 0; i < 4; i++ {
+			c, err := net.Dial("tcp", u.Host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			// the client ids are read from the CONNECT packets
+			if _, err := c.Write(connectPacket(t, "will-"+string(rune('0'+i)))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			p.mu.Lock()
+			n := 0
+			for c := range p.conns {
+				if c.clientID != "" {
+					n++
+				}
+			}
+			p.mu.Unlock()
+			if n == 4 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%d of 4 connections with a client id", n)
+			}
+		}
+
+		ids := p.kill(tt.ratio, tt.silent)
+		killed := make(map[string]bool)
+		for _, id := range ids {
+			if !strings.HasPrefix(id, "will-") || len(id) != len("will-0") {
+				t.Errorf("kill(%v, silent %v): unknown client id %q", tt.ratio, tt.silent, id)
+			}
+			killed[id] = true
+		}
+		if len(ids) != tt.want || len(killed) != tt.want {
+			t.Errorf("kill(%v, silent %v) = %v, want %d distinct client ids", tt.ratio, tt.silent, ids, tt.want)
+		}
+		// silenced connections stay open, reset ones are closed and counted
+		wantResets := int64(tt.want)
+		if tt.silent {
+			wantResets = 0
+		}
+		p.mu.Lock()
+		open := len(p.conns)
+		p.mu.Unlock()
+		if p.resets != wantResets || open != 4-int(wantResets) {
+			t.Errorf("kill(%v, silent %v): %d resets, %d open, want %d resets", tt.ratio, tt.silent, p.resets, open, wantResets)
+		}
+		p.Close()
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/krylovsk/mqtt-benchmark/benchmark"
+)
+
+// compare compares the candidate results to the baseline and exits with status 1 on regression.
+func compare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	tolerances := benchmark.Tolerances{Default: benchmark.DefaultTolerance}
+	fs.Var(&tolerances, "tolerance", "Tolerated relative change as a fraction (0.05) or percentage (5%), or the tolerance of a single metric as {metric}={value}, e.g. p99=10%. Can be repeated.")
+	fs.Float64Var(&tolerances.Ratio, "ratioTolerance", benchmark.DefaultRatioTolerance, "Tolerated absolute change of the ratios, e.g. 0.001 flags a ratio dropping from 1 to 0.998. Any increase of lost messages is flagged.")
+	format := fs.String("format", "text", "Output format: text|markdown")
+	all := fs.Bool("all", false, "Show all metrics, not only the changed ones.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: mqtt-benchmark compare [flags] baseline.json candidate.json\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if tolerances.Ratio < 0 {
+		log.Fatalf("Invalid arguments: ratioTolerance should be >= 0, given: %v", tolerances.Ratio)
+		return
+	}
+
+	if *format != "text" && *format != "markdown" {
+		log.Fatalf("Invalid arguments: unsupported output format: %v", *format)
+		return
+	}
+
+	baseline, err := benchmark.LoadResults(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Error loading the baseline: %v", err)
+		return
+	}
+	candidate, err := benchmark.LoadResults(fs.Arg(1))
+	if err != nil {
+		log.Fatalf("Error loading the candidate: %v", err)
+		return
+	}
+	if baseline.Totals.TestRunType != candidate.Totals.TestRunType {
+		log.Printf("Comparing results of different test types: %v and %v", baseline.Totals.TestRunType, candidate.Totals.TestRunType)
+	}
+
+	deltas := benchmark.Compare(baseline.Totals, candidate.Totals, tolerances)
+	if err := benchmark.WriteComparison(os.Stdout, deltas, *format == "markdown", *all); err != nil {
+		log.Fatalf("Error writing the comparison: %v", err)
+		return
+	}
+	if n := benchmark.Regressions(deltas); n > 0 {
+		log.Printf("%d metrics regressed beyond the tolerance", n)
+		os.Exit(1)
+	}
+}
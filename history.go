@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/krylovsk/mqtt-benchmark/benchmark"
+)
+
+// history queries the local history of results: list the runs, show the results of a run,
+// or the trend of a metric across runs.
+func history(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: mqtt-benchmark history list [flags]\n")
+		fmt.Fprintf(os.Stderr, "       mqtt-benchmark history show [flags] [id]\n")
+		fmt.Fprintf(os.Stderr, "       mqtt-benchmark history trend [flags] -metric {metric}\n")
+	}
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+	command := args[0]
+	switch command {
+	case "list", "show", "trend":
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("history "+command, flag.ExitOnError)
+	db := fs.String("db", benchmark.DefaultHistoryPath(), "Path of the history")
+	var filter benchmark.HistoryFilter
+	fs.StringVar(&filter.RunID, "runId", "", "Only the runs with the test run id")
+	fs.StringVar(&filter.CaseID, "caseId", "", "Only the runs of the test case id")
+	fs.StringVar(&filter.Instance, "instance", "", "Only the runs of the test instance (hostname)")
+	limit := fs.Int("limit", 0, "Only the last N runs, all if 0")
+	metric := fs.String("metric", "", "Metric of the trend, e.g. total_msgs_per_sec, p99 or request.rtt_p99")
+	format := fs.String("format", "text", "Output format of show: text|json")
+	fs.Usage = func() {
+		usage()
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+	// the id of show may be followed by more flags
+	var id string
+	if command == "show" && fs.NArg() > 0 {
+		id = fs.Arg(0)
+		fs.Parse(fs.Args()[1:])
+	}
+	if fs.NArg() > 0 {
+		log.Fatalf("Invalid arguments: unexpected arguments: %v", strings.Join(fs.Args(), " "))
+		return
+	}
+
+	if *limit < 0 {
+		log.Fatalf("Invalid arguments: limit should be >= 0, given: %v", *limit)
+		return
+	}
+	h := &benchmark.History{Path: *db}
+
+	var entries []*benchmark.HistoryEntry
+	var err error
+	if id != "" {
+		n, perr := strconv.Atoi(id)
+		if perr != nil {
+			log.Fatalf("Invalid arguments: invalid history id: %v", id)
+			return
+		}
+		var e *benchmark.HistoryEntry
+		if e, err = h.Entry(n); err == nil {
+			entries = []*benchmark.HistoryEntry{e}
+		}
+	} else {
+		entries, err = h.Entries(filter)
+	}
+	if err != nil {
+		log.Fatalf("Error reading the history: %v", err)
+		return
+	}
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[len(entries)-*limit:]
+	}
+
+	switch command {
+	case "list":
+		err = benchmark.WriteHistory(os.Stdout, entries)
+	case "show":
+		if len(entries) == 0 {
+			log.Fatalf("No runs in the history %v", *db)
+			return
+		}
+		// the latest of the matching runs
+		e := entries[len(entries)-1]
+		if *format == "json" {
+			err = writeJSON(os.Stdout, e.Totals)
+		} else {
+			fmt.Printf("History Entry:                    %d (recorded %v)\n", e.ID, e.Recorded.Local().Format("2006-01-02 15:04:05"))
+			benchmark.PrintResults(nil, e.Totals)
+		}
+	case "trend":
+		if *metric == "" {
+			log.Fatalf("Invalid arguments: a metric is required for the trend")
+			return
+		}
+		var points []*benchmark.TrendPoint
+		if points, err = benchmark.Trend(entries, *metric); err != nil {
+			log.Fatalf("Invalid arguments: %v", err)
+			return
+		}
+		err = benchmark.WriteTrend(os.Stdout, *metric, points)
+	}
+	if err != nil {
+		log.Fatalf("Error writing the history: %v", err)
+	}
+}
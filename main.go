@@ -1,168 +1,328 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
 	"runtime"
+	"strings"
+	"syscall"
 	"time"
 
-	rehttp "github.com/PuerkitoBio/rehttp"
+	"github.com/krylovsk/mqtt-benchmark/benchmark"
 )
 
+// exitInterrupted is the exit code of a test stopped with SIGINT/SIGTERM, as shells use for SIGINT.
+const exitInterrupted = 130
+
+// stringList is a flag, which may be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "compare":
+			compare(os.Args[2:])
+			return
+		case "significance":
+			significance(os.Args[2:])
+			return
+		case "report":
+			report(os.Args[2:])
+			return
+		case "history":
+			history(os.Args[2:])
+			return
+		case "merge":
+			merge(os.Args[2:])
+			return
+		}
+	}
+
+	cfg := benchmark.DefaultConfig()
+	var asserts stringList
+	var junit string
+	var format string
+	var repeat int
+	var pause time.Duration
+	var export string
+	var exportBuffer int
+	var exportDrop bool
+	var record bool
+	var historyDB string
 
-	var (
-		pub         = flag.Bool("pub", false, "Indicates to initialize te test client as a publisher")
-		sub         = flag.Bool("sub", false, "Indicates to initialize the test client as a subscriber")
-		broker      = flag.String("broker", "tcp://localhost:1883", "MQTT broker endpoint as scheme://host:port")
-		topics      = flag.Int("topics", 1, "Number of topics to use")
-		username    = flag.String("username", "", "MQTT username (empty if auth disabled)")
-		password    = flag.String("password", "", "MQTT password (empty if auth disabled)")
-		qos         = flag.Int("qos", 1, "QoS for published messages")
-		size        = flag.Int("size", 100, "Size of the messages payload (bytes)")
-		count       = flag.Int("count", 0, "Number of messages to send or receive per client. If not specifier - run for '-duration' instead.")
-		duration    = flag.Duration("duration", 60*time.Minute, "Maximum duration of the test.")
-		clients     = flag.Int("clients", 10, "Number of clients to start")
-		quiet       = flag.Bool("quiet", false, "Suppress logs while running")
-		dop         = flag.Int("dop", 1, "Max number of threads")
-		runID       = flag.String("runId", "", "Test Run Id, used for reporting results")
-		caseID      = flag.String("caseId", "", "Test Case Id in the current test run, used for reporting results")
-		waitFor     = flag.String("waitFor", "", "Address of a subscriber tool to wait for, before starting the test.")
-		panic       = flag.Bool("panic", false, "If specified, the tool will panic on any connection/protocol error.")
-		idleTimeout = flag.Duration("idletimeout", 10*time.Second, "Max idle time b/w incoming messages.")
-	)
+	flag.BoolVar(&cfg.Pub, "pub", cfg.Pub, "Indicates to initialize te test client as a publisher")
+	flag.BoolVar(&cfg.Sub, "sub", cfg.Sub, "Indicates to initialize the test client as a subscriber")
+	flag.StringVar(&cfg.Broker, "broker", cfg.Broker, "MQTT broker endpoint as scheme://host:port, or a comma separated list of cluster nodes")
+	flag.StringVar(&cfg.BrokerStrategy, "brokerStrategy", cfg.BrokerStrategy, "How clients are assigned to multiple brokers: roundrobin|split (publishers to the first node, subscribers to the second)|failover (first available node in order)")
+	flag.IntVar(&cfg.Topics, "topics", cfg.Topics, "Number of topics to use")
+	flag.StringVar(&cfg.Username, "username", cfg.Username, "MQTT username (empty if auth disabled)")
+	flag.StringVar(&cfg.Password, "password", cfg.Password, "MQTT password (empty if auth disabled)")
+	flag.IntVar(&cfg.QoS, "qos", cfg.QoS, "QoS for published messages")
+	flag.IntVar(&cfg.Size, "size", cfg.Size, "Size of the messages payload (bytes)")
+	flag.IntVar(&cfg.Count, "count", cfg.Count, "Number of messages to send or receive per client. If not specifier - run for '-duration' instead.")
+	flag.DurationVar(&cfg.Duration, "duration", cfg.Duration, "Maximum duration of the test.")
+	flag.IntVar(&cfg.Clients, "clients", cfg.Clients, "Number of clients to start")
+	flag.BoolVar(&cfg.Quiet, "quiet", cfg.Quiet, "Suppress logs while running")
+	flag.IntVar(&cfg.Dop, "dop", cfg.Dop, "Max number of threads")
+	flag.StringVar(&cfg.RunID, "runId", cfg.RunID, "Test Run Id, used for reporting results")
+	flag.StringVar(&cfg.CaseID, "caseId", cfg.CaseID, "Test Case Id in the current test run, used for reporting results")
+	flag.StringVar(&cfg.WaitFor, "waitFor", cfg.WaitFor, "Address of a subscriber tool to wait for, before starting the test.")
+	flag.BoolVar(&cfg.Panic, "panic", cfg.Panic, "If specified, the tool will panic on any connection/protocol error.")
+	flag.DurationVar(&cfg.IdleTimeout, "idletimeout", cfg.IdleTimeout, "Max idle time b/w incoming messages.")
+	flag.DurationVar(&cfg.DrainTimeout, "drainTimeout", cfg.DrainTimeout, "Max time to drain the remaining messages and collect the results when interrupted with SIGINT/SIGTERM.")
+	flag.StringVar(&cfg.TopicSelect, "topicSelect", cfg.TopicSelect, "How publishers with multiple topics choose a topic for each message: roundrobin|random|hash")
+	flag.StringVar(&cfg.Topology, "topology", cfg.Topology, "Topology preset: fanout|fanin|p2p|mesh. Overrides '-topics', '-clients' is the number of clients on the 'many' side.")
+	flag.StringVar(&cfg.Mode, "mode", cfg.Mode, "Benchmark mode to run instead of pub/sub: retained (load '-topics' retained messages, then measure '-clients' subscribers receiving them) | connect (open '-clients' connections without publishing) | churn (subscribe/unsubscribe '-topics' filters while traffic flows) | session (subscribers with persistent sessions go offline while publishers keep sending) | will (connect '-clients' with a will, kill a part of the connections and measure will delivery) | request ('-clients' requesters send requests to '-responders' over '-topics' and measure round-trip time)")
+	flag.DurationVar(&cfg.OfflineAfter, "offlineAfter", cfg.OfflineAfter, "How long session subscribers stay online before disconnecting in session mode.")
+	flag.DurationVar(&cfg.Offline, "offline", cfg.Offline, "How long session subscribers stay offline in session mode.")
+	flag.StringVar(&cfg.Reconnect, "reconnect", cfg.Reconnect, "Reconnect policy of pub/sub clients on connection loss: off|immediate|backoff")
+	flag.DurationVar(&cfg.ReconnectMaxInterval, "reconnectMaxInterval", cfg.ReconnectMaxInterval, "Max interval b/w reconnect attempts of the backoff policy.")
+	flag.Float64Var(&cfg.ChurnRate, "churnRate", cfg.ChurnRate, "Number of unsubscribe/subscribe cycles per second per client in churn mode.")
+	flag.Float64Var(&cfg.ConnectRate, "connectRate", cfg.ConnectRate, "Target rate (conn/sec) of opening connections in connect mode, 0 for as fast as possible.")
+	flag.DurationVar(&cfg.ConnectHold, "connectHold", cfg.ConnectHold, "How long to hold connections open after all connections were attempted in connect mode.")
+	flag.BoolVar(&cfg.ConnectClose, "connectClose", cfg.ConnectClose, "Close each connection right after CONNACK in connect mode.")
+	flag.DurationVar(&cfg.Faults.Latency, "faultLatency", cfg.Faults.Latency, "Latency injected by the fault proxy b/w clients and brokers, in each direction.")
+	flag.DurationVar(&cfg.Faults.Jitter, "faultJitter", cfg.Faults.Jitter, "Max random deviation (+/-) from the injected latency.")
+	flag.Int64Var(&cfg.Faults.Bandwidth, "faultBandwidth", cfg.Faults.Bandwidth, "Bandwidth cap (bytes/sec) of each proxied connection in each direction, 0 for unlimited.")
+	flag.Float64Var(&cfg.Faults.DropRate, "faultDrop", cfg.Faults.DropRate, "Probability of dropping a chunk of data, which is then delivered after a retransmission timeout.")
+	flag.DurationVar(&cfg.Faults.ResetInterval, "faultResetInterval", cfg.Faults.ResetInterval, "Interval of resetting random proxied connections with TCP RST, 0 to disable.")
+	flag.Float64Var(&cfg.Faults.ResetRatio, "faultResetRatio", cfg.Faults.ResetRatio, "Probability of resetting each proxied connection every reset interval.")
+	flag.Int64Var(&cfg.Faults.Seed, "faultSeed", cfg.Faults.Seed, "Seed of the fault proxy random generator, so injected faults are reproducible.")
+	flag.StringVar(&cfg.WillKill, "willKill", cfg.WillKill, "How connections are killed in will mode: reset (TCP RST) | silent (drop all traffic, detected by keepalive)")
+	flag.Float64Var(&cfg.WillKillRatio, "willKillRatio", cfg.WillKillRatio, "Ratio of connections to kill in will mode.")
+	flag.DurationVar(&cfg.WillKillAfter, "willKillAfter", cfg.WillKillAfter, "Delay b/w connecting all clients and killing the connections in will mode.")
+	flag.DurationVar(&cfg.WillKeepAlive, "willKeepalive", cfg.WillKeepAlive, "Keepalive interval of the clients in will mode.")
+	flag.IntVar(&cfg.Responders, "responders", cfg.Responders, "Number of responders in request mode, each request topic is served by a single responder.")
+	flag.DurationVar(&cfg.RequestTimeout, "requestTimeout", cfg.RequestTimeout, "Max time to wait for a response in request mode.")
+	flag.StringVar(&cfg.Profile, "profile", cfg.Profile, "Device profile of pub/sub clients: azure (IoT Hub devices with SAS tokens and device topics)")
+	flag.StringVar(&cfg.Azure.Hub, "azureHub", cfg.Azure.Hub, "IoT Hub host name for the azure profile, e.g. myhub.azure-devices.net")
+	flag.StringVar(&cfg.Azure.Key, "azureKey", cfg.Azure.Key, "Base64 encoded device key for the azure profile, shared by all devices")
+	flag.BoolVar(&cfg.Azure.GroupKey, "azureGroupKey", cfg.Azure.GroupKey, "Treat '-azureKey' as a group enrollment key and derive a key per device.")
+	flag.StringVar(&cfg.Azure.DevicePrefix, "azurePrefix", cfg.Azure.DevicePrefix, "Device id prefix for the azure profile, topic k belongs to device {prefix}{k}.")
+	flag.StringVar(&cfg.Azure.Direction, "azureDirection", cfg.Azure.Direction, "Message direction for the azure profile: d2c (publishers are devices) | c2d (subscribers are devices)")
+	flag.DurationVar(&cfg.Azure.TokenTTL, "azureTokenTTL", cfg.Azure.TokenTTL, "Lifetime of the SAS tokens for the azure profile.")
+	flag.StringVar(&cfg.Credentials, "credentials", cfg.Credentials, "Path of a CSV (with header) or JSON file with per-client credentials: username, password, client_id, cert, key, ca")
+	flag.StringVar(&cfg.CredentialsAssign, "credentialsAssign", cfg.CredentialsAssign, "How credential sets are assigned to pub/sub clients: roundrobin|onetoone")
+	flag.StringVar(&cfg.ClientID, "clientId", cfg.ClientID, "Client id template with placeholders {role}, {index}, {id}, {runId}, {host} and {time}, default "+benchmark.DefaultClientIDTemplate)
+	flag.BoolVar(&cfg.StableIDs, "stableIds", cfg.StableIDs, "Keep client ids stable across runs, default template "+benchmark.StableClientIDTemplate)
+	flag.DurationVar(&cfg.Interval, "interval", cfg.Interval, "Record the throughput and latencies of every interval with the results, e.g. 1s for the charts of the report")
+	flag.BoolVar(&record, "record", false, "Record the total results in the local history, see mqtt-benchmark history")
+	flag.StringVar(&historyDB, "historyDb", benchmark.DefaultHistoryPath(), "Path of the local history")
+	flag.StringVar(&export, "export", "", "Stream every message event to a file: .jsonl or .csv, gzip compressed with .gz, e.g. messages.csv.gz")
+	flag.IntVar(&exportBuffer, "exportBuffer", benchmark.DefaultExportBuffer, "Number of message events buffered for the export, the clients wait for the export while the buffer is full")
+	flag.BoolVar(&exportDrop, "exportDrop", false, "Drop message events while the export buffer is full instead of slowing down the clients, the benchmark fails if any event was dropped")
+	flag.BoolVar(&cfg.KeepSamples, "samples", cfg.KeepSamples, "Include the latencies of all messages in the JSON results, e.g. for the significance tests")
+	flag.IntVar(&cfg.Groups, "groups", cfg.Groups, "Number of shared subscription groups ($share/{group}/{filter}) to spread subscribers into, 0 to disable.")
+	flag.Var(&asserts, "assert", "Assertion on the total results as {metric}{op}{value}, e.g. ratio>=0.999, p99<50ms or total_msgs_per_sec>10000. Can be repeated, the tool exits with status 1 if any assertion fails.")
+	flag.StringVar(&junit, "junit", "", "Path of a JUnit XML report with the outcome of the assertions.")
+	flag.StringVar(&format, "format", "text", "Output format: text|json")
+	flag.IntVar(&repeat, "repeat", 1, "Number of times to run the test, the key metrics are summarized across runs.")
+	flag.DurationVar(&pause, "repeatPause", 5*time.Second, "Pause b/w repeated runs.")
 
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: mqtt-benchmark [flags]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       mqtt-benchmark compare [flags] baseline.json candidate.json\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       mqtt-benchmark significance [flags] baseline.json candidate.json\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       mqtt-benchmark report [flags] results.json\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       mqtt-benchmark history list|show|trend [flags]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       mqtt-benchmark merge [flags] results.json results.json...\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
-	if !(*pub != *sub) {
-		log.Fatalf("Invalid arguments: must specify either pub or sub mode")
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid arguments: %v", err)
 		return
 	}
 
-	if *clients < 1 {
-		log.Fatalf("Invalid arguments: number of clients should be > 1, given: %v", *clients)
+	if format != "text" && format != "json" {
+		log.Fatalf("Invalid arguments: unsupported output format: %v", format)
 		return
 	}
 
-	if *count < 0 {
-		log.Fatalf("Invalid arguments: messages count should be >= 0, given: %v", *count)
+	if repeat < 1 {
+		log.Fatalf("Invalid arguments: number of runs should be >= 1, given: %v", repeat)
 		return
 	}
 
-	if *topics < 1 {
-		log.Fatalf("Invalid arguments: topics count should be > 1 and <= number of clients, given: %v", *topics)
-		return
+	assertions := make([]*benchmark.Assertion, len(asserts))
+	for i, expr := range asserts {
+		a, err := benchmark.ParseAssertion(expr)
+		if err != nil {
+			log.Fatalf("Invalid arguments: %v", err)
+			return
+		}
+		assertions[i] = a
 	}
 
-	if *pub && *topics > *clients {
-		log.Fatalf("Invalid arguments: topics count should not be greater than the number of clients, given: %v", *topics)
-		return
+	var exporter *benchmark.MessageExporter
+	if export != "" {
+		var err error
+		if exporter, err = benchmark.NewMessageExporter(export, exportBuffer); err != nil {
+			log.Fatalf("Invalid arguments: %v", err)
+			return
+		}
+		exporter.Drop = exportDrop
 	}
 
-	if *sub && *topics > *clients && *topics%(*clients) > 0 {
-		log.Fatalf("Invalid arguments: number of clients should be submultiple of or greater than the topics count, given: %v", *topics%(*clients))
-		return
-	}
+	runtime.GOMAXPROCS(cfg.Dop)
 
-	if *pub && *waitFor != "" {
-		log.Printf("Waiting for subscriber at %v to start.", *waitFor)
-		waitForSubscriber(*waitFor)
-	}
-
-	runtime.GOMAXPROCS(*dop)
-
-	resCh := make(chan *RunResults)
-	startTime := time.Now()
-	for i := 0; i < *clients; i++ {
-		if !*quiet {
-			log.Println("Starting client ", i)
-		}
-		if *pub {
-			c := Publisher{
-				id:           i,
-				brokerURL:    *broker,
-				brokerUser:   *username,
-				brokerPass:   *password,
-				MsgTopic:     fmt.Sprintf("/test%d", i%(*topics)),
-				MsgSize:      *size,
-				MsgCount:     *count,
-				MsgQoS:       byte(*qos),
-				Quiet:        *quiet,
-				Panic:        *panic,
-				TestDuration: *duration,
+	ctx := interruptible()
+	var runs []*benchmark.JSONResults
+	var reports []benchmark.AssertionReport
+	failed, checked := 0, 0
+	for i := 0; i < repeat; i++ {
+		if i > 0 {
+			log.Printf("Starting run %d of %d in %v.", i+1, repeat, pause)
+			select {
+			case <-time.After(pause):
+			case <-ctx.Done():
 			}
-			go c.Run(resCh)
-		} else {
-			c := Subscriber{
-				id:           i,
-				brokerURL:    *broker,
-				brokerUser:   *username,
-				brokerPass:   *password,
-				ClientsCount: *clients,
-				TopicsCount:  *topics,
-				MsgSize:      *size,
-				MsgCount:     *count,
-				MsgQoS:       byte(*qos),
-				Quiet:        *quiet,
-				Panic:        *panic,
-				TestDuration: *duration,
-				IdleTimeout:  *idleTimeout,
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		runner := benchmark.NewRunner(cfg)
+		if exporter != nil {
+			exporter.SetRun(i + 1)
+			runner.Hooks.OnMessage = exporter.Add
+		}
+		res, err := runner.Run(ctx)
+		if err != nil {
+			if len(runs) > 0 && ctx.Err() != nil {
+				break
 			}
-			go c.Run(resCh)
+			log.Fatalf("Error running the benchmark: %v", err)
+			return
+		}
+		runs = append(runs, res)
+
+		// print stats
+		if format == "text" {
+			benchmark.PrintResults(res.Runs, res.Totals)
+		}
+		benchmark.PublishResults(res.Runs, res.Totals)
+		if record {
+			h := &benchmark.History{Path: historyDB}
+			if err := h.Add(res.Totals); err != nil {
+				log.Printf("Error recording the results in the history: %v", err)
+			}
+		}
+
+		if len(assertions) > 0 {
+			results := benchmark.CheckAssertions(assertions, res.Totals)
+			if format == "text" {
+				benchmark.PrintAssertions(results)
+			}
+			reports = append(reports, benchmark.AssertionReport{Totals: res.Totals, Results: results})
+			failed += benchmark.AssertionsFailed(results)
+			checked += len(results)
+		}
+		if res.Totals.Interrupted {
+			break
 		}
 	}
 
-	log.Printf("All clients have started.")
-	if *sub {
-		go exposeReadyEndpoint()
+	exportFailed := false
+	if exporter != nil {
+		if err := exporter.Close(); err != nil {
+			log.Printf("Error exporting the messages: %v", err)
+			exportFailed = true
+		}
+		log.Printf("Exported %d message events to %v", exporter.Written(), export)
+		if n := exporter.Dropped(); n > 0 {
+			log.Printf("Dropped %d message events, the export is incomplete, increase -exportBuffer to keep them", n)
+			exportFailed = true
+		}
 	}
 
-	// collect the results
-	results := make([]*RunResults, *clients)
-	for i := 0; i < *clients; i++ {
-		results[i] = <-resCh
+	var summary *benchmark.RepeatResults
+	if repeat > 1 {
+		totals := make([]*benchmark.TotalResults, len(runs))
+		for i, res := range runs {
+			totals[i] = res.Totals
+		}
+		summary = benchmark.CalculateRepeatResults(totals)
 	}
-	endTime := time.Now()
-	testType := "pub"
-	if *sub {
-		testType = "sub"
-		endTime = endTime.Add(-*idleTimeout) // subtract IdleTimeout from total duration for subscribers.
+	if format == "json" {
+		var err error
+		if summary != nil {
+			err = writeJSON(os.Stdout, &benchmark.RepeatedJSONResults{Results: runs, Repeat: summary})
+		} else {
+			err = benchmark.WriteJSONResults(os.Stdout, runs[0])
+		}
+		if err != nil {
+			log.Printf("Error writing the results: %v", err)
+		}
+	} else if summary != nil {
+		benchmark.PrintRepeatResults(summary)
 	}
-	totals := calculateTotalResults(*runID, *caseID, results, startTime, endTime, testType, *clients, *topics, *count, *size, *qos, *dop)
 
-	// print stats
-	printResults(results, totals)
-	publishResults(results, totals)
-}
-
-func exposeReadyEndpoint() {
-	log.Printf("Exposing ready endpoint on http://:8080/.")
-	http.HandleFunc("/", readyHandler)
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	if junit != "" && len(reports) > 0 {
+		if err := writeJUnit(junit, reports); err != nil {
+			log.Printf("Error writing the JUnit report: %v", err)
+		}
+	}
+	if failed > 0 {
+		log.Printf("%d of %d assertions failed", failed, checked)
+	}
+	if runs[len(runs)-1].Totals.Interrupted {
+		os.Exit(exitInterrupted)
+	}
+	if failed > 0 || exportFailed {
+		os.Exit(1)
+	}
 }
 
-func readyHandler(w http.ResponseWriter, r *http.Request) {
-	log.Printf("Confirming ready status.")
-	w.WriteHeader(http.StatusOK)
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
 }
 
-func waitForSubscriber(subAddress string) {
-	// retry for 30 sec
-	tr := rehttp.NewTransport(
-		nil,
-		rehttp.RetryAll(rehttp.RetryMaxRetries(30),
-			rehttp.RetryIsErr(func(e error) bool { return e != nil })),
-		rehttp.ConstDelay(time.Second),
-	)
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   60 * time.Second, // Client timeout applies to all retries as a whole
-	}
-	resp, err := client.Get(subAddress)
+func writeJUnit(path string, reports []benchmark.AssertionReport) error {
+	f, err := os.Create(path)
 	if err != nil {
-		log.Fatalf("Did not get the ready confirmation from subscriber in time. Err: %v", err)
-		panic(err)
+		return err
+	}
+	if err := benchmark.WriteJUnit(f, reports...); err != nil {
+		f.Close()
+		return err
 	}
-	defer resp.Body.Close()
+	return f.Close()
+}
+
+// interruptible returns a context, which is cancelled on the first SIGINT/SIGTERM
+// to stop the test gracefully. The second signal exits immediately.
+func interruptible() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("Received %v, stopping the test. Send it again to exit immediately.", sig)
+		cancel()
+		sig = <-signals
+		log.Printf("Received %v, exiting.", sig)
+		os.Exit(exitInterrupted)
+	}()
+	return ctx
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/krylovsk/mqtt-benchmark/benchmark"
+)
+
+// merge combines the results of several instances of the same test into aggregate results.
+func merge(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := fs.String("output", "", "Path of the merged results, stdout if not set")
+	format := fs.String("format", "json", "Output format: json|text")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: mqtt-benchmark merge [flags] results.json results.json...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if *format != "text" && *format != "json" {
+		log.Fatalf("Invalid arguments: unsupported output format: %v", *format)
+		return
+	}
+
+	inputs := make([]*benchmark.JSONResults, fs.NArg())
+	for i, path := range fs.Args() {
+		res, err := benchmark.LoadResults(path)
+		if err != nil {
+			log.Fatalf("Error loading the results: %v", err)
+			return
+		}
+		if i > 0 && res.Totals.TestRunID != inputs[0].Totals.TestRunID {
+			log.Printf("Merging results of different test runs: %v and %v", inputs[0].Totals.TestRunID, res.Totals.TestRunID)
+		}
+		inputs[i] = res
+	}
+
+	merged, err := benchmark.MergeResults(inputs)
+	if err != nil {
+		log.Fatalf("Error merging the results: %v", err)
+		return
+	}
+
+	err = writeOutput(*output, func(w io.Writer) error {
+		if *format == "text" {
+			benchmark.FprintResults(w, merged.Runs, merged.Totals)
+			return nil
+		}
+		return benchmark.WriteJSONResults(w, merged)
+	})
+	if err != nil {
+		log.Fatalf("Error writing the merged results: %v", err)
+		return
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/krylovsk/mqtt-benchmark/benchmark"
+)
+
+// report writes the results as a self-contained HTML report.
+func report(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	output := fs.String("output", "", "Path of the HTML report, stdout if not set")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: mqtt-benchmark report [flags] results.json\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	res, err := benchmark.LoadResults(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Error loading the results: %v", err)
+		return
+	}
+
+	if err := writeOutput(*output, func(w io.Writer) error { return benchmark.WriteReport(w, res) }); err != nil {
+		log.Fatalf("Error writing the report: %v", err)
+		return
+	}
+}
+
+// writeOutput writes to the file at the path, or to stdout if the path is empty.
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/krylovsk/mqtt-benchmark/benchmark"
+)
+
+// significance tests whether the latencies of the candidate differ significantly from the baseline.
+func significance(args []string) {
+	fs := flag.NewFlagSet("significance", flag.ExitOnError)
+	alpha := fs.Float64("alpha", 0.05, "Significance level of the tests")
+	source := fs.String("source", benchmark.SampleSourceAuto, "Latencies to test: auto|samples|histogram, auto uses the raw samples if both results have them (-samples), the histograms otherwise")
+	format := fs.String("format", "text", "Output format: text|json")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: mqtt-benchmark significance [flags] baseline.json candidate.json\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if *alpha <= 0 || *alpha >= 1 {
+		log.Fatalf("Invalid arguments: alpha should be between 0 and 1, got %v", *alpha)
+		return
+	}
+	if *format != "text" && *format != "json" {
+		log.Fatalf("Invalid arguments: unsupported output format: %v", *format)
+		return
+	}
+
+	baselineResults, err := benchmark.LoadResults(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Error loading the baseline: %v", err)
+		return
+	}
+	candidateResults, err := benchmark.LoadResults(fs.Arg(1))
+	if err != nil {
+		log.Fatalf("Error loading the candidate: %v", err)
+		return
+	}
+
+	baseline, err := benchmark.LatencySample(baselineResults, *source)
+	if err != nil {
+		log.Fatalf("Error loading the baseline latencies: %v", err)
+		return
+	}
+	candidate, err := benchmark.LatencySample(candidateResults, *source)
+	if err != nil {
+		log.Fatalf("Error loading the candidate latencies: %v", err)
+		return
+	}
+	// raw samples can only be tested against binned ones at the resolution of the histogram
+	if baseline.Source != candidate.Source {
+		if baseline, err = benchmark.LatencySample(baselineResults, benchmark.SampleSourceHistogram); err == nil {
+			candidate, err = benchmark.LatencySample(candidateResults, benchmark.SampleSourceHistogram)
+		}
+		if err != nil {
+			log.Fatalf("Error loading the latency histograms: %v", err)
+			return
+		}
+	}
+
+	res, err := benchmark.TestSignificance(baseline, candidate, *alpha)
+	if err != nil {
+		log.Fatalf("Error testing significance: %v", err)
+		return
+	}
+	if *format == "json" {
+		if err := writeJSON(os.Stdout, res); err != nil {
+			log.Fatalf("Error writing the results: %v", err)
+		}
+		return
+	}
+	benchmark.PrintSignificanceResults(res)
+}